@@ -0,0 +1,228 @@
+package account
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"move_profit/exchange"
+)
+
+const (
+	// 已结束的订单最多保留的数量，超出后按结束顺序淘汰
+	maxFinishedOrders = 1000
+	// 最多保留的成交记录数量
+	maxTrades = 1000
+)
+
+type Balance struct {
+	Asset      string
+	Balance    decimal.Decimal
+	UpdateTime time.Time
+}
+
+// Trade 一笔成交，数量为基础币数量
+type Trade struct {
+	Id       string
+	OrderId  string
+	Market   string
+	Side     exchange.Side
+	Quantity decimal.Decimal
+	Price    decimal.Decimal
+	Fee      decimal.Decimal
+	Maker    bool
+	Time     time.Time
+}
+
+// Account 由私有频道推送维护的本地账户状态
+type Account struct {
+	venue string
+
+	mu             sync.RWMutex
+	balances       map[string]Balance
+	positions      map[string]exchange.Position
+	orders         map[string]exchange.Order
+	finished       map[string]bool // 已结束的订单 id
+	finishedOrders []string        // 按结束顺序，用于淘汰
+	listeners      []func(o exchange.Order)
+	trades         []Trade
+	updateTime     time.Time
+}
+
+func newAccount(venue string) *Account {
+	return &Account{
+		venue:     venue,
+		balances:  make(map[string]Balance),
+		positions: make(map[string]exchange.Position),
+		orders:    make(map[string]exchange.Order),
+		finished:  make(map[string]bool),
+	}
+}
+
+var accounts = map[string]*Account{
+	exchange.Binance: newAccount(exchange.Binance),
+	exchange.Gate:    newAccount(exchange.Gate),
+}
+
+// Get 返回某个交易所的账户
+func Get(venue string) *Account {
+	return accounts[venue]
+}
+
+func (a *Account) Venue() string {
+	return a.venue
+}
+
+// UpdateTime 最近一次收到推送的时间
+func (a *Account) UpdateTime() time.Time {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.updateTime
+}
+
+func (a *Account) UpdateBalance(b Balance) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if b.UpdateTime.IsZero() {
+		b.UpdateTime = time.Now()
+	}
+	a.balances[b.Asset] = b
+	a.updateTime = time.Now()
+}
+
+func (a *Account) Balance(asset string) (Balance, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	b, ok := a.balances[asset]
+	return b, ok
+}
+
+// UpdatePosition 数量为 0 时删除该市场的仓位
+func (a *Account) UpdatePosition(p exchange.Position) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if p.Quantity.IsZero() {
+		delete(a.positions, p.Market)
+	} else {
+		a.positions[p.Market] = p
+	}
+	a.updateTime = time.Now()
+}
+
+func (a *Account) Position(market string) exchange.Position {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	p, ok := a.positions[market]
+	if !ok {
+		return exchange.Position{Market: market}
+	}
+	return p
+}
+
+// Positions 所有非零仓位，按市场排序
+func (a *Account) Positions() []exchange.Position {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	list := make([]exchange.Position, 0, len(a.positions))
+	for _, p := range a.positions {
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Market < list[j].Market
+	})
+	return list
+}
+
+// OnOrderUpdate 注册订单推送的回调，在推送处理的 goroutine 中按推送顺序调用
+func (a *Account) OnOrderUpdate(f func(o exchange.Order)) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.listeners = append(a.listeners, f)
+}
+
+// UpdateOrder final 表示订单已结束(全部成交、撤销、拒绝等)，不再有后续推送
+func (a *Account) UpdateOrder(o exchange.Order, final bool) {
+	a.mu.Lock()
+	a.updateOrder(o, final)
+	listeners := a.listeners
+	a.mu.Unlock()
+
+	for _, f := range listeners {
+		f(o)
+	}
+}
+
+func (a *Account) updateOrder(o exchange.Order, final bool) {
+	a.orders[o.Id] = o
+	a.updateTime = time.Now()
+	// 已结束的订单可能重复推送，只记录一次
+	if !final || a.finished[o.Id] {
+		return
+	}
+	a.finished[o.Id] = true
+	a.finishedOrders = append(a.finishedOrders, o.Id)
+	if len(a.finishedOrders) > maxFinishedOrders {
+		delete(a.orders, a.finishedOrders[0])
+		delete(a.finished, a.finishedOrders[0])
+		a.finishedOrders = a.finishedOrders[1:]
+	}
+}
+
+func (a *Account) Order(id string) (exchange.Order, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	o, ok := a.orders[id]
+	return o, ok
+}
+
+// OpenOrders 未结束的订单，market 为空时返回所有市场
+func (a *Account) OpenOrders(market string) []exchange.Order {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	list := make([]exchange.Order, 0)
+	for id, o := range a.orders {
+		if a.finished[id] || (market != "" && o.Market != market) {
+			continue
+		}
+		list = append(list, o)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Id < list[j].Id
+	})
+	return list
+}
+
+func (a *Account) AddTrade(t Trade) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.trades = append(a.trades, t)
+	if len(a.trades) > maxTrades {
+		a.trades = a.trades[len(a.trades)-maxTrades:]
+	}
+	a.updateTime = time.Now()
+}
+
+// Trades 某个订单的成交记录，按成交顺序
+func (a *Account) Trades(orderId string) []Trade {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	list := make([]Trade, 0)
+	for _, t := range a.trades {
+		if t.OrderId == orderId {
+			list = append(list, t)
+		}
+	}
+	return list
+}
@@ -0,0 +1,42 @@
+package alert
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"move_profit/log"
+	"net/http"
+	"time"
+)
+
+var (
+	webhookUrl string
+	client     = &http.Client{Timeout: 5 * time.Second}
+)
+
+// Init 设置告警 webhook，为空时只写错误日志
+func Init(url string) {
+	webhookUrl = url
+}
+
+// Send 写错误日志并异步推送到 webhook，推送失败不影响调用方
+func Send(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	log.Log.Errorf("[alert] %s", msg)
+	if webhookUrl == "" {
+		return
+	}
+
+	go func() {
+		body, _ := json.Marshal(map[string]string{"text": "[move_profit] " + msg})
+		resp, err := client.Post(webhookUrl, "application/json", bytes.NewReader(body))
+		if err != nil {
+			log.Log.Warningf("send alert err:%+v", err)
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			log.Log.Warningf("send alert resp status:%d", resp.StatusCode)
+		}
+	}()
+}
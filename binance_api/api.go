@@ -2,17 +2,11 @@ package binance_api
 
 import (
 	"context"
-	"crypto/hmac"
-	"crypto/sha256"
-	"crypto/sha512"
-	"encoding/hex"
-	"encoding/json"
 	"errors"
 	"fmt"
 	sdk "github.com/adshao/go-binance/v2"
 	"github.com/adshao/go-binance/v2/futures"
-	"github.com/levigross/grequests"
-	"io"
+	"move_profit/exchange"
 	"move_profit/utils"
 	"net/http"
 	"net/url"
@@ -22,10 +16,10 @@ import (
 
 var binanceMarketInfoMap sync.Map
 
+// 资金费结算间隔，只有被调整过间隔的市场会出现在 fundingInfo 中，其他市场为默认 8 小时
 var (
-	ApikeyInvalidError = errors.New("invalid apikey")
-	apikeyInvalidCode  = -2015
-	apikeyInvalidCode2 = -2014
+	binanceFundingIntervalMap sync.Map
+	defaultFundingInterval    = 8 * time.Hour
 )
 
 type switchLeverageResp struct {
@@ -39,6 +33,32 @@ type MsgResp struct {
 	Msg  string `json:"msg"`
 }
 
+type positionRiskResp struct {
+	Symbol           string `json:"symbol"`
+	PositionAmt      string `json:"positionAmt"`
+	EntryPrice       string `json:"entryPrice"`
+	MarkPrice        string `json:"markPrice"`
+	UnRealizedProfit string `json:"unRealizedProfit"`
+	LiquidationPrice string `json:"liquidationPrice"`
+	Leverage         string `json:"leverage"`
+	MarginType       string `json:"marginType"`
+	PositionSide     string `json:"positionSide"`
+	UpdateTime       int64  `json:"updateTime"`
+}
+
+type commissionRateResp struct {
+	Symbol              string `json:"symbol"`
+	MakerCommissionRate string `json:"makerCommissionRate"`
+	TakerCommissionRate string `json:"takerCommissionRate"`
+}
+
+type fundingInfoResp struct {
+	Symbol                   string `json:"symbol"`
+	AdjustedFundingRateCap   string `json:"adjustedFundingRateCap"`
+	AdjustedFundingRateFloor string `json:"adjustedFundingRateFloor"`
+	FundingIntervalHours     int    `json:"fundingIntervalHours"`
+}
+
 type fapiTimeStampResp struct {
 	ServerTime int64 `json:"serverTime"`
 }
@@ -81,20 +101,14 @@ type binance struct {
 
 var BinanceApiClient *binance
 
-func InitBinanceApi(apiKey, apiSecret string) {
+// InitBinanceApi 只创建客户端，合约元数据通过 RefreshMarketInfo 加载
+func InitBinanceApi(fapiEndpoint, apiKey, apiSecret string) {
 	BinanceApiClient = &binance{
-		fapiEndpoint: "https://fapi.binance.com", // U本位合约
-		apiEndpoint:  "https://api.binance.com",  // 现货/杠杆/币安宝/矿池
+		fapiEndpoint: fapiEndpoint,              // U本位合约
+		apiEndpoint:  "https://api.binance.com", // 现货/杠杆/币安宝/矿池
 		key:          apiKey,
 		secret:       apiSecret,
 	}
-
-	result, _ := BinanceApiClient.GetMarketInfo()
-
-	for _, contract := range result.Symbols {
-		binanceMarketInfoMap.Store(utils.Trans2GateMarket(contract.Symbol), contract)
-	}
-
 }
 
 func GetMarketInfo(market string) (futures.Symbol, bool) {
@@ -105,265 +119,174 @@ func GetMarketInfo(market string) (futures.Symbol, bool) {
 	return val.(futures.Symbol), true
 }
 
-func (b *binance) GetMarketInfo() (*futures.ExchangeInfo, error) {
-	return sdk.NewFuturesClient(b.key, b.secret).NewExchangeInfoService().Do(context.Background(), futures.WithRecvWindow(10000))
-}
-
-func (b *binance) Order(market string, size string, side string) (*apiOrderRsp, error) {
-	//市价开多
-	values := url.Values{}
-	values.Set("symbol", utils.Trans2BinancecMarket(market)) //BTCUSDT
-	values.Set("side", side)                                 //BUY SELL
-	values.Set("type", "MARKET")
-	values.Set("quantity", size)
-	values.Set("timestamp", b.timestampMilli())
-	api := fmt.Sprintf("%s/fapi/v1/order?%s&signature=%s", b.fapiEndpoint, values.Encode(), b.makeSignature(b.secret, values))
-
-	req, err := http.NewRequest(http.MethodPost, api, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("X-MBX-APIKEY", b.key)
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
+// LoadFundingInfo 加载各市场的资金费结算间隔
+func LoadFundingInfo() error {
+	list, err := BinanceApiClient.GetFundingInfo()
 	if err != nil {
-		return nil, err
+		return err
 	}
-	if !utils.InArray(resp.StatusCode, []int{http.StatusOK, http.StatusCreated, http.StatusNoContent}) {
-		var res MsgResp
-		err := json.Unmarshal(body, &res)
-		if err != nil {
-			return nil, fmt.Errorf("resp code not 200 resp:%+v", resp)
-		}
-		if res.Code == apikeyInvalidCode {
-			return nil, ApikeyInvalidError
+	for _, info := range list {
+		if info.FundingIntervalHours > 0 {
+			binanceFundingIntervalMap.Store(utils.Trans2GateMarket(info.Symbol), time.Duration(info.FundingIntervalHours)*time.Hour)
 		}
-		return nil, fmt.Errorf("%s", res.Msg)
 	}
+	return nil
+}
 
-	var res *apiOrderRsp
-	err = json.Unmarshal(body, &res)
-	if err != nil {
-		return nil, fmt.Errorf("parse body err %+v err:%+v", resp, err)
-	} else {
-		return res, nil
+func GetFundingInterval(market string) time.Duration {
+	val, ok := binanceFundingIntervalMap.Load(market)
+	if !ok {
+		return defaultFundingInterval
 	}
+	return val.(time.Duration)
 }
 
-func (b *binance) GetBinanceTimeStamp() (int64, error) {
-	api := fmt.Sprintf("%s/fapi/v1/time", b.fapiEndpoint)
-
-	req, err := http.NewRequest(http.MethodGet, api, nil)
-	if err != nil {
-		return 0, err
-	}
+func (b *binance) GetMarketInfo() (*futures.ExchangeInfo, error) {
+	client := sdk.NewFuturesClient(b.key, b.secret)
+	client.HTTPClient = httpClient
+	// 与其它请求使用同一个配置的地址，sdk 默认为 fapi.binance.com
+	client.BaseURL = b.fapiEndpoint
+	return client.NewExchangeInfoService().Do(context.Background(), futures.WithRecvWindow(10000))
+}
 
-	req.Header.Set("Content-Type", "application/json")
+// orderParams 下单参数，数量和价格为符合精度的字符串
+type orderParams struct {
+	Market        string
+	Side          string // BUY SELL
+	Type          string // MARKET LIMIT
+	Quantity      string
+	Price         string // LIMIT
+	TimeInForce   string // LIMIT: GTC IOC FOK GTX
+	ReduceOnly    bool
+	ClientOrderId string
+}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return 0, err
+// Order 下单，返回 RESULT 类型的响应，市价单和 IOC/FOK 单返回时已是最终成交结果
+func (b *binance) Order(p orderParams) (*apiOrderRsp, error) {
+	values := url.Values{}
+	values.Set("symbol", utils.Trans2BinancecMarket(p.Market)) //BTCUSDT
+	values.Set("side", p.Side)                                 //BUY SELL
+	values.Set("type", p.Type)
+	values.Set("quantity", p.Quantity)
+	if p.Type == "LIMIT" {
+		values.Set("price", p.Price)
+		values.Set("timeInForce", p.TimeInForce)
 	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return 0, err
+	if p.ReduceOnly {
+		values.Set("reduceOnly", "true")
 	}
-
-	if !utils.InArray(resp.StatusCode, []int{http.StatusOK, http.StatusCreated, http.StatusNoContent}) {
-		var res MsgResp
-		err := json.Unmarshal(body, &res)
-		if err != nil {
-			return 0, fmt.Errorf("resp code not 200 resp:%+v", resp)
-		}
-		return 0, fmt.Errorf("%s", res.Msg)
+	if p.ClientOrderId != "" {
+		values.Set("newClientOrderId", p.ClientOrderId)
 	}
-	timeStampResp := new(fapiTimeStampResp)
-	err = json.Unmarshal(body, &timeStampResp)
-	if err != nil {
-		return 0, err
+	values.Set("newOrderRespType", "RESULT")
+
+	var res *apiOrderRsp
+	if err := b.signed(http.MethodPost, "/fapi/v1/order", values, &res); err != nil {
+		return nil, err
 	}
-	return timeStampResp.ServerTime, nil
+	return res, nil
 }
 
-// 切换持仓模式
-func (b *binance) SwitchPositionMode() error {
+// CancelOrder 撤单，返回撤单后的订单状态(含已成交数量)
+func (b *binance) CancelOrder(market, orderId string) (*apiOrderRsp, error) {
 	values := url.Values{}
-	serverTimeStamp := time.Now().UnixMilli()
-	values.Set("dualSidePosition", "false")
-	values.Set("timestamp", fmt.Sprintf("%d", serverTimeStamp))
-
-	binanceStamp, _ := b.GetBinanceTimeStamp()
-	if binanceStamp > 0 {
-		diff := binanceStamp - serverTimeStamp
-		if diff < 0 {
-			diff = -diff
-		}
-		if diff >= 5000 {
-			values.Set("recvWindow", fmt.Sprintf("%d", diff))
-		}
-	}
-
-	api := fmt.Sprintf("%s/fapi/v1/positionSide/dual?%s&signature=%s", b.fapiEndpoint, values.Encode(), b.makeSignature(b.secret, values))
+	values.Set("symbol", utils.Trans2BinancecMarket(market))
+	values.Set("orderId", orderId)
 
-	req, err := http.NewRequest(http.MethodPost, api, nil)
-	if err != nil {
-		return err
+	var res *apiOrderRsp
+	if err := b.signed(http.MethodDelete, "/fapi/v1/order", values, &res); err != nil {
+		return nil, err
 	}
-	req.Header.Set("X-MBX-APIKEY", b.key)
-	req.Header.Set("Content-Type", "application/json")
+	return res, nil
+}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
+// GetOrder 查询订单
+func (b *binance) GetOrder(market, orderId string) (*apiOrderRsp, error) {
+	values := url.Values{}
+	values.Set("symbol", utils.Trans2BinancecMarket(market))
+	values.Set("orderId", orderId)
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return err
-	}
-	if !utils.InArray(resp.StatusCode, []int{http.StatusOK, http.StatusCreated, http.StatusNoContent}) {
-		var res MsgResp
-		err = json.Unmarshal(body, &res)
-		if err != nil {
-			return fmt.Errorf("resp code not 200 resp:%+v", resp)
-		}
-		if res.Code == apikeyInvalidCode {
-			return ApikeyInvalidError
-		}
-		return fmt.Errorf("%s", res.Msg)
-	}
-	var res MsgResp
-	err = json.Unmarshal(body, &res)
-	if err != nil {
-		return fmt.Errorf("resp code not 200 resp:%+v", resp)
+	var res *apiOrderRsp
+	if err := b.signed(http.MethodGet, "/fapi/v1/order", values, &res); err != nil {
+		return nil, err
 	}
-	return nil
+	return res, nil
 }
 
-// 切换杠杆模式
-func (b *binance) SwitchLeverageType(leverageType string) error {
+// GetOrderByClientId 按自定义订单 id 查询
+func (b *binance) GetOrderByClientId(market, clientId string) (*apiOrderRsp, error) {
 	values := url.Values{}
-	serverTimeStamp := time.Now().UnixMilli()
-	values.Set("symbol", "BTCUSDT")
-	values.Set("marginType", leverageType) //保证金模式 ISOLATED(逐仓), CROSSED(全仓)
-	values.Set("timestamp", fmt.Sprintf("%d", serverTimeStamp))
-
-	binanceStamp, _ := b.GetBinanceTimeStamp()
-	if binanceStamp > 0 {
-		diff := binanceStamp - serverTimeStamp
-		if diff < 0 {
-			diff = -diff
-		}
-		if diff >= 5000 {
-			values.Set("recvWindow", fmt.Sprintf("%d", diff))
-		}
-	}
-
-	api := fmt.Sprintf("%s/fapi/v1/marginType?%s&signature=%s", b.fapiEndpoint, values.Encode(), b.makeSignature(b.secret, values))
+	values.Set("symbol", utils.Trans2BinancecMarket(market))
+	values.Set("origClientOrderId", clientId)
 
-	req, err := http.NewRequest(http.MethodPost, api, nil)
-	if err != nil {
-		return err
+	var res *apiOrderRsp
+	if err := b.signed(http.MethodGet, "/fapi/v1/order", values, &res); err != nil {
+		return nil, err
 	}
-	req.Header.Set("X-MBX-APIKEY", b.key)
-	req.Header.Set("Content-Type", "application/json")
+	return res, nil
+}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return err
+// OpenOrders 当前挂单，market 为空时查询所有市场(权重 40)
+func (b *binance) OpenOrders(market string) ([]apiOrderRsp, error) {
+	values := url.Values{}
+	if market != "" {
+		values.Set("symbol", utils.Trans2BinancecMarket(market))
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return err
-	}
-	if !utils.InArray(resp.StatusCode, []int{http.StatusOK, http.StatusCreated, http.StatusNoContent}) {
-		var res MsgResp
-		err = json.Unmarshal(body, &res)
-		if err != nil {
-			return fmt.Errorf("resp code not 200 resp:%+v", resp)
-		}
-		if res.Code == apikeyInvalidCode {
-			return ApikeyInvalidError
-		}
-		return fmt.Errorf("%s", res.Msg)
+	var res []apiOrderRsp
+	if err := b.signed(http.MethodGet, "/fapi/v1/openOrders", values, &res); err != nil {
+		return nil, err
 	}
-	return nil
+	return res, nil
 }
 
-func (b *binance) SwitchMarginMode(market string) error {
-
+// CancelAllOrders 撤销某个市场的全部挂单
+func (b *binance) CancelAllOrders(market string) error {
 	values := url.Values{}
-	serverTimeStamp := time.Now().UnixMilli()
 	values.Set("symbol", utils.Trans2BinancecMarket(market))
-	values.Set("marginType", "CROSSED")
-	values.Set("timestamp", fmt.Sprintf("%d", serverTimeStamp))
-
-	binanceStamp, _ := b.GetBinanceTimeStamp()
-	if binanceStamp > 0 {
-		diff := binanceStamp - serverTimeStamp
-		if diff < 0 {
-			diff = -diff
-		}
-		if diff >= 5000 {
-			values.Set("recvWindow", fmt.Sprintf("%d", diff))
-		}
-	}
-
-	api := fmt.Sprintf("%s/fapi/v1/marginType?%s&signature=%s", b.fapiEndpoint, values.Encode(), b.makeSignature(b.secret, values))
-
-	req, err := http.NewRequest(http.MethodPost, api, nil)
-	if err != nil {
-		return err
-	}
-	req.Header.Set("X-MBX-APIKEY", b.key)
-	req.Header.Set("Content-Type", "application/json")
+	return b.signed(http.MethodDelete, "/fapi/v1/allOpenOrders", values, nil)
+}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+// ServerTime binance 服务器时间，用于时钟同步
+func ServerTime() (time.Time, error) {
+	ms, err := BinanceApiClient.GetBinanceTimeStamp()
 	if err != nil {
-		return err
+		return time.Time{}, err
 	}
-	defer resp.Body.Close()
+	return time.UnixMilli(ms), nil
+}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return err
-	}
-	if !utils.InArray(resp.StatusCode, []int{http.StatusOK, http.StatusCreated, http.StatusNoContent}) {
-		var res MsgResp
-		err = json.Unmarshal(body, &res)
-		if err != nil {
-			return fmt.Errorf("resp code not 200 resp:%+v", resp)
-		}
-		if res.Code == apikeyInvalidCode {
-			return ApikeyInvalidError
-		}
-		return fmt.Errorf("%s", res.Msg)
+func (b *binance) GetBinanceTimeStamp() (int64, error) {
+	var res fapiTimeStampResp
+	if err := b.public(http.MethodGet, "/fapi/v1/time", nil, &res); err != nil {
+		return 0, err
 	}
+	return res.ServerTime, nil
+}
 
-	var res *MsgResp
-	err = json.Unmarshal(body, &res)
-	if err != nil {
-		return fmt.Errorf("parse body err %+v err:%+v", resp, err)
-	} else {
+// 切换持仓模式
+func (b *binance) SwitchPositionMode() error {
+	values := url.Values{}
+	values.Set("dualSidePosition", "false")
+	err := b.signed(http.MethodPost, "/fapi/v1/positionSide/dual", values, nil)
+	// 已经是单向持仓时返回 -4059 No need to change position side，视为成功
+	var apiErr *exchange.Error
+	if errors.As(err, &apiErr) && apiErr.Code == "-4059" {
 		return nil
 	}
+	return err
+}
+
+// 切换杠杆模式
+func (b *binance) SwitchLeverageType(leverageType string) error {
+	return b.SwitchMarginMode("BTC_USDT", leverageType)
+}
+
+func (b *binance) SwitchMarginMode(market string, marginType string) error {
+	values := url.Values{}
+	values.Set("symbol", utils.Trans2BinancecMarket(market))
+	values.Set("marginType", marginType) //保证金模式 ISOLATED(逐仓), CROSSED(全仓)
+	return b.signed(http.MethodPost, "/fapi/v1/marginType", values, nil)
 }
 
 // 切换杠杆
@@ -372,134 +295,69 @@ func (b *binance) SwitchLeverage(market string, leverage int) (*switchLeverageRe
 		return nil, fmt.Errorf("leverage over limit")
 	}
 	values := url.Values{}
-	serverTimeStamp := time.Now().UnixMilli()
 	values.Set("symbol", utils.Trans2BinancecMarket(market))
 	values.Set("leverage", fmt.Sprintf("%d", leverage))
-	values.Set("timestamp", fmt.Sprintf("%d", serverTimeStamp))
 
-	binanceStamp, _ := b.GetBinanceTimeStamp()
-	if binanceStamp > 0 {
-		diff := binanceStamp - serverTimeStamp
-		if diff < 0 {
-			diff = -diff
-		}
-		if diff >= 5000 {
-			values.Set("recvWindow", fmt.Sprintf("%d", diff))
-		}
-	}
-
-	api := fmt.Sprintf("%s/fapi/v1/leverage?%s&signature=%s", b.fapiEndpoint, values.Encode(), b.makeSignature(b.secret, values))
-
-	req, err := http.NewRequest(http.MethodPost, api, nil)
-	if err != nil {
+	var res *switchLeverageResp
+	if err := b.signed(http.MethodPost, "/fapi/v1/leverage", values, &res); err != nil {
 		return nil, err
 	}
-	req.Header.Set("X-MBX-APIKEY", b.key)
-	req.Header.Set("Content-Type", "application/json")
+	return res, nil
+}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
+// 查询持仓
+// GetPositionRisk market 为空时返回所有市场
+func (b *binance) GetPositionRisk(market string) ([]*positionRiskResp, error) {
+	values := url.Values{}
+	if market != "" {
+		values.Set("symbol", utils.Trans2BinancecMarket(market))
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
+	var res []*positionRiskResp
+	if err := b.signed(http.MethodGet, "/fapi/v2/positionRisk", values, &res); err != nil {
 		return nil, err
 	}
-	if !utils.InArray(resp.StatusCode, []int{http.StatusOK, http.StatusCreated, http.StatusNoContent}) {
-		var res MsgResp
-		err = json.Unmarshal(body, &res)
-		if err != nil {
-			return nil, fmt.Errorf("resp code not 200 resp:%+v", resp)
-		}
-		if res.Code == apikeyInvalidCode {
-			return nil, ApikeyInvalidError
-		}
-		return nil, fmt.Errorf("%s", res.Msg)
-	}
-
-	var res *switchLeverageResp
-	err = json.Unmarshal(body, &res)
-	if err != nil {
-		return nil, fmt.Errorf("parse body err %+v err:%+v", resp, err)
-	} else {
-		return res, nil
-	}
-}
-
-// makeSignature 添加签名
-func (b *binance) makeSignature(secret string, values url.Values) string {
-	hash := hmac.New(sha256.New, []byte(secret))
-	hash.Write([]byte(values.Encode()))
-	return fmt.Sprintf("%x", hash.Sum(nil))
-}
-
-func (b *binance) timestampMilli() string {
-	return fmt.Sprintf("%d", time.Now().UnixMilli())
+	return res, nil
 }
 
-func GetListenKey(host, apiKey, apiSecret string) (listenKey string, err error) {
-	hash := hmac.New(sha512.New, []byte(apiSecret))
-	hash.Write([]byte(fmt.Sprintf("timestamp=%d", time.Now().UnixMilli())))
-
-	requestBody := map[string]interface{}{
-		"timestamp": time.Now().UnixMilli(),
-		"signature": hex.EncodeToString(hash.Sum(nil)),
-	}
-
-	requestOptions := &grequests.RequestOptions{
-		JSON:    requestBody,
-		Headers: map[string]string{"X-MBX-APIKEY": apiKey},
-	}
-
-	// 发送HTTP POST请求以获取listenKey
-	url := host + "/fapi/v1/listenKey"
-	resp, err := grequests.Post(url, requestOptions)
-	if err != nil {
-		return
-	}
-
-	// 检查响应状态码
-	if resp.StatusCode != 200 {
-		err = fmt.Errorf("GetListenKey HTTP request failed with status code: %d, msg: %s", resp.StatusCode, string(resp.Bytes()))
-		return
+// 查询资金费率配置，公开接口
+func (b *binance) GetFundingInfo() ([]*fundingInfoResp, error) {
+	var res []*fundingInfoResp
+	if err := b.public(http.MethodGet, "/fapi/v1/fundingInfo", nil, &res); err != nil {
+		return nil, err
 	}
+	return res, nil
+}
 
-	// 解析JSON响应以获取listenKey
-	var response map[string]interface{}
-	if err = resp.JSON(&response); err != nil {
-		err = fmt.Errorf("GetListenKey error decoding JSON response:%v", err)
-		return
-	}
+// 查询用户手续费率
+func (b *binance) GetCommissionRate(market string) (*commissionRateResp, error) {
+	values := url.Values{}
+	values.Set("symbol", utils.Trans2BinancecMarket(market))
 
-	listenKey, ok := response["listenKey"].(string)
-	if !ok {
-		err = fmt.Errorf("GetListenKey failed to extract listenKey from the response")
-		return
+	var res *commissionRateResp
+	if err := b.signed(http.MethodGet, "/fapi/v1/commissionRate", values, &res); err != nil {
+		return nil, err
 	}
-
-	return listenKey, nil
+	return res, nil
 }
 
-func RefreshListenKey(host, apiKey string) (err error) {
-	requestOptions := &grequests.RequestOptions{
-		Headers: map[string]string{"X-MBX-APIKEY": apiKey},
-	}
+type listenKeyResp struct {
+	ListenKey string `json:"listenKey"`
+}
 
-	// 发送HTTP PUT 请求以延长 listenKey 时间
-	url := host + "/fapi/v1/listenKey"
-	resp, err := grequests.Put(url, requestOptions)
-	if err != nil {
-		return
+// GetListenKey 创建用户数据流的 listenKey，已存在时返回原有的并延长有效期
+func (b *binance) GetListenKey() (string, error) {
+	var res listenKeyResp
+	if err := b.signed(http.MethodPost, "/fapi/v1/listenKey", nil, &res); err != nil {
+		return "", err
 	}
-
-	// 检查响应状态码
-	if resp.StatusCode != 200 {
-		err = fmt.Errorf("RefreshListenKey HTTP request failed with status code: %d, msg: %s", resp.StatusCode, string(resp.Bytes()))
-		return
+	if res.ListenKey == "" {
+		return "", fmt.Errorf("binance listenKey missing in response")
 	}
+	return res.ListenKey, nil
+}
 
-	return nil
+// RefreshListenKey 延长 listenKey 有效期 60 分钟
+func (b *binance) RefreshListenKey() error {
+	return b.signed(http.MethodPut, "/fapi/v1/listenKey", nil, nil)
 }
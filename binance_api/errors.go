@@ -0,0 +1,53 @@
+package binance_api
+
+import (
+	"encoding/json"
+	"move_profit/exchange"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// binanceErrorKinds 错误码分类，见 https://developers.binance.com/docs/derivatives/usds-margined-futures/error-code
+var binanceErrorKinds = map[int]exchange.ErrorKind{
+	-1022: exchange.KindInvalidKey, // 签名无效
+	-2014: exchange.KindInvalidKey, // apikey 格式错误
+	-2015: exchange.KindInvalidKey, // apikey、ip 或权限无效
+	-1021: exchange.KindTimestamp,  // 时间戳超出 recvWindow
+	-2018: exchange.KindInsufficientMargin,
+	-2019: exchange.KindInsufficientMargin,
+	-4164: exchange.KindMinNotional, // 名义价值低于 MIN_NOTIONAL
+	-4003: exchange.KindMinNotional, // 数量小于 0
+	-2022: exchange.KindReduceOnlyRejected,
+	-1003: exchange.KindRateLimited,    // 请求过多
+	-1015: exchange.KindRateLimited,    // 下单过多
+	-2011: exchange.KindUnknownOrder,   // 撤单时订单不存在
+	-2013: exchange.KindUnknownOrder,   // 订单不存在
+	-1102: exchange.KindInvalidRequest, // 缺少参数
+	-1111: exchange.KindInvalidRequest, // 精度超出
+	-1116: exchange.KindInvalidRequest, // 订单类型无效
+	-1121: exchange.KindInvalidRequest, // 市场无效
+	-4014: exchange.KindInvalidRequest, // 价格不是 tickSize 的整数倍
+	-4023: exchange.KindInvalidRequest, // 数量不是 stepSize 的整数倍
+	-5022: exchange.KindPostOnlyRejected,
+}
+
+// decodeError 非 2xx 响应转换为 *exchange.Error，响应体为 {"code":-1121,"msg":"Invalid symbol."}
+func decodeError(statusCode int, body []byte) error {
+	e := &exchange.Error{Venue: exchange.Binance, Status: statusCode}
+	var res MsgResp
+	if err := json.Unmarshal(body, &res); err != nil || (res.Code == 0 && res.Msg == "") {
+		e.Msg = truncate(body)
+	} else {
+		e.Code, e.Msg = strconv.Itoa(res.Code), res.Msg
+		e.Kind = binanceErrorKinds[res.Code]
+		// -1013 为各种过滤器失败，只有 MIN_NOTIONAL 归为下单量过小
+		if res.Code == -1013 && strings.Contains(res.Msg, "MIN_NOTIONAL") {
+			e.Kind = exchange.KindMinNotional
+		}
+	}
+	if statusCode == http.StatusTooManyRequests || statusCode == http.StatusTeapot {
+		e.Kind = exchange.KindRateLimited
+	}
+	return e
+}
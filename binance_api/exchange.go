@@ -0,0 +1,228 @@
+package binance_api
+
+import (
+	"errors"
+	"fmt"
+	"move_profit/exchange"
+	"move_profit/utils"
+
+	"github.com/shopspring/decimal"
+)
+
+// binanceExchange 将 binance U本位合约适配为 exchange.Exchange
+type binanceExchange struct {
+	client *binance
+}
+
+func NewExchange() exchange.Exchange {
+	return &binanceExchange{client: BinanceApiClient}
+}
+
+func (e *binanceExchange) Name() string {
+	return exchange.Binance
+}
+
+func (e *binanceExchange) PlaceOrder(req exchange.OrderRequest) (*exchange.Order, error) {
+	if err := req.Check(); err != nil {
+		return nil, err
+	}
+	if req.OrderType() == exchange.OrderMarket && req.TimeInForce == exchange.FOK {
+		return nil, &exchange.Error{Venue: exchange.Binance, Kind: exchange.KindInvalidRequest, Msg: "market order does not support FOK"}
+	}
+
+	// binance 的 closePosition 只用于条件单，这里按当前持仓数量下只减仓单
+	quantity := req.Quantity
+	if req.ClosePosition {
+		position, err := e.GetPosition(req.Market)
+		if err != nil {
+			return nil, err
+		}
+		if quantity, err = req.CloseQuantity(position.Quantity); err != nil {
+			return nil, err
+		}
+	}
+	params := orderParams{
+		Market:        req.Market,
+		Side:          string(req.Side),
+		Type:          string(req.OrderType()),
+		Quantity:      quantity.String(),
+		ReduceOnly:    req.ReduceOnly || req.ClosePosition,
+		ClientOrderId: req.ClientId,
+	}
+	if req.OrderType() == exchange.OrderLimit {
+		params.Price = req.Price.String()
+		params.TimeInForce = string(req.TIF())
+	}
+
+	res, err := e.client.Order(params)
+	if err != nil {
+		return nil, err
+	}
+	order := newOrder(req.Market, res)
+	if req.TIF() == exchange.GTX && res.Status == "EXPIRED" && order.FilledQuantity.IsZero() {
+		// post only 单会吃单时 binance 直接过期，与 gate 一样作为拒绝返回
+		return nil, &exchange.Error{Venue: exchange.Binance, Kind: exchange.KindPostOnlyRejected, Msg: fmt.Sprintf("order %d expired", res.OrderId)}
+	}
+	return order, nil
+}
+
+// CancelOrder 返回撤单后的订单，已成交的部分仍在 FilledQuantity 中
+func (e *binanceExchange) CancelOrder(market, id string) (*exchange.Order, error) {
+	res, err := e.client.CancelOrder(market, id)
+	if err != nil {
+		return nil, err
+	}
+	return newOrder(market, res), nil
+}
+
+func (e *binanceExchange) GetOrder(market, id string) (*exchange.Order, error) {
+	res, err := e.client.GetOrder(market, id)
+	if err != nil {
+		return nil, err
+	}
+	return newOrder(market, res), nil
+}
+
+func (e *binanceExchange) GetOrderByClientId(market, clientId string) (*exchange.Order, error) {
+	res, err := e.client.GetOrderByClientId(market, clientId)
+	if err != nil {
+		return nil, err
+	}
+	return newOrder(market, res), nil
+}
+
+func (e *binanceExchange) OpenOrders(market string) ([]exchange.Order, error) {
+	list, err := e.client.OpenOrders(market)
+	if err != nil {
+		return nil, err
+	}
+	orders := make([]exchange.Order, 0, len(list))
+	for i := range list {
+		orders = append(orders, *newOrder(utils.Trans2GateMarket(list[i].Symbol), &list[i]))
+	}
+	return orders, nil
+}
+
+func (e *binanceExchange) CancelAllOrders(market string) error {
+	return e.client.CancelAllOrders(market)
+}
+
+// OrderState binance 订单状态转换为统一状态
+func OrderState(status string) exchange.OrderState {
+	switch status {
+	case "PARTIALLY_FILLED":
+		return exchange.OrderPartiallyFilled
+	case "FILLED":
+		return exchange.OrderFilled
+	case "CANCELED":
+		return exchange.OrderCancelled
+	case "REJECTED":
+		return exchange.OrderRejected
+	case "EXPIRED", "EXPIRED_IN_MATCH":
+		return exchange.OrderExpired
+	}
+	// NEW 以及强平、ADL 产生的 NEW_INSURANCE、NEW_ADL
+	return exchange.OrderNew
+}
+
+func newOrder(market string, res *apiOrderRsp) *exchange.Order {
+	origQty, _ := decimal.NewFromString(res.OrigQty)
+	executedQty, _ := decimal.NewFromString(res.ExecutedQty)
+	avgPrice, _ := decimal.NewFromString(res.AvgPrice)
+	return &exchange.Order{
+		Id:             fmt.Sprintf("%d", res.OrderId),
+		ClientId:       res.ClientOrderId,
+		Market:         market,
+		Side:           exchange.Side(res.Side),
+		Quantity:       origQty,
+		FilledQuantity: executedQty,
+		AvgPrice:       avgPrice,
+		State:          OrderState(res.Status),
+		Status:         res.Status,
+	}
+}
+
+func (e *binanceExchange) SetLeverage(market string, leverage int) error {
+	_, err := e.client.SwitchLeverage(market, leverage)
+	return err
+}
+
+// SetMarginMode 已经是该模式时 binance 返回 -4046，视为成功
+func (e *binanceExchange) SetMarginMode(market string, mode exchange.MarginMode) error {
+	err := e.client.SwitchMarginMode(market, string(mode))
+	var apiErr *exchange.Error
+	if errors.As(err, &apiErr) && apiErr.Code == "-4046" {
+		return nil
+	}
+	return err
+}
+
+func (e *binanceExchange) GetContract(market string) (exchange.Contract, bool) {
+	info, ok := GetMarketInfo(market)
+	if !ok {
+		return exchange.Contract{}, false
+	}
+	contract := exchange.Contract{
+		Market:       market,
+		Multiplier:   decimal.NewFromInt(1),
+		QuantityStep: decimal.New(1, -int32(info.QuantityPrecision)),
+		PriceStep:    decimal.New(1, -int32(info.PricePrecision)),
+		Tradable:     info.Status == "TRADING",
+	}
+	if filter := info.PriceFilter(); filter != nil {
+		if tick, err := decimal.NewFromString(filter.TickSize); err == nil && tick.IsPositive() {
+			contract.PriceStep = tick
+		}
+	}
+	if filter := info.LotSizeFilter(); filter != nil {
+		if step, err := decimal.NewFromString(filter.StepSize); err == nil && step.IsPositive() {
+			contract.QuantityStep = step
+		}
+		contract.MinQuantity, _ = decimal.NewFromString(filter.MinQuantity)
+		contract.MaxQuantity, _ = decimal.NewFromString(filter.MaxQuantity)
+	}
+	// 吃单用市价单，最大数量还受 MARKET_LOT_SIZE 限制
+	if filter := info.MarketLotSizeFilter(); filter != nil {
+		if limit, err := decimal.NewFromString(filter.MaxQuantity); err == nil && limit.IsPositive() &&
+			(contract.MaxQuantity.IsZero() || limit.LessThan(contract.MaxQuantity)) {
+			contract.MaxQuantity = limit
+		}
+	}
+	if filter := info.MinNotionalFilter(); filter != nil {
+		contract.MinNotional, _ = decimal.NewFromString(filter.Notional)
+	}
+	return contract, true
+}
+
+func (e *binanceExchange) GetPosition(market string) (exchange.Position, error) {
+	list, err := e.client.GetPositionRisk(market)
+	if err != nil {
+		return exchange.Position{}, err
+	}
+	position := exchange.Position{Market: market}
+	for _, p := range list {
+		quantity, _ := decimal.NewFromString(p.PositionAmt)
+		entryPrice, _ := decimal.NewFromString(p.EntryPrice)
+		position.Quantity = position.Quantity.Add(quantity)
+		if !quantity.IsZero() {
+			position.EntryPrice = entryPrice
+		}
+	}
+	return position, nil
+}
+
+func (e *binanceExchange) GetFeeRate(market string) (exchange.FeeRate, error) {
+	res, err := e.client.GetCommissionRate(market)
+	if err != nil {
+		return exchange.FeeRate{}, err
+	}
+	maker, err := decimal.NewFromString(res.MakerCommissionRate)
+	if err != nil {
+		return exchange.FeeRate{}, err
+	}
+	taker, err := decimal.NewFromString(res.TakerCommissionRate)
+	if err != nil {
+		return exchange.FeeRate{}, err
+	}
+	return exchange.FeeRate{Maker: maker, Taker: taker}, nil
+}
@@ -0,0 +1,67 @@
+package binance_api
+
+import (
+	"move_profit/exchange"
+	"move_profit/ratelimit"
+	"move_profit/utils"
+	"reflect"
+	"strings"
+
+	"github.com/adshao/go-binance/v2/futures"
+)
+
+// RefreshMarketInfo 重新加载 exchangeInfo，返回与上次相比的合约变化，首次加载时每个市场都是 listed。
+// 只保留 U本位永续合约，交割合约(BTCUSDT_240628)与永续合约会换算成同一个市场
+func RefreshMarketInfo() ([]exchange.MarketChange, error) {
+	result, err := BinanceApiClient.GetMarketInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make([]exchange.MarketChange, 0)
+	seen := make(map[string]bool)
+	for _, symbol := range result.Symbols {
+		if symbol.QuoteAsset != "USDT" || strings.Contains(symbol.Symbol, "_") {
+			continue
+		}
+		market := utils.Trans2GateMarket(symbol.Symbol)
+		if market == "" {
+			continue
+		}
+		seen[market] = true
+		change := exchange.MarketChange{Venue: exchange.Binance, Market: market, Tradable: symbol.Status == "TRADING"}
+		old, ok := GetMarketInfo(market)
+		binanceMarketInfoMap.Store(market, symbol)
+		switch {
+		case !ok:
+			change.Kind = exchange.MarketListed
+		case old.Status != symbol.Status:
+			change.Kind = exchange.MarketStatusChanged
+		case rulesChanged(old, symbol):
+			change.Kind = exchange.MarketRulesChanged
+		default:
+			continue
+		}
+		changes = append(changes, change)
+	}
+	binanceMarketInfoMap.Range(func(key, value any) bool {
+		if market := key.(string); !seen[market] {
+			binanceMarketInfoMap.Delete(market)
+			changes = append(changes, exchange.MarketChange{Venue: exchange.Binance, Market: market, Kind: exchange.MarketRemoved})
+		}
+		return true
+	})
+
+	limiter := ratelimit.Get(exchange.Binance)
+	for _, limit := range result.RateLimits {
+		limiter.SetLimit(ratelimit.BinanceCounter(limit.RateLimitType, limit.Interval, limit.IntervalNum),
+			int(limit.Limit), ratelimit.BinanceInterval(limit.Interval, limit.IntervalNum))
+	}
+	return changes, nil
+}
+
+func rulesChanged(old, cur futures.Symbol) bool {
+	return old.PricePrecision != cur.PricePrecision ||
+		old.QuantityPrecision != cur.QuantityPrecision ||
+		!reflect.DeepEqual(old.Filters, cur.Filters)
+}
@@ -0,0 +1,118 @@
+package binance_api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"move_profit/clock"
+	"move_profit/exchange"
+	"move_profit/log"
+	"move_profit/ratelimit"
+	"move_profit/utils"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	recvWindow        = 5000
+	maxErrBodyLength  = 512
+	httpClientTimeout = 10 * time.Second
+)
+
+// httpClient 所有 binance REST 请求共用，经过限频器计权重和读取用量头
+var httpClient = &http.Client{
+	Timeout:   httpClientTimeout,
+	Transport: ratelimit.Get(exchange.Binance).Transport(nil),
+}
+
+// public 公开接口，params 可为 nil，result 为 nil 时忽略响应体
+func (b *binance) public(method, path string, params url.Values, result interface{}) error {
+	return b.do(method, path, params, false, result)
+}
+
+// signed 需要签名的接口，自动补上 timestamp/recvWindow/signature 和 apikey 头；
+// 时间戳过期时同步一次服务器时间后重试
+func (b *binance) signed(method, path string, params url.Values, result interface{}) error {
+	if params == nil {
+		params = url.Values{}
+	}
+	err := b.do(method, path, params, true, result)
+	if errors.Is(err, exchange.ErrTimestamp) {
+		if syncErr := clock.Get(exchange.Binance).Sync(); syncErr != nil {
+			log.Log.Errorf("binance sync server time err:%+v", syncErr)
+			return err
+		}
+		return b.do(method, path, params, true, result)
+	}
+	return err
+}
+
+func (b *binance) do(method, path string, params url.Values, signed bool, result interface{}) error {
+	query := ""
+	if signed {
+		params.Set("timestamp", strconv.FormatInt(clock.Get(exchange.Binance).Now().UnixMilli(), 10))
+		params.Set("recvWindow", strconv.Itoa(recvWindow))
+		query = params.Encode()
+		query += "&signature=" + b.makeSignature(b.secret, query)
+	} else if len(params) > 0 {
+		query = params.Encode()
+	}
+
+	api := b.fapiEndpoint + path
+	if query != "" {
+		api += "?" + query
+	}
+	req, err := http.NewRequest(method, api, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if signed {
+		req.Header.Set("X-MBX-APIKEY", b.key)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		var limitErr *ratelimit.LimitError
+		if errors.As(err, &limitErr) {
+			return &exchange.Error{Venue: exchange.Binance, Kind: exchange.KindRateLimited, Msg: limitErr.Error(), Err: err}
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if !utils.InArray(resp.StatusCode, []int{http.StatusOK, http.StatusCreated, http.StatusNoContent}) {
+		return decodeError(resp.StatusCode, body)
+	}
+	if result == nil {
+		return nil
+	}
+	if err = json.Unmarshal(body, result); err != nil {
+		// 请求已被处理但结果未知，见 exchange.OutcomeUnknown
+		return &exchange.Error{Venue: exchange.Binance, Status: resp.StatusCode, Msg: fmt.Sprintf("%s %s parse body:[%s] err:%+v", method, path, truncate(body), err), Err: err}
+	}
+	return nil
+}
+
+func truncate(body []byte) string {
+	if len(body) > maxErrBodyLength {
+		return string(body[:maxErrBodyLength]) + "..."
+	}
+	return string(body)
+}
+
+// makeSignature 对 query 做 HMAC-SHA256 签名
+func (b *binance) makeSignature(secret string, query string) string {
+	hash := hmac.New(sha256.New, []byte(secret))
+	hash.Write([]byte(query))
+	return fmt.Sprintf("%x", hash.Sum(nil))
+}
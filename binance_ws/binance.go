@@ -2,23 +2,24 @@ package binance_ws
 
 import (
 	"fmt"
-	"github.com/bitly/go-simplejson"
-	"github.com/shopspring/decimal"
 	"move_profit/binance_api"
-	"move_profit/gate_api"
-	"move_profit/gate_ws"
+	"move_profit/config"
+	"move_profit/exchange"
+	"move_profit/jsonscan"
 	"move_profit/log"
+	"move_profit/quote"
+	"move_profit/strategy"
 	"move_profit/utils"
+	"strings"
 	"sync"
 	"time"
 )
 
-var binanceLastPriceMap sync.Map
-var count2Taker = 0
+// 单条订阅消息最多携带的 stream 数量
+const subscribeBatchSize = 100
 
-var fishingChan = make(chan *TmpPositionInfo, 1)
-
-func AsyncProcessBinancePubChan() {
+// AsyncProcessBinancePubChan 订阅全市场盘口和资金费率，以及 depthMarkets 的前 N 档深度
+func AsyncProcessBinancePubChan(depthMarkets []string) {
 	go func() {
 		//defer func() {
 		//	if r := recover(); r != nil {
@@ -27,14 +28,13 @@ func AsyncProcessBinancePubChan() {
 		//	}
 		//}()
 
-		processBinancePubChan()
+		processBinancePubChan(depthMarkets)
 	}()
 }
 
-func processBinancePubChan() {
+func processBinancePubChan(depthMarkets []string) {
 	server, err := NewWsService(log.Log, &ConnConf{
-		ApiUrl:                   "https://fapi.binance.com",
-		URL:                      "wss://fstream.binance.com/ws",
+		URL:                      config.Conf.Binance.WsUrl,
 		IsOpenPublicWs:           true,
 		PublicChanLen:            5000,
 		ListenKeyRefreshInterval: "58m50s",
@@ -66,165 +66,174 @@ func processBinancePubChan() {
 	}
 
 	err = server.WriteSubscribeMsg(SubscribeMsgRequest{
+		Id:     1,
 		Method: "SUBSCRIBE",
-		Params: []interface{}{"!ticker@arr"},
+		Params: []interface{}{"!bookTicker", "!markPrice@arr@1s"},
 	})
 	if err != nil {
 		return
 	}
 
+	depthMu.Lock()
+	pubServer = server
+	depthMu.Unlock()
+	SetDepthMarkets(depthMarkets)
+
+	msg := new(pubMsg)
 	for {
 		select {
 		case msgBytes := <-pub:
-			processPubMsg(msgBytes)
+			processPubMsg(msg, msgBytes)
 		}
 	}
 }
 
-func processPubMsg(msgBytes []byte) {
-	//{"e":"24hrMiniTicker","E":1702530188424,"s":"BTCUSDT","c":"42731.50","o":"40971.90","h":"43517.60","l":"40812.90","v":"360594.073","q":"15202373572.10"}
-	/**
-	{
-	   "e": "24hrMiniTicker",  // 事件类型
-	   "E": 123456789,         // 事件时间(毫秒)
-	   "s": "BNBUSDT",          // 交易对
-	   "c": "0.0025",          // 最新成交价格
-	   "o": "0.0010",          // 24小时前开始第一笔成交价格
-	   "h": "0.0025",          // 24小时内最高成交价
-	   "l": "0.0010",          // 24小时内最低成交价
-	   "v": "10000",           // 成交量
-	   "q": "18"               // 成交额
-	 }
-	*/
-	data, err := simplejson.NewJson(msgBytes)
-	if err != nil {
-		log.Log.Errorf("binance pase msg:[%s] err:[%+v]", string(msgBytes), err)
+var (
+	depthMu      sync.Mutex
+	pubServer    *WsService
+	depthSubs    = make(map[string]bool) // 已订阅深度的市场
+	subscribeSeq int64                   // 订阅消息 id，1 为全市场频道
+)
+
+// SetDepthMarkets 订阅新增市场的深度，取消已移除市场的深度；公共连接还没建立时只在建立后订阅初始市场。
+// 发送失败的市场下次调用时重试
+func SetDepthMarkets(markets []string) {
+	depthMu.Lock()
+	defer depthMu.Unlock()
+	if pubServer == nil {
 		return
 	}
-	tickerList, _ := data.Array()
-	for i := 0; i < len(tickerList); i++ {
-		ticker := data.GetIndex(i)
-		binanceMarket, _ := ticker.Get("s").String()
-		binancePrice, _ := ticker.Get("c").String()
-		binancePriceD, _ := decimal.NewFromString(binancePrice)
-		if !binancePriceD.IsPositive() {
-			continue
+
+	added, removed := diffMarkets(depthSubs, markets)
+	// 启动时的初始订阅不打印
+	if len(depthSubs) > 0 && len(added)+len(removed) > 0 {
+		log.Log.Warningf("binance depth markets added:%v removed:%v", added, removed)
+	}
+	writeDepth("SUBSCRIBE", added, true)
+	writeDepth("UNSUBSCRIBE", removed, false)
+}
+
+// diffMarkets 相对已订阅的 subs，markets 中新增和缺少的市场
+func diffMarkets(subs map[string]bool, markets []string) (added, removed []string) {
+	want := make(map[string]bool, len(markets))
+	for _, market := range markets {
+		want[market] = true
+		if !subs[market] {
+			added = append(added, market)
 		}
-		market := utils.Trans2GateMarket(binanceMarket)
-		if utils.InArrayString(market, []string{"BTC_USDT", "ETH_USDT"}) {
-			continue
+	}
+	for market := range subs {
+		if !want[market] {
+			removed = append(removed, market)
 		}
-		binanceLastPriceMap.Store(market, binancePriceD)
-		gatePrice, ok := gate_ws.GateLastPriceMap.Load(market)
-		if !ok {
-			continue
+	}
+	return added, removed
+}
+
+// writeDepth 调用方需持有 depthMu，按批发送，成功后更新 depthSubs
+func writeDepth(method string, markets []string, subscribed bool) {
+	for i := 0; i < len(markets); i += subscribeBatchSize {
+		end := i + subscribeBatchSize
+		if end > len(markets) {
+			end = len(markets)
 		}
-		gatePriceD := gatePrice.(decimal.Decimal)
-		diff := binancePriceD.Sub(gatePriceD).Abs()
-		diffRate := diff.Div(binancePriceD)
-		fee, _ := decimal.NewFromString("0.005")
-		lowFee, _ := decimal.NewFromString("0.002")
-		msg := fmt.Sprintf("市场:%s gate市价:%+v binance市价:%+v 价差:%+v 价差比例:%+v%s", market, gatePriceD, binancePriceD, diff, diffRate.Mul(decimal.NewFromInt(100)).Truncate(5), "%")
-		if len(fishingChan) >= 1 {
-			tmp := <-fishingChan
-			fishingChan <- tmp
-			stopDiff := tmp.DiffRate.Sub(lowFee)
-			//stopDiff, _ := decimal.NewFromString("0.0002")
-
-			if tmp.Market == market {
-				fmt.Println(fmt.Sprintf("market:%s diffRate:%+v stopDiff:%+v tmp market:%s", market, diffRate, stopDiff, tmp.Market))
-				if diffRate.LessThan(stopDiff) {
-					<-fishingChan
-					//出现平仓信号，判断是否有仓位可平仓
-					log.Log.Infof("[close position]%s", msg)
-					_, err = gate_api.PlaceExchagneOrder(tmp.Market, tmp.GatePositionSize*(-1))
-					if err != nil {
-						log.Log.Infof("gate err:%+v market:%s,size:%d", err, market, tmp.GatePositionSize*(-1))
-						panic(err)
-					}
-					side := "BUY"
-					if tmp.BinancePositionSide == "BUY" {
-						side = "SELL"
-					}
-					_, err = binance_api.BinanceApiClient.Order(tmp.Market,
-						tmp.BinancePositionSize.String(), side)
-					if err != nil {
-						log.Log.Infof("binance err:%+v market:%s size:%+v", err, market, tmp.BinancePositionSize.Mul(decimal.NewFromInt(-1)))
-						panic(err)
-					}
-
-				}
-			}
-			continue
+		streams := make([]interface{}, 0, end-i)
+		for _, market := range markets[i:end] {
+			streams = append(streams, depthStream(market))
 		}
-		if diffRate.LessThan(fee) {
+		subscribeSeq++
+		err := pubServer.WriteSubscribeMsg(SubscribeMsgRequest{
+			Id:     subscribeSeq + 1,
+			Method: method,
+			Params: streams,
+		})
+		if err != nil {
+			log.Log.Errorf("binance %s depth err:%+v", strings.ToLower(method), err)
 			continue
 		}
-		gateMarketInfo, ok := gate_api.GetMarketInfo(market)
-		if !ok {
-			continue
+		for _, market := range markets[i:end] {
+			if subscribed {
+				depthSubs[market] = true
+			} else {
+				delete(depthSubs, market)
+			}
 		}
+	}
+}
 
-		quantoMultiplier, _ := decimal.NewFromString(gateMarketInfo.QuantoMultiplier)
+// depthStream BTC_USDT -> btcusdt@depth20@500ms
+func depthStream(market string) string {
+	return fmt.Sprintf("%s@depth%d@500ms", strings.ToLower(utils.Trans2BinancecMarket(market)), config.Conf.Strategy.DepthLevels)
+}
 
-		binanceMarketInfo, ok := binance_api.GetMarketInfo(market)
-		if !ok {
-			continue
-		}
-		binanceSize := decimal.NewFromInt(120).Div(binancePriceD).Truncate(int32(binanceMarketInfo.QuantityPrecision))
-		sizeGate := int(binanceSize.Div(quantoMultiplier).IntPart())
-		count2Taker++
-		log.Log.Infof("%s ,count:%d", msg, count2Taker)
-
-		tmp := &TmpPositionInfo{
-			Market:   market,
-			DiffRate: diffRate,
-		}
-		fmt.Println(123)
-
-		binance_api.BinanceApiClient.SwitchMarginMode(market)
-		binance_api.BinanceApiClient.SwitchLeverage(market, 10)
-		gate_api.SwitchPositionLeverage(market, 10)
-		if gatePriceD.LessThan(binancePriceD) {
-			//gate买单，binance卖单
-			_, err = gate_api.PlaceExchagneOrder(market, sizeGate)
-			if err != nil {
-				log.Log.Infof("gate err:%+v market:%s,size:%d", err, market, sizeGate)
-				panic(err)
-			}
-			tmp.GatePositionSize = sizeGate
-			_, err = binance_api.BinanceApiClient.Order(market, binanceSize.String(), "SELL")
-			if err != nil {
-				log.Log.Infof("binance err:%+v market:%s size:%+v", err, market, binanceSize)
-				panic(err)
-			}
-			tmp.BinancePositionSize = binanceSize
-			tmp.BinancePositionSide = "SELL"
-		} else {
-			//gate卖单，binance买单
-			_, err = gate_api.PlaceExchagneOrder(market, sizeGate*(-1))
-			if err != nil {
-				log.Log.Infof("gate err:%+v market:%s,size:%d", err, market, sizeGate)
-				panic(err)
+// processPubMsg msg 在每条消息间复用以减少分配
+func processPubMsg(msg *pubMsg, msgBytes []byte) {
+	// 全市场标记价格推送为数组
+	if jsonscan.IsArray(msgBytes) {
+		err := jsonscan.ArrayEach(msgBytes, func(value []byte) bool {
+			if err := msg.decode(value); err != nil {
+				return false
 			}
-			tmp.GatePositionSize = sizeGate * (-1)
-			_, err = binance_api.BinanceApiClient.Order(market, binanceSize.String(), "BUY")
-			if err != nil {
-				log.Log.Infof("binance err:%+v market:%s size:%+v", err, market, binanceSize)
-				continue
-			}
-			tmp.BinancePositionSize = binanceSize
-			tmp.BinancePositionSide = "BUY"
+			processMarkPrice(msg)
+			return true
+		})
+		if err != nil {
+			log.Log.Errorf("binance pase msg:[%s] err:[%+v]", string(msgBytes), err)
 		}
-		fishingChan <- tmp
-		fmt.Println(123)
+		return
 	}
+
+	if err := msg.decode(msgBytes); err != nil {
+		log.Log.Errorf("binance pase msg:[%s] err:[%+v]", string(msgBytes), err)
+		return
+	}
+	market := marketOf(msg.symbol)
+	if market == "" {
+		return
+	}
+
+	switch string(msg.event) {
+	case "bookTicker":
+		processBookTicker(market, msg)
+	case "depthUpdate":
+		processDepth(market, msg)
+	}
+}
+
+// processBookTicker
+// {"e":"bookTicker","u":400900217,"E":1568014460893,"T":1568014460891,"s":"BNBUSDT","b":"25.35190000","B":"31.21000000","a":"25.36520000","A":"40.66000000"}
+func processBookTicker(market string, msg *pubMsg) {
+	if !msg.bid.IsPositive() || !msg.ask.IsPositive() {
+		return
+	}
+	quote.StoreBookTicker(exchange.Binance, market, quote.BookTicker{
+		Bid:     msg.bid.Decimal(),
+		BidSize: msg.bidSize.Decimal(),
+		Ask:     msg.ask.Decimal(),
+		AskSize: msg.askSize.Decimal(),
+	})
+	strategy.OnTick(market)
 }
 
-type TmpPositionInfo struct {
-	Market              string
-	BinancePositionSize decimal.Decimal
-	BinancePositionSide string
-	GatePositionSize    int
-	DiffRate            decimal.Decimal
+// processMarkPrice 记录资金费率和下次结算时间
+// {"e":"markPriceUpdate","E":1562305380000,"s":"BTCUSDT","p":"11794.15000000","i":"11784.62659091","P":"11784.25641265","r":"0.00038167","T":1562306400000}
+func processMarkPrice(msg *pubMsg) {
+	market := marketOf(msg.symbol)
+	if market == "" {
+		return
+	}
+	quote.StoreFunding(exchange.Binance, market, quote.Funding{
+		Rate:     msg.fundingRate.Decimal(),
+		NextTime: time.UnixMilli(msg.time),
+		Interval: binance_api.GetFundingInterval(market),
+	})
+}
+
+// processDepth 有限档深度推送，每条都是前 N 档的完整快照
+// {"e":"depthUpdate","E":1571889248277,"T":1571889248276,"s":"BTCUSDT","U":390497796,"u":390497878,"pu":390497794,"b":[["7403.89","0.002"]],"a":[["7405.96","3.340"]]}
+func processDepth(market string, msg *pubMsg) {
+	quote.StoreOrderBook(exchange.Binance, market, quote.OrderBook{
+		Bids: quoteLevels(msg.bids),
+		Asks: quoteLevels(msg.asks),
+	})
 }
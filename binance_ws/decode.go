@@ -0,0 +1,126 @@
+package binance_ws
+
+import (
+	"move_profit/jsonscan"
+	"move_profit/quote"
+	"move_profit/utils"
+	"sync"
+)
+
+type levelNum struct {
+	price    jsonscan.Num
+	quantity jsonscan.Num
+}
+
+// pubMsg 公共行情推送，按事件类型填充对应字段；切片字段指向原始消息或复用底层数组，只在处理本条消息期间有效
+type pubMsg struct {
+	event  []byte
+	symbol []byte
+	time   int64 // bookTicker/depthUpdate 为撮合时间，markPriceUpdate 为下次资金费结算时间
+
+	// bookTicker
+	bid, bidSize, ask, askSize jsonscan.Num
+
+	// depthUpdate
+	bids, asks []levelNum
+
+	// markPriceUpdate
+	fundingRate jsonscan.Num
+}
+
+func (m *pubMsg) reset() {
+	bids, asks := m.bids[:0], m.asks[:0]
+	*m = pubMsg{bids: bids, asks: asks}
+}
+
+func (m *pubMsg) decode(data []byte) error {
+	m.reset()
+	var levelErr error
+	err := jsonscan.ObjectEach(data, func(key, value []byte) bool {
+		switch string(key) {
+		case "e":
+			m.event = jsonscan.Unquote(value)
+		case "s":
+			m.symbol = jsonscan.Unquote(value)
+		case "T":
+			m.time, _ = jsonscan.ParseInt(value)
+		case "r":
+			m.fundingRate, _ = jsonscan.ParseNum(value)
+		// bookTicker 中 b/a 为价格，depthUpdate 中为深度档位数组
+		case "b":
+			if jsonscan.IsArray(value) {
+				m.bids, levelErr = appendLevels(m.bids, value)
+			} else {
+				m.bid, _ = jsonscan.ParseNum(value)
+			}
+		case "a":
+			if jsonscan.IsArray(value) {
+				m.asks, levelErr = appendLevels(m.asks, value)
+			} else {
+				m.ask, _ = jsonscan.ParseNum(value)
+			}
+		case "B":
+			m.bidSize, _ = jsonscan.ParseNum(value)
+		case "A":
+			m.askSize, _ = jsonscan.ParseNum(value)
+		}
+		return levelErr == nil
+	})
+	if err != nil {
+		return err
+	}
+	return levelErr
+}
+
+// appendLevels [["7403.89","0.002"],...]
+func appendLevels(levels []levelNum, data []byte) ([]levelNum, error) {
+	err := jsonscan.ArrayEach(data, func(value []byte) bool {
+		var l levelNum
+		i := 0
+		jsonscan.ArrayEach(value, func(v []byte) bool {
+			if i == 0 {
+				l.price, _ = jsonscan.ParseNum(v)
+			} else {
+				l.quantity, _ = jsonscan.ParseNum(v)
+			}
+			i++
+			return i < 2
+		})
+		levels = append(levels, l)
+		return true
+	})
+	return levels, err
+}
+
+// quoteLevels 转换为盘口档位，跳过数量为 0 的档位
+func quoteLevels(levels []levelNum) []quote.Level {
+	list := make([]quote.Level, 0, len(levels))
+	for _, l := range levels {
+		if !l.quantity.IsPositive() {
+			continue
+		}
+		list = append(list, quote.Level{Price: l.price.Decimal(), Quantity: l.quantity.Decimal()})
+	}
+	return list
+}
+
+var (
+	marketMu    sync.RWMutex
+	marketCache = make(map[string]string)
+)
+
+// marketOf BTCUSDT -> BTC_USDT，结果按 symbol 缓存
+func marketOf(symbol []byte) string {
+	marketMu.RLock()
+	market, ok := marketCache[string(symbol)]
+	marketMu.RUnlock()
+	if ok {
+		return market
+	}
+
+	market = utils.Trans2GateMarket(string(symbol))
+	marketMu.Lock()
+	marketCache[string(symbol)] = market
+	marketMu.Unlock()
+	return market
+}
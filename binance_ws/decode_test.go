@@ -0,0 +1,178 @@
+package binance_ws
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bitly/go-simplejson"
+	"github.com/shopspring/decimal"
+	"move_profit/jsonscan"
+	"move_profit/quote"
+)
+
+var (
+	bookTickerData = []byte(`{"e":"bookTicker","u":400900217,"E":1568014460893,"T":1568014460891,"s":"BNBUSDT","b":"25.35190000","B":"31.21000000","a":"25.36520000","A":"40.66000000"}`)
+	depthData      = []byte(`{"e":"depthUpdate","E":1571889248277,"T":1571889248276,"s":"BTCUSDT","U":390497796,"u":390497878,"pu":390497794,` +
+		`"b":[["7403.89","0.002"],["7403.90","3.906"],["7406.44","0"],["7407.21","1.000"],["7408.33","0.500"]],` +
+		`"a":[["7405.96","3.340"],["7406.63","4.525"],["7407.08","0"],["7407.90","2.100"],["7409.01","0.010"]]}`)
+	markPriceData = []byte(`{"e":"markPriceUpdate","E":1562305380000,"s":"BTCUSDT","p":"11794.15000000","i":"11784.62659091","P":"11784.25641265","r":"-0.00038167","T":1562306400000}`)
+)
+
+func dec(s string) decimal.Decimal {
+	return decimal.RequireFromString(s)
+}
+
+func equalLevels(got []quote.Level, want [][2]string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i, l := range got {
+		if !l.Price.Equal(dec(want[i][0])) || !l.Quantity.Equal(dec(want[i][1])) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestDecodeBookTicker(t *testing.T) {
+	var m pubMsg
+	if err := m.decode(bookTickerData); err != nil {
+		t.Fatal(err)
+	}
+	if string(m.event) != "bookTicker" || string(m.symbol) != "BNBUSDT" || m.time != 1568014460891 {
+		t.Errorf("header = %s %s %d", m.event, m.symbol, m.time)
+	}
+	if !m.bid.Decimal().Equal(dec("25.3519")) || !m.bidSize.Decimal().Equal(dec("31.21")) ||
+		!m.ask.Decimal().Equal(dec("25.3652")) || !m.askSize.Decimal().Equal(dec("40.66")) {
+		t.Errorf("book = %s %s %s %s", m.bid.Decimal(), m.bidSize.Decimal(), m.ask.Decimal(), m.askSize.Decimal())
+	}
+}
+
+func TestDecodeDepth(t *testing.T) {
+	var m pubMsg
+	if err := m.decode(depthData); err != nil {
+		t.Fatal(err)
+	}
+	wantBids := [][2]string{{"7403.89", "0.002"}, {"7403.90", "3.906"}, {"7407.21", "1"}, {"7408.33", "0.5"}}
+	wantAsks := [][2]string{{"7405.96", "3.34"}, {"7406.63", "4.525"}, {"7407.90", "2.1"}, {"7409.01", "0.01"}}
+	if bids := quoteLevels(m.bids); !equalLevels(bids, wantBids) {
+		t.Errorf("bids = %+v", bids)
+	}
+	if asks := quoteLevels(m.asks); !equalLevels(asks, wantAsks) {
+		t.Errorf("asks = %+v", asks)
+	}
+
+	// 档位切片在消息间复用，新消息不能残留上一条的档位
+	if err := m.decode([]byte(`{"e":"depthUpdate","s":"BTCUSDT","b":[["1","2"]],"a":[]}`)); err != nil {
+		t.Fatal(err)
+	}
+	if len(m.bids) != 1 || len(m.asks) != 0 || m.bid.IsPositive() {
+		t.Errorf("reused message bids = %+v asks = %+v", m.bids, m.asks)
+	}
+}
+
+func TestDecodeMarkPrice(t *testing.T) {
+	var m pubMsg
+	if err := m.decode(markPriceData); err != nil {
+		t.Fatal(err)
+	}
+	if string(m.event) != "markPriceUpdate" || m.time != 1562306400000 || !m.fundingRate.Decimal().Equal(dec("-0.00038167")) {
+		t.Errorf("mark price = %s %d %s", m.event, m.time, m.fundingRate.Decimal())
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	for _, data := range []string{
+		``,
+		`{"e":"bookTicker","s":"BNBUSDT","b":"25.35`,
+		`{"e":"depthUpdate","s":"BTCUSDT","b":[["7403.89","0.002"],["7403.90"`,
+		`{"e":"depthUpdate","s":"BTCUSDT","b":[["7403.89","0.002"],],"a":[]}`,
+	} {
+		var m pubMsg
+		if err := m.decode([]byte(data)); !errors.Is(err, jsonscan.ErrSyntax) {
+			t.Errorf("decode %q err = %v, want ErrSyntax", data, err)
+		}
+	}
+}
+
+func TestMarketOf(t *testing.T) {
+	if got := marketOf([]byte("BTCUSDT")); got != "BTC_USDT" {
+		t.Errorf("marketOf = %s", got)
+	}
+	if got := marketOf([]byte("BTCUSDT")); got != "BTC_USDT" {
+		t.Errorf("cached marketOf = %s", got)
+	}
+}
+
+func BenchmarkDecodeBookTicker(b *testing.B) {
+	var m pubMsg
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := m.decode(bookTickerData); err != nil {
+			b.Fatal(err)
+		}
+		_ = quote.BookTicker{Bid: m.bid.Decimal(), BidSize: m.bidSize.Decimal(), Ask: m.ask.Decimal(), AskSize: m.askSize.Decimal()}
+		_ = marketOf(m.symbol)
+	}
+}
+
+// BenchmarkSimplejsonBookTicker 改用 jsonscan 之前的解析方式
+func BenchmarkSimplejsonBookTicker(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		data, err := simplejson.NewJson(bookTickerData)
+		if err != nil {
+			b.Fatal(err)
+		}
+		symbol, _ := data.Get("s").String()
+		_, _ = data.Get("e").String()
+		bid, _ := data.Get("b").String()
+		bidSize, _ := data.Get("B").String()
+		ask, _ := data.Get("a").String()
+		askSize, _ := data.Get("A").String()
+		book := quote.BookTicker{}
+		book.Bid, _ = decimal.NewFromString(bid)
+		book.BidSize, _ = decimal.NewFromString(bidSize)
+		book.Ask, _ = decimal.NewFromString(ask)
+		book.AskSize, _ = decimal.NewFromString(askSize)
+		_ = marketOf([]byte(symbol))
+	}
+}
+
+func BenchmarkDecodeDepth(b *testing.B) {
+	var m pubMsg
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := m.decode(depthData); err != nil {
+			b.Fatal(err)
+		}
+		_ = quote.OrderBook{Bids: quoteLevels(m.bids), Asks: quoteLevels(m.asks)}
+	}
+}
+
+func BenchmarkSimplejsonDepth(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		data, err := simplejson.NewJson(depthData)
+		if err != nil {
+			b.Fatal(err)
+		}
+		_ = quote.OrderBook{Bids: simplejsonLevels(data.Get("b")), Asks: simplejsonLevels(data.Get("a"))}
+	}
+}
+
+func simplejsonLevels(data *simplejson.Json) []quote.Level {
+	list, _ := data.Array()
+	levels := make([]quote.Level, 0, len(list))
+	for i := range list {
+		price, _ := data.GetIndex(i).GetIndex(0).String()
+		quantity, _ := data.GetIndex(i).GetIndex(1).String()
+		level := quote.Level{}
+		level.Price, _ = decimal.NewFromString(price)
+		level.Quantity, _ = decimal.NewFromString(quantity)
+		if level.Quantity.IsPositive() {
+			levels = append(levels, level)
+		}
+	}
+	return levels
+}
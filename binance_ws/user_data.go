@@ -0,0 +1,288 @@
+package binance_ws
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"move_profit/account"
+	"move_profit/alert"
+	"move_profit/binance_api"
+	"move_profit/config"
+	"move_profit/exchange"
+	"move_profit/log"
+	"move_profit/utils"
+	"time"
+)
+
+const (
+	EventOrderTradeUpdate = "ORDER_TRADE_UPDATE"
+	EventAccountUpdate    = "ACCOUNT_UPDATE"
+	EventMarginCall       = "MARGIN_CALL"
+)
+
+// 用户数据推送中大小写不同的字段(如 "s"/"S"、"x"/"X")必须都声明，否则会按大小写不敏感匹配到同一个字段
+
+// OrderTradeUpdateEvent 订单/成交更新
+type OrderTradeUpdateEvent struct {
+	Event           string           `json:"e"`
+	EventTime       int64            `json:"E"`
+	TransactionTime int64            `json:"T"`
+	Order           OrderTradeUpdate `json:"o"`
+}
+
+type OrderTradeUpdate struct {
+	Symbol           string `json:"s"`
+	ClientOrderId    string `json:"c"`
+	Side             string `json:"S"`
+	Type             string `json:"o"`
+	TimeInForce      string `json:"f"`
+	OrigQty          string `json:"q"`
+	Price            string `json:"p"`
+	AvgPrice         string `json:"ap"`
+	StopPrice        string `json:"sp"`
+	ExecutionType    string `json:"x"`
+	Status           string `json:"X"`
+	OrderId          int64  `json:"i"`
+	LastFilledQty    string `json:"l"`
+	FilledQty        string `json:"z"`
+	LastFilledPrice  string `json:"L"`
+	CommissionAsset  string `json:"N"`
+	Commission       string `json:"n"`
+	TradeTime        int64  `json:"T"`
+	TradeId          int64  `json:"t"`
+	BidsNotional     string `json:"b"`
+	AsksNotional     string `json:"a"`
+	IsMaker          bool   `json:"m"`
+	ReduceOnly       bool   `json:"R"`
+	WorkingType      string `json:"wt"`
+	OrigType         string `json:"ot"`
+	PositionSide     string `json:"ps"`
+	ClosePosition    bool   `json:"cp"`
+	ActivationPrice  string `json:"AP"`
+	CallbackRate     string `json:"cr"`
+	RealizedProfit   string `json:"rp"`
+	PriceProtect     bool   `json:"pP"`
+	SelfTradePrevent string `json:"V"`
+	PriceMatch       string `json:"pm"`
+	GoodTillDate     int64  `json:"gtd"`
+}
+
+// AccountUpdateEvent 余额和仓位变动
+type AccountUpdateEvent struct {
+	Event           string        `json:"e"`
+	EventTime       int64         `json:"E"`
+	TransactionTime int64         `json:"T"`
+	Account         AccountUpdate `json:"a"`
+}
+
+type AccountUpdate struct {
+	Reason    string                  `json:"m"`
+	Balances  []AccountUpdateBalance  `json:"B"`
+	Positions []AccountUpdatePosition `json:"P"`
+}
+
+type AccountUpdateBalance struct {
+	Asset              string `json:"a"`
+	WalletBalance      string `json:"wb"`
+	CrossWalletBalance string `json:"cw"`
+	BalanceChange      string `json:"bc"`
+}
+
+type AccountUpdatePosition struct {
+	Symbol              string `json:"s"`
+	PositionAmt         string `json:"pa"`
+	EntryPrice          string `json:"ep"`
+	BreakEvenPrice      string `json:"bep"`
+	AccumulatedRealized string `json:"cr"`
+	UnrealizedPnl       string `json:"up"`
+	MarginType          string `json:"mt"`
+	IsolatedWallet      string `json:"iw"`
+	PositionSide        string `json:"ps"`
+}
+
+// MarginCallEvent 追加保证金通知
+type MarginCallEvent struct {
+	Event              string               `json:"e"`
+	EventTime          int64                `json:"E"`
+	CrossWalletBalance string               `json:"cw"`
+	Positions          []MarginCallPosition `json:"p"`
+}
+
+type MarginCallPosition struct {
+	Symbol            string `json:"s"`
+	PositionSide      string `json:"ps"`
+	PositionAmt       string `json:"pa"`
+	MarginType        string `json:"mt"`
+	IsolatedWallet    string `json:"iw"`
+	MarkPrice         string `json:"mp"`
+	UnrealizedPnl     string `json:"up"`
+	MaintenanceMargin string `json:"mm"`
+}
+
+// ListenKeyExpiredEvent listenKey 过期，WsService 收到后会自动换新的 listenKey 重连
+type ListenKeyExpiredEvent struct {
+	Event     string `json:"e"`
+	EventTime int64  `json:"E"`
+	ListenKey string `json:"listenKey"`
+}
+
+// AsyncProcessBinancePrivateChan 消费用户数据流，更新本地账户 account.Get(exchange.Binance)
+func AsyncProcessBinancePrivateChan() {
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				alert.Send("binance user data handler panic:%+v", r)
+			}
+		}()
+
+		processBinancePrivateChan()
+	}()
+}
+
+func processBinancePrivateChan() {
+	conf := config.Conf.Binance
+	server, err := NewWsService(log.Log, &ConnConf{
+		URL:                      conf.WsUrl,
+		IsOpenPrivacyWs:          true,
+		ListenKeyRefreshInterval: "58m50s",
+	})
+	if err != nil {
+		log.Log.Errorf("binance user data ws init err:%+v", err)
+		return
+	}
+
+	initChan := make(chan struct{})
+	go server.Start(initChan)
+	select {
+	case <-initChan:
+	case <-time.After(time.Second * 60):
+		alert.Send("binance user data ws init timeout, binance account updates stopped")
+		return
+	}
+	privacy, err := server.GetPrivacyMsgChan()
+	if err != nil {
+		return
+	}
+	restart, _ := server.GetRestartMsgChan()
+
+	for {
+		select {
+		case msgBytes := <-privacy:
+			processPrivacyMsg(msgBytes)
+		case <-restart:
+			// 连接建立或重连期间可能漏掉推送，用 REST 重新同步一次仓位
+			syncPositions()
+		}
+	}
+}
+
+func processPrivacyMsg(msgBytes []byte) {
+	var resp ResponseMsg
+	if err := json.Unmarshal(msgBytes, &resp); err != nil {
+		log.Log.Errorf("binance user data parse msg:[%s] err:[%+v]", string(msgBytes), err)
+		return
+	}
+
+	var err error
+	switch resp.Event {
+	case EventOrderTradeUpdate:
+		var e OrderTradeUpdateEvent
+		if err = json.Unmarshal(msgBytes, &e); err == nil {
+			processOrderTradeUpdate(e)
+		}
+	case EventAccountUpdate:
+		var e AccountUpdateEvent
+		if err = json.Unmarshal(msgBytes, &e); err == nil {
+			processAccountUpdate(e)
+		}
+	case EventMarginCall:
+		var e MarginCallEvent
+		if err = json.Unmarshal(msgBytes, &e); err == nil {
+			processMarginCall(e)
+		}
+	case EventListenKeyExpired:
+		var e ListenKeyExpiredEvent
+		if err = json.Unmarshal(msgBytes, &e); err == nil {
+			log.Log.Warningf("binance listenKey %s expired, reconnecting", e.ListenKey)
+		}
+	}
+	if err != nil {
+		log.Log.Errorf("binance user data decode %s msg:[%s] err:[%+v]", resp.Event, string(msgBytes), err)
+	}
+}
+
+func processOrderTradeUpdate(e OrderTradeUpdateEvent) {
+	o := e.Order
+	market := utils.Trans2GateMarket(o.Symbol)
+	order := exchange.Order{
+		Id:       fmt.Sprintf("%d", o.OrderId),
+		ClientId: o.ClientOrderId,
+		Market:   market,
+		Side:     exchange.Side(o.Side),
+		State:    binance_api.OrderState(o.Status),
+		Status:   o.Status,
+	}
+	order.Quantity, _ = decimal.NewFromString(o.OrigQty)
+	order.FilledQuantity, _ = decimal.NewFromString(o.FilledQty)
+	order.AvgPrice, _ = decimal.NewFromString(o.AvgPrice)
+	binanceAccount := account.Get(exchange.Binance)
+	binanceAccount.UpdateOrder(order, order.State.Final())
+
+	if o.ExecutionType != "TRADE" {
+		return
+	}
+	t := account.Trade{
+		Id:      fmt.Sprintf("%d", o.TradeId),
+		OrderId: order.Id,
+		Market:  market,
+		Side:    order.Side,
+		Maker:   o.IsMaker,
+		Time:    time.UnixMilli(o.TradeTime),
+	}
+	t.Quantity, _ = decimal.NewFromString(o.LastFilledQty)
+	t.Price, _ = decimal.NewFromString(o.LastFilledPrice)
+	t.Fee, _ = decimal.NewFromString(o.Commission)
+	binanceAccount.AddTrade(t)
+	log.Log.Infof("[binance fill] market:%s order:%s side:%s quantity:%s price:%s fee:%s", t.Market, t.OrderId, t.Side, t.Quantity, t.Price, t.Fee)
+}
+
+func processAccountUpdate(e AccountUpdateEvent) {
+	binanceAccount := account.Get(exchange.Binance)
+	for _, b := range e.Account.Balances {
+		balance := account.Balance{Asset: b.Asset, UpdateTime: time.UnixMilli(e.TransactionTime)}
+		balance.Balance, _ = decimal.NewFromString(b.WalletBalance)
+		binanceAccount.UpdateBalance(balance)
+	}
+	for _, p := range e.Account.Positions {
+		// 单向持仓模式下仓位方向为 BOTH
+		if p.PositionSide != "BOTH" {
+			continue
+		}
+		position := exchange.Position{Market: utils.Trans2GateMarket(p.Symbol)}
+		position.Quantity, _ = decimal.NewFromString(p.PositionAmt)
+		position.EntryPrice, _ = decimal.NewFromString(p.EntryPrice)
+		binanceAccount.UpdatePosition(position)
+	}
+}
+
+func processMarginCall(e MarginCallEvent) {
+	for _, p := range e.Positions {
+		alert.Send("[binance margin call] market:%s position:%s markPrice:%s unrealized:%s maintenance:%s crossWallet:%s",
+			utils.Trans2GateMarket(p.Symbol), p.PositionAmt, p.MarkPrice, p.UnrealizedPnl, p.MaintenanceMargin, e.CrossWalletBalance)
+	}
+}
+
+func syncPositions() {
+	list, err := binance_api.BinanceApiClient.GetPositionRisk("")
+	if err != nil {
+		log.Log.Errorf("binance sync positions err:%+v", err)
+		return
+	}
+	binanceAccount := account.Get(exchange.Binance)
+	for _, p := range list {
+		position := exchange.Position{Market: utils.Trans2GateMarket(p.Symbol)}
+		position.Quantity, _ = decimal.NewFromString(p.PositionAmt)
+		position.EntryPrice, _ = decimal.NewFromString(p.EntryPrice)
+		binanceAccount.UpdatePosition(position)
+	}
+}
@@ -54,10 +54,7 @@ type WsService struct {
 
 type ConnConf struct {
 	UserId                   uint32
-	ApiUrl                   string
 	URL                      string
-	Key                      string
-	Secret                   string
 	IsOpenPublicWs           bool   // 是否开启 ws 公共频道订阅
 	IsOpenPrivacyWs          bool   // 是否开启 ws 私有频道订阅
 	PublicChanLen            uint64 // 公共通道消息存储长度
@@ -209,7 +206,7 @@ func (ws *WsService) refreshListenKey(du time.Duration) {
 		case <-ticker.C:
 			isSuccess := false
 			for i := 0; i < ws.conf.MaxRetryConn; i++ {
-				err := binance_api.RefreshListenKey(ws.conf.ApiUrl, ws.conf.Key)
+				err := binance_api.BinanceApiClient.RefreshListenKey()
 				if err != nil {
 					ws.logger.Warningf("failed to refresh listkenKey:%s, retry %d times", err.Error(), i)
 					time.Sleep(time.Millisecond * 100 * time.Duration(i))
@@ -229,7 +226,7 @@ func (ws *WsService) refreshListenKey(du time.Duration) {
 			isSuccess := false
 			for i := 0; i < ws.conf.MaxRetryConn; i++ {
 				// listenKey 过期，手动刷新重连; 即时 listenKey 过期，链接也不会主动断开
-				listenKey, err = binance_api.GetListenKey(ws.conf.ApiUrl, ws.conf.Key, ws.conf.Secret)
+				listenKey, err = binance_api.BinanceApiClient.GetListenKey()
 				if err != nil {
 					ws.logger.Warningf("failed to get listenKey:%s, unable to get a new listenKey for expire event, retry %d times", err.Error(), i)
 					time.Sleep(time.Millisecond * 100 * time.Duration(i))
@@ -311,7 +308,7 @@ func (ws *WsService) initClient() error {
 		msgChan := make(chan []byte, ws.conf.PrivacyChanLen)
 		ws.privacyMsgChan = msgChan
 
-		listenKey, err := binance_api.GetListenKey(ws.conf.ApiUrl, ws.conf.Key, ws.conf.Secret)
+		listenKey, err := binance_api.BinanceApiClient.GetListenKey()
 		if err != nil {
 			return fmt.Errorf("failed to get listenKey:%s, unable to start privacy ws", err.Error())
 		}
@@ -384,8 +381,10 @@ type SubscribeMsgRequest struct {
 	isReconnect bool
 }
 
+// ResponseMsg 只解析事件类型；推送中同时有 "e" 和 "E"，两个都要声明，否则 "E" 会按大小写不敏感匹配到 "e"
 type ResponseMsg struct {
-	e string `json:"e"` // event
+	Event     string `json:"e"`
+	EventTime int64  `json:"E"`
 }
 
 func (ws *WsService) readPublicMsg() {
@@ -437,7 +436,7 @@ func (ws *WsService) readPrivacyMsg() {
 			if err != nil {
 				ws.logger.Warningf("failed to Unmarshal message [%s]:%s", string(message), err.Error())
 			} else {
-				if resp.e == EventListenKeyExpired {
+				if resp.Event == EventListenKeyExpired {
 					// 发送过期 listenKey 消息，去进行主动重连
 					ws.expireChan <- struct{}{}
 				}
@@ -528,7 +527,7 @@ func (ws *WsService) reconnectPrivacy() error {
 				// timeout
 				ws.logger.Warningf("failed to dial %s timeout:%s, manual get a new listenKey to retry", ws.getPrivacyUrl(), err.Error())
 
-				listenKey, err := binance_api.GetListenKey(ws.conf.ApiUrl, ws.conf.Key, ws.conf.Secret)
+				listenKey, err := binance_api.BinanceApiClient.GetListenKey()
 				if err != nil {
 					return fmt.Errorf("failed to get listenKey:%s, unable to get a new listenKey for reconnect", err.Error())
 				}
@@ -0,0 +1,135 @@
+// Package clock 交易所服务器时间同步：后台定期采样服务器时间，估算本地时钟偏移和往返时延，
+// 签名请求统一用校正后的时间戳
+package clock
+
+import (
+	"fmt"
+	"move_profit/alert"
+	"move_profit/exchange"
+	"move_profit/log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// samplesPerSync 每次同步采样次数，取往返时延最小的一次
+const samplesPerSync = 3
+
+// Sampler 请求一次交易所服务器时间
+type Sampler func() (time.Time, error)
+
+type Clock struct {
+	venue   string
+	sample  Sampler
+	maxSkew time.Duration
+
+	offset   atomic.Int64 // 服务器时间 - 本地时间，纳秒
+	rtt      atomic.Int64
+	lastSync atomic.Int64 // 上次同步成功的本地时间，unix 纳秒
+
+	mu     sync.Mutex // 串行化 Sync
+	skewed bool
+}
+
+var clocks = map[string]*Clock{
+	exchange.Binance: {venue: exchange.Binance},
+	exchange.Gate:    {venue: exchange.Gate},
+}
+
+// Get 返回某个交易所的时钟
+func Get(venue string) *Clock {
+	return clocks[venue]
+}
+
+// Now 按服务器时间偏移校正后的本地时间，未同步过时为本地时间
+func (c *Clock) Now() time.Time {
+	return time.Now().Add(c.Offset())
+}
+
+func (c *Clock) Offset() time.Duration {
+	return time.Duration(c.offset.Load())
+}
+
+func (c *Clock) RTT() time.Duration {
+	return time.Duration(c.rtt.Load())
+}
+
+// LastSync 上次同步成功的时间，未同步过时为零值
+func (c *Clock) LastSync() time.Time {
+	nano := c.lastSync.Load()
+	if nano == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nano)
+}
+
+// Start 同步一次后在后台每隔 interval 同步，偏移绝对值超过 maxSkew 时告警
+func (c *Clock) Start(sample Sampler, interval, maxSkew time.Duration) {
+	c.mu.Lock()
+	c.sample, c.maxSkew = sample, maxSkew
+	c.mu.Unlock()
+
+	if err := c.Sync(); err != nil {
+		log.Log.Errorf("[clock %s] sync err:%+v", c.venue, err)
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		for range ticker.C {
+			if err := c.Sync(); err != nil {
+				log.Log.Errorf("[clock %s] sync err:%+v", c.venue, err)
+			}
+		}
+	}()
+}
+
+// Sync 立即同步一次，时间戳被交易所拒绝时也可以直接调用
+func (c *Clock) Sync() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.sample == nil {
+		return fmt.Errorf("clock %s not started", c.venue)
+	}
+
+	var (
+		best    time.Duration
+		bestRtt time.Duration = -1
+		lastErr error
+	)
+	for i := 0; i < samplesPerSync; i++ {
+		start := time.Now()
+		serverTime, err := c.sample()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		end := time.Now()
+		rtt := end.Sub(start)
+		if bestRtt < 0 || rtt < bestRtt {
+			// 假设请求和响应路径耗时相同，服务器时间对应往返的中点
+			best, bestRtt = serverTime.Sub(start.Add(rtt/2)), rtt
+		}
+	}
+	if bestRtt < 0 {
+		return lastErr
+	}
+
+	c.offset.Store(int64(best))
+	c.rtt.Store(int64(bestRtt))
+	c.lastSync.Store(time.Now().UnixNano())
+	log.Log.Infof("[clock %s] offset:%s rtt:%s", c.venue, best, bestRtt)
+
+	skew := best
+	if skew < 0 {
+		skew = -skew
+	}
+	if c.maxSkew > 0 && skew > c.maxSkew {
+		if !c.skewed {
+			alert.Send("[clock %s] local clock skew %s exceeds %s, check ntp", c.venue, best, c.maxSkew)
+		}
+		c.skewed = true
+	} else if c.skewed {
+		log.Log.Infof("[clock %s] clock skew back to %s", c.venue, best)
+		c.skewed = false
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+// report 读取开平仓记录，按日、周、市场打印盈亏汇总
+package main
+
+import (
+	"flag"
+	"fmt"
+	"move_profit/ledger"
+	"os"
+	"text/tabwriter"
+)
+
+func main() {
+	path := flag.String("f", "./data/ledger.jsonl", "ledger file path")
+	paper := flag.Bool("paper", false, "report paper trading records instead of live records")
+	market := flag.String("market", "", "only report this market, e.g. BTC_USDT")
+	flag.Parse()
+
+	all, err := ledger.Load(*path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "load ledger err:%+v\n", err)
+		os.Exit(1)
+	}
+	records := make([]ledger.Record, 0, len(all))
+	for _, r := range all {
+		if r.Paper == *paper && (*market == "" || r.Market == *market) {
+			records = append(records, r)
+		}
+	}
+	if len(records) == 0 {
+		fmt.Println("no records")
+		return
+	}
+
+	printSummary("daily", ledger.Summarize(records, ledger.Day))
+	printSummary("weekly", ledger.Summarize(records, ledger.Week))
+	printSummary("market", ledger.Summarize(records, ledger.Market))
+	printSummary("total", ledger.Summarize(records, func(ledger.Record) string { return "all" }))
+}
+
+func printSummary(title string, list []ledger.Summary) {
+	fmt.Printf("== %s ==\n", title)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "key\ttrades\twins\tgross\tfees\tfunding\tnet\t")
+	for _, s := range list {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s\t%s\t%s\t\n",
+			s.Key, s.Trades, s.Wins, s.Gross.StringFixed(4), s.Fees.StringFixed(4), s.Funding.StringFixed(4), s.Net.StringFixed(4))
+	}
+	w.Flush()
+	fmt.Println()
+}
@@ -0,0 +1,381 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// 环境变量前缀，环境变量的值会覆盖配置文件
+const envPrefix = "MOVE_PROFIT_"
+
+var Conf *Config
+
+type Config struct {
+	Binance   BinanceConf   `json:"binance"`
+	Gate      GateConf      `json:"gate"`
+	Strategy  StrategyConf  `json:"strategy"`
+	Paper     PaperConf     `json:"paper"`
+	Execution ExecutionConf `json:"execution"`
+	Alert     AlertConf     `json:"alert"`
+	Clock     ClockConf     `json:"clock"`
+
+	StatePath  string `json:"state_path"`  // 仓位落盘路径，在途订单保存在同目录的 .orders 文件
+	LedgerPath string `json:"ledger_path"` // 开平仓记录追加写入的路径
+}
+
+type BinanceConf struct {
+	Key          string `json:"key"`
+	Secret       string `json:"secret"`
+	FapiEndpoint string `json:"fapi_endpoint"`
+	WsUrl        string `json:"ws_url"`
+}
+
+type GateConf struct {
+	Key            string   `json:"key"`
+	Secret         string   `json:"secret"`
+	WsUrl          string   `json:"ws_url"`
+	WsMaxRetry     int      `json:"ws_max_retry"`     // 断线后最多重连次数，0 为一直重连
+	WsPingInterval Duration `json:"ws_ping_interval"` // futures.ping 心跳间隔
+}
+
+type StrategyConf struct {
+	MinEntryEdge   decimal.Decimal `json:"min_entry_edge"`  // 开仓价差扣除往返手续费后的最小比例
+	MinExitProfit  decimal.Decimal `json:"min_exit_profit"` // 平仓时本轮扣除往返手续费后的最小收益率
+	Notional       decimal.Decimal `json:"notional"`        // 单次开仓名义价值(USDT)
+	Leverage       int             `json:"leverage"`
+	ExcludeMarkets []string        `json:"exclude_markets"` // 不参与套利的市场 BTC_USDT
+
+	FeeRefreshInterval      Duration `json:"fee_refresh_interval"`      // 交易所手续费率刷新间隔
+	MetadataRefreshInterval Duration `json:"metadata_refresh_interval"` // 合约元数据(上下架、状态、精度)刷新间隔
+	ExpectedHolding         Duration `json:"expected_holding"`          // 预计持仓时长，开仓时计入该时长内的资金费
+	FundingExitWindow       Duration `json:"funding_exit_window"`       // 平仓时计入该时长内即将发生的资金费结算
+
+	MaxPositions     int             `json:"max_positions"`      // 同时持有的最大仓位数
+	MaxTotalNotional decimal.Decimal `json:"max_total_notional"` // 所有仓位名义价值上限(USDT)
+	MaxQuoteAge      Duration        `json:"max_quote_age"`      // 盘口超过该时长未更新则不做开平仓判断
+	DepthLevels      int             `json:"depth_levels"`       // 订阅的深度档数，开仓前按此深度估算滑点
+}
+
+// PaperConf 模拟盘：行情为实盘，订单按当前报价在本地模拟成交
+type PaperConf struct {
+	Enabled         bool            `json:"enabled"`
+	BinanceTakerFee decimal.Decimal `json:"binance_taker_fee"`
+	GateTakerFee    decimal.Decimal `json:"gate_taker_fee"`
+	BinanceMakerFee decimal.Decimal `json:"binance_maker_fee"` // 挂单成交的费率
+	GateMakerFee    decimal.Decimal `json:"gate_maker_fee"`
+}
+
+// 开仓执行方式
+const (
+	ExecutionTaker = "taker" // 两腿都吃单
+	ExecutionMaker = "maker" // 一腿挂 post only 单，成交后另一腿吃单对冲
+)
+
+// ExecutionConf 单腿失败处理：第二腿在次数、时间和滑点预算内重试，超出后回滚已成交的第一腿
+type ExecutionConf struct {
+	Mode             string          `json:"mode"`
+	MakerTimeout     Duration        `json:"maker_timeout"` // maker 模式挂单最长等待时间，超时撤单
+	LegRetryTimes    int             `json:"leg_retry_times"`
+	LegRetryTimeout  Duration        `json:"leg_retry_timeout"`
+	MaxSlippage      decimal.Decimal `json:"max_slippage"` // 第二腿价格相对信号价格的最大不利变动比例
+	UnwindRetryTimes int             `json:"unwind_retry_times"`
+}
+
+// ClockConf 交易所服务器时间同步，签名请求使用校正后的时间
+type ClockConf struct {
+	SyncInterval Duration `json:"sync_interval"`
+	MaxSkew      Duration `json:"max_skew"` // 本地时钟偏移超过该值时告警
+}
+
+type AlertConf struct {
+	WebhookUrl string `json:"webhook_url"`
+}
+
+func defaultConfig() *Config {
+	return &Config{
+		Binance: BinanceConf{
+			FapiEndpoint: "https://fapi.binance.com",
+			WsUrl:        "wss://fstream.binance.com/ws",
+		},
+		Gate: GateConf{
+			WsUrl:          "wss://fx-ws.gateio.ws/v4/ws/usdt",
+			WsPingInterval: Duration(10 * time.Second),
+		},
+		Strategy: StrategyConf{
+			MinEntryEdge:   decimal.RequireFromString("0.002"),
+			MinExitProfit:  decimal.RequireFromString("0.001"),
+			Notional:       decimal.NewFromInt(120),
+			Leverage:       10,
+			ExcludeMarkets: []string{"BTC_USDT", "ETH_USDT"},
+
+			FeeRefreshInterval:      Duration(30 * time.Minute),
+			MetadataRefreshInterval: Duration(5 * time.Minute),
+			ExpectedHolding:         Duration(8 * time.Hour),
+			FundingExitWindow:       Duration(10 * time.Minute),
+
+			MaxPositions:     5,
+			MaxTotalNotional: decimal.NewFromInt(600),
+			MaxQuoteAge:      Duration(5 * time.Second),
+			DepthLevels:      20,
+		},
+		Paper: PaperConf{
+			BinanceTakerFee: decimal.RequireFromString("0.0005"),
+			GateTakerFee:    decimal.RequireFromString("0.0005"),
+			BinanceMakerFee: decimal.RequireFromString("0.0002"),
+			GateMakerFee:    decimal.RequireFromString("0.0002"),
+		},
+		Execution: ExecutionConf{
+			Mode:             ExecutionTaker,
+			MakerTimeout:     Duration(30 * time.Second),
+			LegRetryTimes:    3,
+			LegRetryTimeout:  Duration(3 * time.Second),
+			MaxSlippage:      decimal.RequireFromString("0.002"),
+			UnwindRetryTimes: 5,
+		},
+		Clock: ClockConf{
+			SyncInterval: Duration(time.Minute),
+			MaxSkew:      Duration(time.Second),
+		},
+		StatePath:  "./data/state.json",
+		LedgerPath: "./data/ledger.jsonl",
+	}
+}
+
+// Init 加载配置并设置全局 Conf
+func Init(path string) error {
+	c, err := Load(path)
+	if err != nil {
+		return err
+	}
+	Conf = c
+	return nil
+}
+
+// Load 依次应用默认值、配置文件(json)、环境变量，最后校验
+func Load(path string) (*Config, error) {
+	c := defaultConfig()
+
+	if path != "" {
+		body, err := os.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("read config file %s err:%+v", path, err)
+		}
+		if err == nil {
+			if err = json.Unmarshal(body, c); err != nil {
+				return nil, fmt.Errorf("parse config file %s err:%+v", path, err)
+			}
+		}
+	}
+
+	if err := c.applyEnv(); err != nil {
+		return nil, err
+	}
+
+	if err := c.Check(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Config) applyEnv() error {
+	setters := map[string]func(string) error{
+		"BINANCE_KEY":               setString(&c.Binance.Key),
+		"BINANCE_SECRET":            setString(&c.Binance.Secret),
+		"BINANCE_FAPI_ENDPOINT":     setString(&c.Binance.FapiEndpoint),
+		"BINANCE_WS_URL":            setString(&c.Binance.WsUrl),
+		"GATE_KEY":                  setString(&c.Gate.Key),
+		"GATE_SECRET":               setString(&c.Gate.Secret),
+		"GATE_WS_URL":               setString(&c.Gate.WsUrl),
+		"GATE_WS_MAX_RETRY":         setInt(&c.Gate.WsMaxRetry),
+		"GATE_WS_PING_INTERVAL":     setDuration(&c.Gate.WsPingInterval),
+		"MIN_ENTRY_EDGE":            setDecimal(&c.Strategy.MinEntryEdge),
+		"MIN_EXIT_PROFIT":           setDecimal(&c.Strategy.MinExitProfit),
+		"FEE_REFRESH_INTERVAL":      setDuration(&c.Strategy.FeeRefreshInterval),
+		"METADATA_REFRESH_INTERVAL": setDuration(&c.Strategy.MetadataRefreshInterval),
+		"EXPECTED_HOLDING":          setDuration(&c.Strategy.ExpectedHolding),
+		"FUNDING_EXIT_WINDOW":       setDuration(&c.Strategy.FundingExitWindow),
+		"NOTIONAL":                  setDecimal(&c.Strategy.Notional),
+		"LEVERAGE":                  setInt(&c.Strategy.Leverage),
+		"EXCLUDE_MARKETS":           setStringList(&c.Strategy.ExcludeMarkets),
+		"MAX_POSITIONS":             setInt(&c.Strategy.MaxPositions),
+		"MAX_TOTAL_NOTIONAL":        setDecimal(&c.Strategy.MaxTotalNotional),
+		"MAX_QUOTE_AGE":             setDuration(&c.Strategy.MaxQuoteAge),
+		"DEPTH_LEVELS":              setInt(&c.Strategy.DepthLevels),
+		"PAPER":                     setBool(&c.Paper.Enabled),
+		"PAPER_BINANCE_FEE":         setDecimal(&c.Paper.BinanceTakerFee),
+		"PAPER_GATE_FEE":            setDecimal(&c.Paper.GateTakerFee),
+		"PAPER_BINANCE_MAKER_FEE":   setDecimal(&c.Paper.BinanceMakerFee),
+		"PAPER_GATE_MAKER_FEE":      setDecimal(&c.Paper.GateMakerFee),
+		"STATE_PATH":                setString(&c.StatePath),
+		"LEDGER_PATH":               setString(&c.LedgerPath),
+		"EXECUTION_MODE":            setString(&c.Execution.Mode),
+		"MAKER_TIMEOUT":             setDuration(&c.Execution.MakerTimeout),
+		"LEG_RETRY_TIMES":           setInt(&c.Execution.LegRetryTimes),
+		"LEG_RETRY_TIMEOUT":         setDuration(&c.Execution.LegRetryTimeout),
+		"MAX_SLIPPAGE":              setDecimal(&c.Execution.MaxSlippage),
+		"UNWIND_RETRY_TIMES":        setInt(&c.Execution.UnwindRetryTimes),
+		"ALERT_WEBHOOK_URL":         setString(&c.Alert.WebhookUrl),
+		"CLOCK_SYNC_INTERVAL":       setDuration(&c.Clock.SyncInterval),
+		"CLOCK_MAX_SKEW":            setDuration(&c.Clock.MaxSkew),
+	}
+	for name, set := range setters {
+		val, ok := os.LookupEnv(envPrefix + name)
+		if !ok {
+			continue
+		}
+		if err := set(val); err != nil {
+			return fmt.Errorf("env %s%s err:%+v", envPrefix, name, err)
+		}
+	}
+	return nil
+}
+
+// Check 启动时校验配置
+func (c *Config) Check() error {
+	// 模拟盘只用公共接口，不强制要求 key
+	if !c.Paper.Enabled {
+		if c.Binance.Key == "" || c.Binance.Secret == "" {
+			return fmt.Errorf("binance key and secret are required")
+		}
+		if c.Gate.Key == "" || c.Gate.Secret == "" {
+			return fmt.Errorf("gate key and secret are required")
+		}
+	}
+	if c.Paper.BinanceTakerFee.IsNegative() || c.Paper.GateTakerFee.IsNegative() ||
+		c.Paper.BinanceMakerFee.IsNegative() || c.Paper.GateMakerFee.IsNegative() {
+		return fmt.Errorf("paper fee must not be negative")
+	}
+	if c.Binance.FapiEndpoint == "" || c.Binance.WsUrl == "" || c.Gate.WsUrl == "" {
+		return fmt.Errorf("exchange endpoints must not be empty")
+	}
+	if c.Gate.WsMaxRetry < 0 || c.Gate.WsPingInterval <= 0 {
+		return fmt.Errorf("gate ws_max_retry must not be negative and ws_ping_interval must great than 0")
+	}
+	if c.Clock.SyncInterval <= 0 || c.Clock.MaxSkew <= 0 {
+		return fmt.Errorf("clock sync_interval and max_skew must great than 0")
+	}
+	if c.StatePath == "" || c.LedgerPath == "" {
+		return fmt.Errorf("state_path and ledger_path must not be empty")
+	}
+
+	s := c.Strategy
+	if !s.MinEntryEdge.IsPositive() {
+		return fmt.Errorf("min_entry_edge must great than 0")
+	}
+	if s.MinExitProfit.IsNegative() {
+		return fmt.Errorf("min_exit_profit must not be negative")
+	}
+	if s.FeeRefreshInterval <= 0 || s.MetadataRefreshInterval <= 0 {
+		return fmt.Errorf("fee_refresh_interval and metadata_refresh_interval must great than 0")
+	}
+	if s.ExpectedHolding < 0 || s.FundingExitWindow < 0 {
+		return fmt.Errorf("expected_holding and funding_exit_window must not be negative")
+	}
+	if !s.Notional.IsPositive() {
+		return fmt.Errorf("notional must great than 0")
+	}
+	if s.Leverage < 1 || s.Leverage > 125 {
+		return fmt.Errorf("leverage must be in [1, 125]")
+	}
+	if s.MaxPositions < 1 {
+		return fmt.Errorf("max_positions must great than 0")
+	}
+	if s.MaxTotalNotional.LessThan(s.Notional) {
+		return fmt.Errorf("max_total_notional must not be less than notional")
+	}
+	if s.MaxQuoteAge <= 0 {
+		return fmt.Errorf("max_quote_age must great than 0")
+	}
+	// binance 有限档深度只支持 5、10、20 档
+	if s.DepthLevels != 5 && s.DepthLevels != 10 && s.DepthLevels != 20 {
+		return fmt.Errorf("depth_levels must be one of 5, 10, 20")
+	}
+
+	e := c.Execution
+	if e.Mode != ExecutionTaker && e.Mode != ExecutionMaker {
+		return fmt.Errorf("execution mode must be %s or %s", ExecutionTaker, ExecutionMaker)
+	}
+	if e.MakerTimeout <= 0 {
+		return fmt.Errorf("maker_timeout must great than 0")
+	}
+	if e.LegRetryTimes < 0 || e.UnwindRetryTimes < 1 {
+		return fmt.Errorf("leg_retry_times must not be negative and unwind_retry_times must great than 0")
+	}
+	if e.LegRetryTimeout <= 0 {
+		return fmt.Errorf("leg_retry_timeout must great than 0")
+	}
+	if !e.MaxSlippage.IsPositive() {
+		return fmt.Errorf("max_slippage must great than 0")
+	}
+	return nil
+}
+
+func setString(p *string) func(string) error {
+	return func(v string) error {
+		*p = v
+		return nil
+	}
+}
+
+func setStringList(p *[]string) func(string) error {
+	return func(v string) error {
+		list := make([]string, 0)
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				list = append(list, s)
+			}
+		}
+		*p = list
+		return nil
+	}
+}
+
+func setBool(p *bool) func(string) error {
+	return func(v string) error {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return err
+		}
+		*p = b
+		return nil
+	}
+}
+
+func setDecimal(p *decimal.Decimal) func(string) error {
+	return func(v string) error {
+		d, err := decimal.NewFromString(v)
+		if err != nil {
+			return err
+		}
+		*p = d
+		return nil
+	}
+}
+
+func setDuration(p *Duration) func(string) error {
+	return func(v string) error {
+		du, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		*p = Duration(du)
+		return nil
+	}
+}
+
+func setInt(p *int) func(string) error {
+	return func(v string) error {
+		i, err := strconv.Atoi(v)
+		if err != nil {
+			return err
+		}
+		*p = i
+		return nil
+	}
+}
@@ -0,0 +1,30 @@
+package config
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Duration 配置文件中以 "3s"、"1m30s" 形式书写的时长
+type Duration time.Duration
+
+func (d Duration) Duration() time.Duration {
+	return time.Duration(d)
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	du, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(du)
+	return nil
+}
@@ -0,0 +1,139 @@
+package exchange
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+)
+
+// ErrorKind 交易所错误分类，两个交易所的错误码都映射到这里
+type ErrorKind int
+
+const (
+	KindUnknown            ErrorKind = iota // 未归类，包括网络错误
+	KindInvalidKey                          // apikey 无效、签名错误、ip 或权限受限
+	KindTimestamp                           // 请求时间戳超出交易所允许的窗口
+	KindInsufficientMargin                  // 保证金或余额不足
+	KindMinNotional                         // 下单数量或名义价值低于最小值
+	KindReduceOnlyRejected                  // 只减仓单会增加仓位或超过持仓
+	KindRateLimited                         // 触发限频
+	KindUnknownOrder                        // 订单不存在或已结束
+	KindInvalidRequest                      // 参数错误，如价格精度、订单类型不支持
+	KindPostOnlyRejected                    // post only 单会立即成交被拒绝
+)
+
+func (k ErrorKind) String() string {
+	switch k {
+	case KindInvalidKey:
+		return "invalid_key"
+	case KindTimestamp:
+		return "timestamp"
+	case KindInsufficientMargin:
+		return "insufficient_margin"
+	case KindMinNotional:
+		return "min_notional"
+	case KindReduceOnlyRejected:
+		return "reduce_only_rejected"
+	case KindRateLimited:
+		return "rate_limited"
+	case KindUnknownOrder:
+		return "unknown_order"
+	case KindInvalidRequest:
+		return "invalid_request"
+	case KindPostOnlyRejected:
+		return "post_only_rejected"
+	}
+	return "unknown"
+}
+
+// Retryable 请求确定没有被交易所接受且原样重试可能成功：时间戳在校时后、限频在退避后可以重试。
+// 未归类的错误(网络错误等)无法确定交易所是否已经处理，不可直接重试，见 OutcomeUnknown
+func (k ErrorKind) Retryable() bool {
+	switch k {
+	case KindTimestamp, KindRateLimited:
+		return true
+	}
+	return false
+}
+
+// Error 交易所返回的错误，Code 为交易所原始错误码(binance 为数字，gate 为 label)
+type Error struct {
+	Venue  string
+	Kind   ErrorKind
+	Code   string
+	Msg    string
+	Status int   // http 状态码，请求未发出时为 0
+	Err    error // 原始错误
+}
+
+func (e *Error) Error() string {
+	if e.Venue == "" {
+		// 下单前本地校验的错误，没有交易所
+		return fmt.Sprintf("%s error msg:%s", e.Kind, e.Msg)
+	}
+	return fmt.Sprintf("%s %s error status:%d code:%s msg:%s", e.Venue, e.Kind, e.Status, e.Code, e.Msg)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is 与下面的哨兵错误按分类比较，如 errors.Is(err, exchange.ErrMinNotional)
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Venue == "" && t.Kind == e.Kind
+}
+
+func (e *Error) Retryable() bool {
+	return e.Kind.Retryable()
+}
+
+var (
+	ErrInvalidKey         = &Error{Kind: KindInvalidKey}
+	ErrTimestamp          = &Error{Kind: KindTimestamp}
+	ErrInsufficientMargin = &Error{Kind: KindInsufficientMargin}
+	ErrMinNotional        = &Error{Kind: KindMinNotional}
+	ErrReduceOnlyRejected = &Error{Kind: KindReduceOnlyRejected}
+	ErrRateLimited        = &Error{Kind: KindRateLimited}
+	ErrUnknownOrder       = &Error{Kind: KindUnknownOrder}
+	ErrInvalidRequest     = &Error{Kind: KindInvalidRequest}
+	ErrPostOnlyRejected   = &Error{Kind: KindPostOnlyRejected}
+)
+
+// KindOf 错误的分类，不是交易所错误时为 KindUnknown
+func KindOf(err error) ErrorKind {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+	return KindUnknown
+}
+
+// Retryable 见 ErrorKind.Retryable，err 为 nil 时返回 false
+func Retryable(err error) bool {
+	return err != nil && KindOf(err).Retryable()
+}
+
+// OutcomeUnknown 无法确定请求是否已被交易所处理：网络错误、超时、交易所内部错误(5xx)以及 2xx 响应体无法解析。
+// 下单遇到这类错误时不能直接重发，需要先按自定义订单 id 查询订单是否存在；
+// 其它错误(本地校验失败、交易所明确拒绝等)说明请求没有被处理
+func OutcomeUnknown(err error) bool {
+	if err == nil {
+		return false
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		if e.Kind != KindUnknown {
+			return false
+		}
+		if e.Status >= http.StatusInternalServerError || (e.Status >= http.StatusOK && e.Status < http.StatusMultipleChoices) {
+			return true
+		}
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
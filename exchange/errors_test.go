@@ -0,0 +1,43 @@
+package exchange
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestOutcomeUnknown(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"timeout", &url.Error{Op: "Post", URL: "https://fapi.binance.com/fapi/v1/order", Err: os.ErrDeadlineExceeded}, true},
+		{"connection reset", &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}, true},
+		{"context deadline", fmt.Errorf("place order err:%w", context.DeadlineExceeded), true},
+		{"eof", io.ErrUnexpectedEOF, true},
+		{"5xx", &Error{Venue: Binance, Status: 503, Msg: "Service Unavailable"}, true},
+		{"network error wrapped", &Error{Venue: Gate, Err: syscall.ECONNRESET}, true},
+		{"2xx body unreadable", &Error{Venue: Binance, Status: 200, Msg: "parse body"}, true},
+		{"4xx unclassified", &Error{Venue: Gate, Status: 400, Code: "SERVER_BUSY"}, false},
+		{"5xx classified", &Error{Venue: Binance, Kind: KindRateLimited, Status: 503}, false},
+		{"rejected", &Error{Venue: Binance, Kind: KindInsufficientMargin, Status: 400}, false},
+		{"local validation", &Error{Kind: KindMinNotional, Msg: "quantity too small"}, false},
+		{"local unknown kind", &Error{Venue: Gate, Msg: "no status"}, false},
+		{"plain error", errors.New("market not found"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := OutcomeUnknown(tt.err); got != tt.want {
+				t.Errorf("OutcomeUnknown(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
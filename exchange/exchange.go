@@ -0,0 +1,242 @@
+package exchange
+
+import (
+	"fmt"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	Binance = "binance"
+	Gate    = "gate"
+)
+
+type Side string
+
+const (
+	SideBuy  Side = "BUY"
+	SideSell Side = "SELL"
+)
+
+func (s Side) Opposite() Side {
+	if s == SideBuy {
+		return SideSell
+	}
+	return SideBuy
+}
+
+type MarginMode string
+
+const (
+	MarginCrossed  MarginMode = "CROSSED"
+	MarginIsolated MarginMode = "ISOLATED"
+)
+
+// Exchange 交易所抽象，数量统一为基础币数量，市场名统一为 BTC_USDT 格式
+type Exchange interface {
+	Name() string
+	PlaceOrder(req OrderRequest) (*Order, error)
+	CancelOrder(market, id string) (*Order, error)
+	GetOrder(market, id string) (*Order, error)
+	// GetOrderByClientId 按下单时的自定义订单 id 查询，订单不存在时返回 ErrUnknownOrder
+	GetOrderByClientId(market, clientId string) (*Order, error)
+	OpenOrders(market string) ([]Order, error) // market 为空时返回所有市场
+	CancelAllOrders(market string) error
+	SetLeverage(market string, leverage int) error
+	SetMarginMode(market string, mode MarginMode) error
+	GetContract(market string) (Contract, bool)
+	GetPosition(market string) (Position, error)
+	GetFeeRate(market string) (FeeRate, error)
+}
+
+// FeeRate 用户在某个市场的实际手续费率
+type FeeRate struct {
+	Maker decimal.Decimal
+	Taker decimal.Decimal
+}
+
+type Contract struct {
+	Market       string
+	Multiplier   decimal.Decimal // 一张合约对应的基础币数量
+	QuantityStep decimal.Decimal // 下单数量步长(基础币)
+	MinQuantity  decimal.Decimal // 单笔最小下单数量(基础币)，0 为不限制
+	MaxQuantity  decimal.Decimal // 单笔最大下单数量(基础币)，0 为不限制
+	MinNotional  decimal.Decimal // 单笔最小名义价值(USDT)，0 为不限制
+	PriceStep    decimal.Decimal // 价格步长
+	Tradable     bool
+}
+
+// RoundQuantity 按数量步长向下取整
+func (c Contract) RoundQuantity(quantity decimal.Decimal) decimal.Decimal {
+	if !c.QuantityStep.IsPositive() {
+		return quantity
+	}
+	return quantity.Div(c.QuantityStep).Floor().Mul(c.QuantityStep)
+}
+
+// RoundPrice 按价格步长取整到不吃单的一侧：买单向下，卖单向上
+func (c Contract) RoundPrice(price decimal.Decimal, side Side) decimal.Decimal {
+	if !c.PriceStep.IsPositive() {
+		return price
+	}
+	steps := price.Div(c.PriceStep)
+	if side == SideSell {
+		return steps.Ceil().Mul(c.PriceStep)
+	}
+	return steps.Floor().Mul(c.PriceStep)
+}
+
+type OrderType string
+
+const (
+	OrderMarket OrderType = "MARKET"
+	OrderLimit  OrderType = "LIMIT"
+)
+
+type TimeInForce string
+
+const (
+	GTC TimeInForce = "GTC"
+	IOC TimeInForce = "IOC"
+	FOK TimeInForce = "FOK"
+	GTX TimeInForce = "GTX" // post only，会立即成交时被拒绝
+)
+
+// MaxClientIdLength 自定义订单 id 最大长度，gate 的 text 需要加 "t-" 前缀且总长不超过 28
+const MaxClientIdLength = 26
+
+type OrderRequest struct {
+	Market   string
+	Side     Side
+	Quantity decimal.Decimal // ClosePosition 时忽略
+
+	Type          OrderType       // 为空时为 MARKET
+	Price         decimal.Decimal // LIMIT 单价格
+	TimeInForce   TimeInForce     // LIMIT 单为空时为 GTC；MARKET 单只能为空、IOC 或 FOK
+	ReduceOnly    bool
+	ClosePosition bool   // 按当前持仓数量全部平仓，隐含 ReduceOnly
+	ClientId      string // 自定义订单 id，字母数字和 _-. 组成
+}
+
+func (r OrderRequest) OrderType() OrderType {
+	if r.Type == "" {
+		return OrderMarket
+	}
+	return r.Type
+}
+
+func (r OrderRequest) TIF() TimeInForce {
+	if r.TimeInForce == "" && r.OrderType() == OrderLimit {
+		return GTC
+	}
+	return r.TimeInForce
+}
+
+// Check 下单前校验请求本身，不检查交易所的精度和最小下单量
+func (r OrderRequest) Check() error {
+	invalid := func(format string, args ...interface{}) error {
+		return &Error{Kind: KindInvalidRequest, Msg: fmt.Sprintf("market %s: ", r.Market) + fmt.Sprintf(format, args...)}
+	}
+	if r.Side != SideBuy && r.Side != SideSell {
+		return invalid("invalid side %q", r.Side)
+	}
+	if !r.ClosePosition && !r.Quantity.IsPositive() {
+		return invalid("invalid quantity %s", r.Quantity)
+	}
+	switch r.OrderType() {
+	case OrderMarket:
+		if !r.Price.IsZero() {
+			return invalid("market order must not have a price")
+		}
+		if r.TimeInForce != "" && r.TimeInForce != IOC && r.TimeInForce != FOK {
+			return invalid("market order does not support %s", r.TimeInForce)
+		}
+	case OrderLimit:
+		if !r.Price.IsPositive() {
+			return invalid("invalid limit price %s", r.Price)
+		}
+		switch r.TIF() {
+		case GTC, IOC, FOK, GTX:
+		default:
+			return invalid("invalid time in force %q", r.TimeInForce)
+		}
+	default:
+		return invalid("invalid order type %q", r.Type)
+	}
+	if len(r.ClientId) > MaxClientIdLength {
+		return invalid("client id %s longer than %d", r.ClientId, MaxClientIdLength)
+	}
+	for _, c := range r.ClientId {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c == '-' || c == '.') {
+			return invalid("client id %s contains invalid char %q", r.ClientId, c)
+		}
+	}
+	return nil
+}
+
+// CloseQuantity ClosePosition 或 ReduceOnly 单按当前持仓能成交的数量：方向与持仓相反，数量不超过持仓
+func (r OrderRequest) CloseQuantity(position decimal.Decimal) (decimal.Decimal, error) {
+	if (r.Side == SideSell && !position.IsPositive()) || (r.Side == SideBuy && !position.IsNegative()) {
+		return decimal.Zero, &Error{Kind: KindReduceOnlyRejected, Msg: fmt.Sprintf("market %s: %s order would not reduce position %s", r.Market, r.Side, position)}
+	}
+	if r.ClosePosition {
+		return position.Abs(), nil
+	}
+	return decimal.Min(r.Quantity, position.Abs()), nil
+}
+
+// OrderState 两个交易所统一的订单状态：new → partially_filled → filled / cancelled / rejected / expired
+type OrderState string
+
+const (
+	OrderNew             OrderState = "new"
+	OrderPartiallyFilled OrderState = "partially_filled"
+	OrderFilled          OrderState = "filled"
+	OrderCancelled       OrderState = "cancelled"
+	OrderRejected        OrderState = "rejected"
+	OrderExpired         OrderState = "expired" // IOC/FOK 未成交部分过期、post only 被拒等
+)
+
+// Final 订单已结束，不会再有成交
+func (s OrderState) Final() bool {
+	switch s {
+	case OrderFilled, OrderCancelled, OrderRejected, OrderExpired:
+		return true
+	}
+	return false
+}
+
+type Order struct {
+	Id             string
+	ClientId       string
+	Market         string
+	Side           Side
+	Quantity       decimal.Decimal
+	FilledQuantity decimal.Decimal
+	AvgPrice       decimal.Decimal
+	State          OrderState
+	Status         string // 交易所原始状态
+}
+
+type Position struct {
+	Market     string
+	Quantity   decimal.Decimal // 多仓为正，空仓为负
+	EntryPrice decimal.Decimal
+}
+
+type MarketChangeKind string
+
+const (
+	MarketListed        MarketChangeKind = "listed"  // 新上线(包括启动时首次加载)
+	MarketRemoved       MarketChangeKind = "removed" // 从合约列表中消失
+	MarketStatusChanged MarketChangeKind = "status"  // 可交易状态变化，如进入下架流程
+	MarketRulesChanged  MarketChangeKind = "rules"   // 精度、步长、下单数量限制或合约乘数变化
+)
+
+// MarketChange 刷新合约元数据时发现的变化
+type MarketChange struct {
+	Venue    string
+	Market   string
+	Kind     MarketChangeKind
+	Tradable bool // 变化后是否可交易，removed 时为 false
+}
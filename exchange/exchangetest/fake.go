@@ -0,0 +1,188 @@
+// Package exchangetest 提供按脚本返回结果的 exchange.Exchange，用于在不连接交易所的情况下测试下单流程
+package exchangetest
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/shopspring/decimal"
+	"move_profit/exchange"
+)
+
+// Result 一次下单的结果。Err 不为空时返回该错误，Accepted 为 true 时订单仍然到达交易所并成交(模拟响应超时)；
+// Partial 为 true 时只成交 Filled，否则全部成交；Ack 为 true 时响应为未成交的 new 状态，查询才能拿到成交结果
+type Result struct {
+	Err      error
+	Accepted bool
+	Partial  bool
+	Filled   decimal.Decimal
+	Ack      bool
+}
+
+// Fake 每次下单依次取 Script 中的结果，取完后全部成交；成交价为 Price，持仓按成交累计
+type Fake struct {
+	Venue       string
+	Price       decimal.Decimal
+	Contracts   map[string]exchange.Contract
+	Fee         exchange.FeeRate
+	LeverageErr error // SetLeverage 返回的错误
+	MarginErr   error // SetMarginMode 返回的错误
+
+	mu        sync.Mutex
+	script    []Result
+	requests  []exchange.OrderRequest
+	orders    map[string]exchange.Order // 交易所订单 id -> 订单
+	clientIds map[string]string         // 自定义订单 id -> 交易所订单 id
+	positions map[string]decimal.Decimal
+	seq       int
+}
+
+func NewFake(venue string, price decimal.Decimal, contracts ...exchange.Contract) *Fake {
+	f := &Fake{
+		Venue:     venue,
+		Price:     price,
+		Contracts: make(map[string]exchange.Contract),
+		orders:    make(map[string]exchange.Order),
+		clientIds: make(map[string]string),
+		positions: make(map[string]decimal.Decimal),
+	}
+	for _, c := range contracts {
+		f.Contracts[c.Market] = c
+	}
+	return f
+}
+
+// Script 追加之后下单的结果
+func (f *Fake) Script(results ...Result) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.script = append(f.script, results...)
+}
+
+// Requests 收到的所有下单请求，包括返回错误的
+func (f *Fake) Requests() []exchange.OrderRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]exchange.OrderRequest{}, f.requests...)
+}
+
+// Position 按成交累计的持仓，多仓为正
+func (f *Fake) Position(market string) decimal.Decimal {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.positions[market]
+}
+
+func (f *Fake) Name() string {
+	return f.Venue
+}
+
+func (f *Fake) PlaceOrder(req exchange.OrderRequest) (*exchange.Order, error) {
+	if err := req.Check(); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.requests = append(f.requests, req)
+	res := Result{}
+	if len(f.script) > 0 {
+		res, f.script = f.script[0], f.script[1:]
+	}
+	if res.Err != nil && !res.Accepted {
+		return nil, res.Err
+	}
+
+	filled := req.Quantity
+	if res.Partial {
+		filled = res.Filled
+	}
+	f.seq++
+	o := exchange.Order{
+		Id:             fmt.Sprintf("%s-%d", f.Venue, f.seq),
+		ClientId:       req.ClientId,
+		Market:         req.Market,
+		Side:           req.Side,
+		Quantity:       req.Quantity,
+		FilledQuantity: filled,
+		State:          exchange.OrderFilled,
+	}
+	if filled.LessThan(req.Quantity) {
+		o.State = exchange.OrderExpired
+	}
+	if filled.IsPositive() {
+		o.AvgPrice = f.Price
+		if req.Side == exchange.SideSell {
+			filled = filled.Neg()
+		}
+		f.positions[req.Market] = f.positions[req.Market].Add(filled)
+	}
+	f.orders[o.Id] = o
+	if req.ClientId != "" {
+		f.clientIds[req.ClientId] = o.Id
+	}
+	if res.Err != nil {
+		return nil, res.Err
+	}
+	if res.Ack {
+		o.State, o.FilledQuantity, o.AvgPrice = exchange.OrderNew, decimal.Zero, decimal.Zero
+	}
+	return &o, nil
+}
+
+func (f *Fake) CancelOrder(market, id string) (*exchange.Order, error) {
+	return nil, &exchange.Error{Venue: f.Venue, Kind: exchange.KindUnknownOrder, Msg: fmt.Sprintf("order %s finished", id)}
+}
+
+func (f *Fake) GetOrder(market, id string) (*exchange.Order, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	o, ok := f.orders[id]
+	if !ok {
+		return nil, &exchange.Error{Venue: f.Venue, Kind: exchange.KindUnknownOrder, Msg: fmt.Sprintf("order %s not found", id)}
+	}
+	return &o, nil
+}
+
+func (f *Fake) GetOrderByClientId(market, clientId string) (*exchange.Order, error) {
+	f.mu.Lock()
+	id, ok := f.clientIds[clientId]
+	f.mu.Unlock()
+	if !ok {
+		return nil, &exchange.Error{Venue: f.Venue, Kind: exchange.KindUnknownOrder, Msg: fmt.Sprintf("order %s not found", clientId)}
+	}
+	return f.GetOrder(market, id)
+}
+
+func (f *Fake) OpenOrders(market string) ([]exchange.Order, error) {
+	return nil, nil
+}
+
+func (f *Fake) CancelAllOrders(market string) error {
+	return nil
+}
+
+func (f *Fake) SetLeverage(market string, leverage int) error {
+	return f.LeverageErr
+}
+
+func (f *Fake) SetMarginMode(market string, mode exchange.MarginMode) error {
+	return f.MarginErr
+}
+
+func (f *Fake) GetContract(market string) (exchange.Contract, bool) {
+	c, ok := f.Contracts[market]
+	return c, ok
+}
+
+func (f *Fake) GetPosition(market string) (exchange.Position, error) {
+	return exchange.Position{Market: market, Quantity: f.Position(market), EntryPrice: f.Price}, nil
+}
+
+func (f *Fake) GetFeeRate(market string) (exchange.FeeRate, error) {
+	return f.Fee, nil
+}
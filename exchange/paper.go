@@ -0,0 +1,392 @@
+package exchange
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// QuoteFunc 返回市场当前按方向可成交的价格：买入为卖一价，卖出为买一价
+type QuoteFunc func(market string, side Side) (decimal.Decimal, bool)
+
+// PaperExchange 模拟撮合：按当前报价全部成交并扣除 taker 手续费，挂单成交扣除 maker 手续费，
+// 只记录虚拟仓位和盈亏，不发送真实订单。合约元数据仍然取自真实交易所
+type PaperExchange struct {
+	inner Exchange
+	quote QuoteFunc
+	fee   FeeRate
+
+	mu          sync.Mutex
+	orderId     int64
+	positions   map[string]*Position
+	resting     map[string]*restingOrder
+	orders      map[string]Order // 最近的订单，供 GetOrder 查询
+	orderIds    []string         // 按下单顺序，用于淘汰
+	realizedPnl decimal.Decimal
+	fees        decimal.Decimal
+	onUpdate    func(o Order, final bool)
+}
+
+// restingOrder 未成交的 GTC/GTX 限价单，对手价穿过挂单价时按挂单价全部成交
+type restingOrder struct {
+	order Order
+	req   OrderRequest
+	seq   int64 // 订单 id 中的序号
+}
+
+func NewPaperExchange(inner Exchange, quote QuoteFunc, fee FeeRate) *PaperExchange {
+	return &PaperExchange{
+		inner:     inner,
+		quote:     quote,
+		fee:       fee,
+		positions: make(map[string]*Position),
+		resting:   make(map[string]*restingOrder),
+		orders:    make(map[string]Order),
+	}
+}
+
+// 模拟盘最多保留的订单数量
+const maxPaperOrders = 1000
+
+var paperStatus = map[OrderState]string{
+	OrderNew:       "NEW",
+	OrderFilled:    "FILLED",
+	OrderCancelled: "CANCELED",
+	OrderExpired:   "EXPIRED",
+}
+
+func setState(o *Order, state OrderState) {
+	o.State = state
+	o.Status = paperStatus[state]
+}
+
+// OnOrderUpdate 订单状态变化时回调，相当于实盘的私有频道推送，用于把模拟订单写入本地账户
+func (p *PaperExchange) OnOrderUpdate(f func(o Order, final bool)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.onUpdate = f
+}
+
+// update 记录订单最新状态并回调
+func (p *PaperExchange) update(o Order) {
+	p.mu.Lock()
+	if _, ok := p.orders[o.Id]; !ok {
+		p.orderIds = append(p.orderIds, o.Id)
+		if len(p.orderIds) > maxPaperOrders {
+			delete(p.orders, p.orderIds[0])
+			p.orderIds = p.orderIds[1:]
+		}
+	}
+	p.orders[o.Id] = o
+	f := p.onUpdate
+	p.mu.Unlock()
+	if f != nil {
+		f(o, o.State.Final())
+	}
+}
+
+func (p *PaperExchange) Name() string {
+	return p.inner.Name()
+}
+
+// PlaceOrder 市价单和可立即成交的限价单按当前报价全部成交；不能立即成交的 IOC/FOK 单过期，
+// GTC/GTX 单返回 NEW 并挂单，由 Run 按盘口撮合
+func (p *PaperExchange) PlaceOrder(req OrderRequest) (*Order, error) {
+	order, err := p.placeOrder(req)
+	if err != nil {
+		return nil, err
+	}
+	p.update(*order)
+	return order, nil
+}
+
+func (p *PaperExchange) placeOrder(req OrderRequest) (*Order, error) {
+	if err := req.Check(); err != nil {
+		return nil, err
+	}
+	price, ok := p.quote(req.Market, req.Side)
+	if !ok || !price.IsPositive() {
+		return nil, &Error{Venue: p.Name(), Kind: KindInvalidRequest, Msg: fmt.Sprintf("paper market %s has no price", req.Market)}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.orderId++
+	order := &Order{
+		Id:       fmt.Sprintf("paper-%d", p.orderId),
+		ClientId: req.ClientId,
+		Market:   req.Market,
+		Side:     req.Side,
+		Quantity: req.Quantity,
+	}
+	setState(order, OrderNew)
+	if req.ReduceOnly || req.ClosePosition {
+		var position decimal.Decimal
+		if pos, ok := p.positions[req.Market]; ok {
+			position = pos.Quantity
+		}
+		quantity, err := req.CloseQuantity(position)
+		if err != nil {
+			return nil, err
+		}
+		order.Quantity = quantity
+	}
+	if req.OrderType() == OrderLimit {
+		marketable := req.Price.GreaterThanOrEqual(price)
+		if req.Side == SideSell {
+			marketable = req.Price.LessThanOrEqual(price)
+		}
+		switch {
+		case marketable && req.TIF() == GTX:
+			return nil, &Error{Venue: p.Name(), Kind: KindPostOnlyRejected, Msg: fmt.Sprintf("paper market %s limit %s would take %s", req.Market, req.Price, price)}
+		case !marketable && (req.TIF() == IOC || req.TIF() == FOK):
+			setState(order, OrderExpired)
+			return order, nil
+		case !marketable:
+			p.resting[order.Id] = &restingOrder{order: *order, req: req, seq: p.orderId}
+			return order, nil
+		}
+	}
+
+	quantity := order.Quantity
+	if req.Side == SideSell {
+		quantity = quantity.Neg()
+	}
+	p.fill(req.Market, quantity, price)
+	p.fees = p.fees.Add(order.Quantity.Mul(price).Mul(p.fee.Taker))
+	order.FilledQuantity = order.Quantity
+	order.AvgPrice = price
+	setState(order, OrderFilled)
+	return order, nil
+}
+
+// CancelOrder 只能撤销挂单，已成交或已撤销的订单返回 ErrUnknownOrder
+func (p *PaperExchange) CancelOrder(market, id string) (*Order, error) {
+	p.mu.Lock()
+	r, ok := p.resting[id]
+	if ok {
+		delete(p.resting, id)
+		setState(&r.order, OrderCancelled)
+	}
+	p.mu.Unlock()
+	if !ok {
+		return nil, &Error{Venue: p.Name(), Kind: KindUnknownOrder, Msg: fmt.Sprintf("paper order %s not found", id)}
+	}
+	p.update(r.order)
+	return &r.order, nil
+}
+
+func (p *PaperExchange) GetOrder(market, id string) (*Order, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	o, ok := p.orders[id]
+	if !ok {
+		return nil, &Error{Venue: p.Name(), Kind: KindUnknownOrder, Msg: fmt.Sprintf("paper order %s not found", id)}
+	}
+	return &o, nil
+}
+
+// GetOrderByClientId 只能查到保留的最近订单，同一个自定义 id 有多笔时返回最新的一笔
+func (p *PaperExchange) GetOrderByClientId(market, clientId string) (*Order, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for i := len(p.orderIds) - 1; i >= 0; i-- {
+		if o, ok := p.orders[p.orderIds[i]]; ok && o.ClientId == clientId {
+			return &o, nil
+		}
+	}
+	return nil, &Error{Venue: p.Name(), Kind: KindUnknownOrder, Msg: fmt.Sprintf("paper order %s not found", clientId)}
+}
+
+// OpenOrders 未成交的挂单，按下单顺序排序(订单 id 按字符串比较时 paper-10 会排在 paper-9 之前)
+func (p *PaperExchange) OpenOrders(market string) ([]Order, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	resting := make([]*restingOrder, 0, len(p.resting))
+	for _, r := range p.resting {
+		if market == "" || r.order.Market == market {
+			resting = append(resting, r)
+		}
+	}
+	sort.Slice(resting, func(i, j int) bool {
+		return resting[i].seq < resting[j].seq
+	})
+	list := make([]Order, 0, len(resting))
+	for _, r := range resting {
+		list = append(list, r.order)
+	}
+	return list, nil
+}
+
+func (p *PaperExchange) CancelAllOrders(market string) error {
+	list, _ := p.OpenOrders(market)
+	for _, o := range list {
+		// 撤单期间可能已经成交，忽略
+		p.CancelOrder(o.Market, o.Id)
+	}
+	return nil
+}
+
+// Run 按 interval 用当前盘口撮合挂单：买单在卖一价不高于挂单价、卖单在买一价不低于挂单价时按挂单价全部成交
+func (p *PaperExchange) Run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		for _, o := range p.match() {
+			p.update(o)
+		}
+	}
+}
+
+func (p *PaperExchange) match() []Order {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var updates []Order
+	for id, r := range p.resting {
+		price, ok := p.quote(r.req.Market, r.req.Side)
+		if !ok {
+			continue
+		}
+		if (r.req.Side == SideBuy && price.GreaterThan(r.req.Price)) || (r.req.Side == SideSell && price.LessThan(r.req.Price)) {
+			continue
+		}
+		delete(p.resting, id)
+		quantity := r.order.Quantity
+		if r.req.ReduceOnly {
+			var position decimal.Decimal
+			if pos, ok := p.positions[r.req.Market]; ok {
+				position = pos.Quantity
+			}
+			var err error
+			if quantity, err = r.req.CloseQuantity(position); err != nil {
+				// 仓位已经平掉，只减仓单过期
+				setState(&r.order, OrderExpired)
+				updates = append(updates, r.order)
+				continue
+			}
+		}
+		signed := quantity
+		if r.req.Side == SideSell {
+			signed = signed.Neg()
+		}
+		p.fill(r.req.Market, signed, r.req.Price)
+		p.fees = p.fees.Add(quantity.Mul(r.req.Price).Mul(p.fee.Maker))
+		r.order.FilledQuantity = quantity
+		r.order.AvgPrice = r.req.Price
+		setState(&r.order, OrderFilled)
+		updates = append(updates, r.order)
+	}
+	return updates
+}
+
+// fill 按均价法更新虚拟仓位，平仓部分计入已实现盈亏
+func (p *PaperExchange) fill(market string, quantity, price decimal.Decimal) {
+	pos, ok := p.positions[market]
+	if !ok {
+		pos = &Position{Market: market}
+		p.positions[market] = pos
+	}
+
+	if pos.Quantity.IsZero() || pos.Quantity.Sign() == quantity.Sign() {
+		total := pos.Quantity.Abs().Add(quantity.Abs())
+		pos.EntryPrice = pos.Quantity.Abs().Mul(pos.EntryPrice).Add(quantity.Abs().Mul(price)).Div(total)
+		pos.Quantity = pos.Quantity.Add(quantity)
+		return
+	}
+
+	closed := decimal.Min(pos.Quantity.Abs(), quantity.Abs())
+	pnl := closed.Mul(price.Sub(pos.EntryPrice))
+	if pos.Quantity.IsNegative() {
+		pnl = pnl.Neg()
+	}
+	p.realizedPnl = p.realizedPnl.Add(pnl)
+
+	remain := pos.Quantity.Add(quantity)
+	switch {
+	case remain.IsZero():
+		pos.EntryPrice = decimal.Zero
+	case remain.Sign() != pos.Quantity.Sign():
+		// 反手，剩余部分以成交价开仓
+		pos.EntryPrice = price
+	}
+	pos.Quantity = remain
+}
+
+func (p *PaperExchange) SetLeverage(market string, leverage int) error {
+	return nil
+}
+
+func (p *PaperExchange) SetMarginMode(market string, mode MarginMode) error {
+	return nil
+}
+
+func (p *PaperExchange) GetContract(market string) (Contract, bool) {
+	return p.inner.GetContract(market)
+}
+
+func (p *PaperExchange) GetPosition(market string) (Position, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	pos, ok := p.positions[market]
+	if !ok {
+		return Position{Market: market}, nil
+	}
+	return *pos, nil
+}
+
+// GetFeeRate 模拟盘按配置的费率计算
+func (p *PaperExchange) GetFeeRate(market string) (FeeRate, error) {
+	return p.fee, nil
+}
+
+// PnL 返回已实现盈亏(不含手续费)、累计手续费、按当前平仓价计算的浮动盈亏
+func (p *PaperExchange) PnL() (realized, fees, unrealized decimal.Decimal) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for market, pos := range p.positions {
+		if pos.Quantity.IsZero() {
+			continue
+		}
+		closeSide := SideSell
+		if pos.Quantity.IsNegative() {
+			closeSide = SideBuy
+		}
+		price, ok := p.quote(market, closeSide)
+		if !ok {
+			continue
+		}
+		unrealized = unrealized.Add(pos.Quantity.Mul(price.Sub(pos.EntryPrice)))
+	}
+	return p.realizedPnl, p.fees, unrealized
+}
+
+// Report 虚拟账户摘要，用于定时打日志
+func (p *PaperExchange) Report() string {
+	realized, fees, unrealized := p.PnL()
+
+	p.mu.Lock()
+	markets := make([]string, 0, len(p.positions))
+	for market, pos := range p.positions {
+		if !pos.Quantity.IsZero() {
+			markets = append(markets, fmt.Sprintf("%s:%s@%s", market, pos.Quantity, pos.EntryPrice.Truncate(8)))
+		}
+	}
+	p.mu.Unlock()
+	sort.Strings(markets)
+
+	return fmt.Sprintf("[paper %s] realized:%s fees:%s unrealized:%s net:%s positions:[%s]",
+		p.Name(), realized.Truncate(6), fees.Truncate(6), unrealized.Truncate(6),
+		realized.Sub(fees).Add(unrealized).Truncate(6), strings.Join(markets, " "))
+}
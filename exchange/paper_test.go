@@ -0,0 +1,161 @@
+package exchange
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func dec(s string) decimal.Decimal {
+	return decimal.RequireFromString(s)
+}
+
+// stubExchange 模拟盘只用到真实交易所的名称
+type stubExchange struct {
+	Exchange
+}
+
+func (stubExchange) Name() string {
+	return Binance
+}
+
+// book 可变的买一卖一价
+type book struct {
+	bid, ask decimal.Decimal
+}
+
+func (b *book) quote(market string, side Side) (decimal.Decimal, bool) {
+	if side == SideBuy {
+		return b.ask, true
+	}
+	return b.bid, true
+}
+
+func newPaper(b *book) *PaperExchange {
+	return NewPaperExchange(stubExchange{}, b.quote, FeeRate{Maker: dec("0.0002"), Taker: dec("0.0005")})
+}
+
+func TestPaperFill(t *testing.T) {
+	const market = "BTC_USDT"
+	buy := func(quantity string) OrderRequest {
+		return OrderRequest{Market: market, Side: SideBuy, Quantity: dec(quantity)}
+	}
+	sell := func(quantity string) OrderRequest {
+		return OrderRequest{Market: market, Side: SideSell, Quantity: dec(quantity)}
+	}
+	limit := func(req OrderRequest, price string) OrderRequest {
+		req.Type, req.Price = OrderLimit, dec(price)
+		return req
+	}
+	reduceOnly := func(req OrderRequest) OrderRequest {
+		req.ReduceOnly = true
+		return req
+	}
+	// step 先把盘口设为 bid/ask，req 为空时只撮合挂单
+	type step struct {
+		bid, ask string
+		req      OrderRequest
+	}
+	tests := []struct {
+		name       string
+		steps      []step
+		position   string
+		entryPrice string
+		realized   string
+		fees       string
+		open       int
+	}{
+		{
+			name:     "taker buy",
+			steps:    []step{{"99", "100", buy("2")}},
+			position: "2", entryPrice: "100", realized: "0", fees: "0.1",
+		},
+		{
+			name:     "taker round trip",
+			steps:    []step{{"99", "100", buy("2")}, {"110", "111", sell("2")}},
+			position: "0", entryPrice: "0", realized: "20", fees: "0.21",
+		},
+		{
+			name:     "average entry",
+			steps:    []step{{"99", "100", buy("1")}, {"109", "110", buy("3")}},
+			position: "4", entryPrice: "107.5", realized: "0", fees: "0.215",
+		},
+		{
+			name:     "reverse",
+			steps:    []step{{"99", "100", buy("2")}, {"110", "111", sell("3")}},
+			position: "-1", entryPrice: "110", realized: "20", fees: "0.265",
+		},
+		{
+			name:     "marketable limit takes at quote",
+			steps:    []step{{"99", "100", limit(buy("1"), "101")}},
+			position: "1", entryPrice: "100", realized: "0", fees: "0.05",
+		},
+		{
+			name:     "resting limit waits",
+			steps:    []step{{"99", "100", limit(buy("1"), "95")}, {"96", "97", OrderRequest{}}},
+			position: "0", entryPrice: "0", realized: "0", fees: "0", open: 1,
+		},
+		{
+			name:     "resting limit fills as maker",
+			steps:    []step{{"99", "100", limit(buy("1"), "95")}, {"93", "94", OrderRequest{}}},
+			position: "1", entryPrice: "95", realized: "0", fees: "0.019",
+		},
+		{
+			name: "resting reduce only capped by position",
+			steps: []step{
+				{"99", "100", buy("1")},
+				{"99", "100", reduceOnly(limit(sell("1"), "105"))},
+				{"99", "100", sell("0.4")},
+				{"106", "107", OrderRequest{}},
+			},
+			position: "0", entryPrice: "0", realized: "2.6", fees: "0.0824",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &book{}
+			p := newPaper(b)
+			for i, s := range tt.steps {
+				b.bid, b.ask = dec(s.bid), dec(s.ask)
+				if s.req.Market == "" {
+					p.match()
+					continue
+				}
+				if _, err := p.PlaceOrder(s.req); err != nil {
+					t.Fatalf("step %d err:%v", i, err)
+				}
+			}
+			pos, _ := p.GetPosition(market)
+			realized, fees, _ := p.PnL()
+			if !pos.Quantity.Equal(dec(tt.position)) || !pos.EntryPrice.Equal(dec(tt.entryPrice)) {
+				t.Errorf("position = %s@%s, want %s@%s", pos.Quantity, pos.EntryPrice, tt.position, tt.entryPrice)
+			}
+			if !realized.Equal(dec(tt.realized)) || !fees.Equal(dec(tt.fees)) {
+				t.Errorf("realized:%s fees:%s, want %s %s", realized, fees, tt.realized, tt.fees)
+			}
+			if list, _ := p.OpenOrders(""); len(list) != tt.open {
+				t.Errorf("open orders = %d, want %d", len(list), tt.open)
+			}
+		})
+	}
+}
+
+func TestPaperOpenOrders(t *testing.T) {
+	p := newPaper(&book{bid: dec("99"), ask: dec("100")})
+	for i := 0; i < 12; i++ {
+		req := OrderRequest{Market: "BTC_USDT", Side: SideBuy, Quantity: dec("1"), Type: OrderLimit, Price: dec("90")}
+		if _, err := p.PlaceOrder(req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	list, err := p.OpenOrders("BTC_USDT")
+	if err != nil || len(list) != 12 {
+		t.Fatalf("open orders = %d err:%v", len(list), err)
+	}
+	for i, o := range list {
+		if want := fmt.Sprintf("paper-%d", i+1); o.Id != want {
+			t.Errorf("open order %d = %s, want %s", i, o.Id, want)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package fee
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"move_profit/exchange"
+	"move_profit/log"
+)
+
+// Model 缓存用户在各交易所各市场的实际手续费率。
+// 首次查询某个市场时同步拉取，之后由后台按 refreshInterval 刷新已查询过的市场
+// 查询失败后在该时长内不再向交易所查询，避免每次行情都打接口
+const retryAfterFail = time.Minute
+
+type Model struct {
+	exchanges       map[string]exchange.Exchange
+	refreshInterval time.Duration
+
+	mu       sync.RWMutex
+	rates    map[string]map[string]exchange.FeeRate // venue -> market -> rate
+	failTime map[string]time.Time                   // venue:market -> 最近一次查询失败的时间
+}
+
+func NewModel(refreshInterval time.Duration, exs ...exchange.Exchange) *Model {
+	m := &Model{
+		exchanges:       make(map[string]exchange.Exchange),
+		refreshInterval: refreshInterval,
+		rates:           make(map[string]map[string]exchange.FeeRate),
+		failTime:        make(map[string]time.Time),
+	}
+	for _, ex := range exs {
+		m.exchanges[ex.Name()] = ex
+		m.rates[ex.Name()] = make(map[string]exchange.FeeRate)
+	}
+	return m
+}
+
+// Get 返回费率，缓存中没有时向交易所查询
+func (m *Model) Get(venue, market string) (exchange.FeeRate, error) {
+	m.mu.RLock()
+	rate, ok := m.rates[venue][market]
+	failTime := m.failTime[venue+":"+market]
+	m.mu.RUnlock()
+	if ok {
+		return rate, nil
+	}
+	if time.Since(failTime) < retryAfterFail {
+		return exchange.FeeRate{}, fmt.Errorf("%s fee of %s unavailable", venue, market)
+	}
+	return m.fetch(venue, market)
+}
+
+func (m *Model) fetch(venue, market string) (exchange.FeeRate, error) {
+	ex, ok := m.exchanges[venue]
+	if !ok {
+		return exchange.FeeRate{}, fmt.Errorf("fee model unknown venue %s", venue)
+	}
+	rate, err := ex.GetFeeRate(market)
+	if err == nil && rate.Taker.IsNegative() {
+		err = fmt.Errorf("invalid taker:%s", rate.Taker)
+	}
+	if err != nil {
+		m.mu.Lock()
+		m.failTime[venue+":"+market] = time.Now()
+		m.mu.Unlock()
+		return exchange.FeeRate{}, fmt.Errorf("get %s fee of %s err:%+v", venue, market, err)
+	}
+
+	m.mu.Lock()
+	old, ok := m.rates[venue][market]
+	m.rates[venue][market] = rate
+	delete(m.failTime, venue+":"+market)
+	m.mu.Unlock()
+
+	if !ok || !old.Taker.Equal(rate.Taker) || !old.Maker.Equal(rate.Maker) {
+		log.Log.Infof("[fee] %s %s maker:%s taker:%s", venue, market, rate.Maker, rate.Taker)
+	}
+	return rate, nil
+}
+
+// TakerRoundTrip 两边各吃单开仓一次、平仓一次的总手续费率
+func (m *Model) TakerRoundTrip(market string) (decimal.Decimal, error) {
+	total := decimal.Zero
+	for venue := range m.exchanges {
+		rate, err := m.Get(venue, market)
+		if err != nil {
+			return decimal.Zero, err
+		}
+		total = total.Add(rate.Taker.Mul(decimal.NewFromInt(2)))
+	}
+	return total, nil
+}
+
+// MakerTakerRoundTrip makerVenue 挂单开仓、另一边吃单开仓，平仓时两边都吃单的总手续费率
+func (m *Model) MakerTakerRoundTrip(market, makerVenue string) (decimal.Decimal, error) {
+	total := decimal.Zero
+	for venue := range m.exchanges {
+		rate, err := m.Get(venue, market)
+		if err != nil {
+			return decimal.Zero, err
+		}
+		open := rate.Taker
+		if venue == makerVenue {
+			open = rate.Maker
+		}
+		total = total.Add(open).Add(rate.Taker)
+	}
+	return total, nil
+}
+
+// Run 定时刷新已缓存的费率，刷新失败时保留旧值
+func (m *Model) Run() {
+	ticker := time.NewTicker(m.refreshInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		m.refresh()
+	}
+}
+
+func (m *Model) refresh() {
+	for venue := range m.exchanges {
+		m.mu.RLock()
+		markets := make([]string, 0, len(m.rates[venue]))
+		for market := range m.rates[venue] {
+			markets = append(markets, market)
+		}
+		m.mu.RUnlock()
+
+		for _, market := range markets {
+			if _, err := m.fetch(venue, market); err != nil {
+				log.Log.Errorf("[fee] refresh err:%+v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package fee
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/op/go-logging"
+	"github.com/shopspring/decimal"
+	"move_profit/exchange"
+	"move_profit/exchange/exchangetest"
+	"move_profit/log"
+)
+
+func TestMain(m *testing.M) {
+	log.Log = logging.MustGetLogger("test")
+	logging.SetLevel(logging.CRITICAL, "")
+	os.Exit(m.Run())
+}
+
+func dec(s string) decimal.Decimal {
+	return decimal.RequireFromString(s)
+}
+
+// feeExchange 记录费率查询次数，err 不为空时查询失败
+type feeExchange struct {
+	*exchangetest.Fake
+	err   error
+	calls int
+}
+
+func (e *feeExchange) GetFeeRate(market string) (exchange.FeeRate, error) {
+	e.calls++
+	if e.err != nil {
+		return exchange.FeeRate{}, e.err
+	}
+	return e.Fake.GetFeeRate(market)
+}
+
+func newExchange(venue, maker, taker string) *feeExchange {
+	f := exchangetest.NewFake(venue, dec("100"))
+	f.Fee = exchange.FeeRate{Maker: dec(maker), Taker: dec(taker)}
+	return &feeExchange{Fake: f}
+}
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name         string
+		binance      [2]string // maker, taker
+		gate         [2]string
+		binanceErr   error
+		taker        string
+		makerBinance string
+		makerGate    string
+		wantErr      bool
+	}{
+		{name: "same rates", binance: [2]string{"0.0002", "0.0005"}, gate: [2]string{"0.0002", "0.0005"}, taker: "0.002", makerBinance: "0.0017", makerGate: "0.0017"},
+		{name: "different rates", binance: [2]string{"0.00018", "0.00045"}, gate: [2]string{"0.00015", "0.0005"}, taker: "0.0019", makerBinance: "0.00163", makerGate: "0.00155"},
+		// maker 返佣为负费率，降低挂单开仓的成本
+		{name: "maker rebate", binance: [2]string{"0.0002", "0.0005"}, gate: [2]string{"-0.00005", "0.0005"}, taker: "0.002", makerBinance: "0.0017", makerGate: "0.00145"},
+		{name: "zero fee", binance: [2]string{"0", "0"}, gate: [2]string{"0", "0"}, taker: "0", makerBinance: "0", makerGate: "0"},
+		{name: "negative taker", binance: [2]string{"0.0002", "-0.0001"}, gate: [2]string{"0.0002", "0.0005"}, wantErr: true},
+		{name: "fetch failed", binance: [2]string{"0.0002", "0.0005"}, gate: [2]string{"0.0002", "0.0005"}, binanceErr: errors.New("timeout"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			binance := newExchange(exchange.Binance, tt.binance[0], tt.binance[1])
+			binance.err = tt.binanceErr
+			gate := newExchange(exchange.Gate, tt.gate[0], tt.gate[1])
+			m := NewModel(time.Minute, binance, gate)
+
+			taker, err := m.TakerRoundTrip("BTC_USDT")
+			makerBinance, errBinance := m.MakerTakerRoundTrip("BTC_USDT", exchange.Binance)
+			makerGate, errGate := m.MakerTakerRoundTrip("BTC_USDT", exchange.Gate)
+			if tt.wantErr {
+				if err == nil || errBinance == nil || errGate == nil {
+					t.Errorf("err = %v %v %v, want errors", err, errBinance, errGate)
+				}
+				return
+			}
+			if err != nil || errBinance != nil || errGate != nil {
+				t.Fatalf("err = %v %v %v", err, errBinance, errGate)
+			}
+			if !taker.Equal(dec(tt.taker)) {
+				t.Errorf("taker round trip = %s, want %s", taker, tt.taker)
+			}
+			if !makerBinance.Equal(dec(tt.makerBinance)) || !makerGate.Equal(dec(tt.makerGate)) {
+				t.Errorf("maker round trip binance:%s gate:%s, want %s %s", makerBinance, makerGate, tt.makerBinance, tt.makerGate)
+			}
+		})
+	}
+}
+
+func TestGet(t *testing.T) {
+	binance := newExchange(exchange.Binance, "0.0002", "0.0005")
+	m := NewModel(time.Minute, binance)
+
+	// 首次查询后使用缓存
+	for i := 0; i < 3; i++ {
+		if rate, err := m.Get(exchange.Binance, "BTC_USDT"); err != nil || !rate.Taker.Equal(dec("0.0005")) {
+			t.Fatalf("rate = %+v err:%v", rate, err)
+		}
+	}
+	if binance.calls != 1 {
+		t.Errorf("calls = %d, want 1", binance.calls)
+	}
+
+	// 查询失败后一段时间内不再查询
+	binance.err = errors.New("timeout")
+	for i := 0; i < 3; i++ {
+		if _, err := m.Get(exchange.Binance, "ETH_USDT"); err == nil {
+			t.Fatal("failed fetch returned rate")
+		}
+	}
+	if binance.calls != 2 {
+		t.Errorf("calls after failure = %d, want 2", binance.calls)
+	}
+
+	// 刷新失败时保留旧值，成功时更新
+	m.refresh()
+	if rate, err := m.Get(exchange.Binance, "BTC_USDT"); err != nil || !rate.Taker.Equal(dec("0.0005")) {
+		t.Errorf("rate after failed refresh = %+v err:%v", rate, err)
+	}
+	binance.err = nil
+	binance.Fee.Taker = dec("0.0004")
+	m.refresh()
+	if rate, err := m.Get(exchange.Binance, "BTC_USDT"); err != nil || !rate.Taker.Equal(dec("0.0004")) {
+		t.Errorf("rate after refresh = %+v err:%v", rate, err)
+	}
+
+	if _, err := m.Get(exchange.Gate, "BTC_USDT"); err == nil {
+		t.Error("unknown venue returned rate")
+	}
+}
@@ -5,7 +5,10 @@ import (
 	"fmt"
 	"github.com/antihax/optional"
 	gateapi "github.com/gateio/gateapi-go/v6"
+	"move_profit/exchange"
+	"move_profit/ratelimit"
 	"net/http"
+	"sort"
 	"sync"
 	"time"
 )
@@ -14,12 +17,9 @@ var client *gateapi.APIClient
 
 var gateMarketInfoMap sync.Map
 
-func InitGateClient() {
-	client = getGateApiClient()
-	gateMarketInfoList, _ := GetGateMarketInfo()
-	for _, contract := range gateMarketInfoList {
-		gateMarketInfoMap.Store(contract.Name, contract)
-	}
+// InitGateClient 只创建客户端，合约元数据通过 RefreshMarketInfo 加载
+func InitGateClient(apiKey, apiSecret string) {
+	client = getGateApiClient(apiKey, apiSecret)
 }
 
 func GetMarketInfo(market string) (gateapi.Contract, bool) {
@@ -30,62 +30,150 @@ func GetMarketInfo(market string) (gateapi.Contract, bool) {
 	return val.(gateapi.Contract), true
 }
 
-func GetGateMarketInfo() ([]gateapi.Contract, error) {
-	ctx := context.WithValue(context.Background(), gateapi.ContextGateAPIV4, gateapi.GateAPIV4{
-		Key:    "f6f3cc50911e00ae4ff6a5dbb1913a5e",
-		Secret: "8ea94f2850ad01d1da565e5c2ef6369e9667cb18209f676120857d5bc8f42c34",
+func GetMarketList() []string {
+	list := make([]string, 0)
+	gateMarketInfoMap.Range(func(key, value any) bool {
+		list = append(list, key.(string))
+		return true
 	})
+	sort.Strings(list)
+	return list
+}
+
+func GetGateMarketInfo() ([]gateapi.Contract, error) {
+	ctx := context.Background()
 	contractList, _, err := client.FuturesApi.ListFuturesContracts(ctx, "usdt")
 	if err != nil {
-		return nil, err
+		return nil, wrapError(err)
 	}
 	return contractList, nil
 }
 
-func getGateApiClient() *gateapi.APIClient {
+func getGateApiClient(apiKey, apiSecret string) *gateapi.APIClient {
 	cfg := gateapi.NewConfiguration()
-	cfg.HTTPClient = &http.Client{Timeout: 60 * time.Second}
+	cfg.Key = apiKey
+	cfg.Secret = apiSecret
+	cfg.HTTPClient = &http.Client{
+		Timeout:   60 * time.Second,
+		Transport: ratelimit.Get(exchange.Gate).Transport(&signTransport{secret: apiSecret, base: http.DefaultTransport}),
+	}
 	return gateapi.NewAPIClient(cfg)
 }
 
-func PlaceExchagneOrder(market string, size int) (gateapi.FuturesOrder, error) {
-	reqOrder := gateapi.FuturesOrder{
-		Contract: market,
-		Price:    "0",
-		Size:     int64(size),
-		Tif:      "ioc",
+// ServerTime gate 服务器时间，用于时钟同步
+func ServerTime() (time.Time, error) {
+	res, _, err := client.SpotApi.GetSystemTime(context.Background())
+	if err != nil {
+		return time.Time{}, wrapError(err)
 	}
-	ctx := context.WithValue(context.Background(), gateapi.ContextGateAPIV4, gateapi.GateAPIV4{
-		Key:    "f6f3cc50911e00ae4ff6a5dbb1913a5e",
-		Secret: "8ea94f2850ad01d1da565e5c2ef6369e9667cb18209f676120857d5bc8f42c34",
-	})
+	return time.UnixMilli(res.ServerTime), nil
+}
 
-	orderResponse, _, err := client.FuturesApi.CreateFuturesOrder(ctx, "usdt", reqOrder)
+// PlaceExchagneOrder 下单，size 为合约张数，正数买入负数卖出；Price 为 "0" 时为市价单
+func PlaceExchagneOrder(order gateapi.FuturesOrder) (gateapi.FuturesOrder, error) {
+	ctx := context.Background()
+	orderResponse, _, err := client.FuturesApi.CreateFuturesOrder(ctx, "usdt", order)
 	if err != nil {
-		return gateapi.FuturesOrder{}, err
+		return gateapi.FuturesOrder{}, wrapError(err)
 	}
 	return orderResponse, nil
 }
+
+func CancelExchangeOrder(orderId string) (gateapi.FuturesOrder, error) {
+	ctx := context.Background()
+	orderResponse, _, err := client.FuturesApi.CancelFuturesOrder(ctx, "usdt", orderId)
+	if err != nil {
+		return gateapi.FuturesOrder{}, wrapError(err)
+	}
+	return orderResponse, nil
+}
+
+func GetExchangeOrder(orderId string) (gateapi.FuturesOrder, error) {
+	ctx := context.Background()
+	orderResponse, _, err := client.FuturesApi.GetFuturesOrder(ctx, "usdt", orderId)
+	if err != nil {
+		return gateapi.FuturesOrder{}, wrapError(err)
+	}
+	return orderResponse, nil
+}
+
+// ListOpenOrders 当前挂单，market 为空时查询所有市场
+func ListOpenOrders(market string) ([]gateapi.FuturesOrder, error) {
+	ctx := context.Background()
+	opts := &gateapi.ListFuturesOrdersOpts{}
+	if market != "" {
+		opts.Contract = optional.NewString(market)
+	}
+	list, _, err := client.FuturesApi.ListFuturesOrders(ctx, "usdt", "open", opts)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	return list, nil
+}
+
+func CancelAllExchangeOrders(market string) error {
+	ctx := context.Background()
+	_, _, err := client.FuturesApi.CancelFuturesOrders(ctx, "usdt", market, nil)
+	if err != nil {
+		return wrapError(err)
+	}
+	return nil
+}
+
 func SwitchPositionLeverage(market string, leverage int) error {
-	ctx := context.WithValue(context.Background(), gateapi.ContextGateAPIV4, gateapi.GateAPIV4{
-		Key:    "f6f3cc50911e00ae4ff6a5dbb1913a5e",
-		Secret: "8ea94f2850ad01d1da565e5c2ef6369e9667cb18209f676120857d5bc8f42c34",
-	})
+	ctx := context.Background()
 	_, _, err := client.FuturesApi.UpdatePositionLeverage(ctx, "usdt", market, "0", &gateapi.UpdatePositionLeverageOpts{CrossLeverageLimit: optional.NewString(fmt.Sprintf("%d", leverage))})
 	if err != nil {
-		return err
+		return wrapError(err)
 	}
 	return nil
 }
 
 func SwitchPositionMode() error {
-	ctx := context.WithValue(context.Background(), gateapi.ContextGateAPIV4, gateapi.GateAPIV4{
-		Key:    "f6f3cc50911e00ae4ff6a5dbb1913a5e",
-		Secret: "8ea94f2850ad01d1da565e5c2ef6369e9667cb18209f676120857d5bc8f42c34",
-	})
-	_, _, err := client.FuturesApi.SetDualMode(ctx, "usdt", false)
+	ctx := context.Background()
+	account, _, err := client.FuturesApi.ListFuturesAccounts(ctx, "usdt")
+	if err != nil {
+		return wrapError(err)
+	}
+	// 已经是单向持仓时不再切换
+	if !account.InDualMode {
+		return nil
+	}
+	_, _, err = client.FuturesApi.SetDualMode(ctx, "usdt", false)
 	if err != nil {
-		return err
+		return wrapError(err)
 	}
 	return nil
 }
+
+func GetPosition(market string) (gateapi.Position, error) {
+	ctx := context.Background()
+	position, _, err := client.FuturesApi.GetPosition(ctx, "usdt", market)
+	if err != nil {
+		return gateapi.Position{}, wrapError(err)
+	}
+	return position, nil
+}
+
+func GetFuturesFee(market string) (gateapi.FuturesFee, error) {
+	ctx := context.Background()
+	feeMap, _, err := client.FuturesApi.GetFuturesFee(ctx, "usdt", &gateapi.GetFuturesFeeOpts{Contract: optional.NewString(market)})
+	if err != nil {
+		return gateapi.FuturesFee{}, wrapError(err)
+	}
+	fee, ok := feeMap[market]
+	if !ok {
+		return gateapi.FuturesFee{}, fmt.Errorf("gate fee of %s not found", market)
+	}
+	return fee, nil
+}
+
+// GetUserId 私有频道订阅需要用户 id
+func GetUserId() (int64, error) {
+	ctx := context.Background()
+	detail, _, err := client.AccountApi.GetAccountDetail(ctx)
+	if err != nil {
+		return 0, wrapError(err)
+	}
+	return detail.UserId, nil
+}
@@ -0,0 +1,86 @@
+package gate_api
+
+import (
+	"errors"
+	"move_profit/clock"
+	"move_profit/exchange"
+	"move_profit/log"
+	"move_profit/ratelimit"
+	"net/http"
+	"strconv"
+	"strings"
+
+	gateapi "github.com/gateio/gateapi-go/v6"
+)
+
+// gateErrorKinds 错误 label 分类，见 https://www.gate.io/docs/developers/apiv4/#label-list
+var gateErrorKinds = map[string]exchange.ErrorKind{
+	"INVALID_KEY":             exchange.KindInvalidKey,
+	"INVALID_SIGNATURE":       exchange.KindInvalidKey,
+	"MISSING_REQUIRED_HEADER": exchange.KindInvalidKey,
+	"IP_FORBIDDEN":            exchange.KindInvalidKey,
+	"READ_ONLY":               exchange.KindInvalidKey,
+	"FORBIDDEN":               exchange.KindInvalidKey,
+	"ACCOUNT_LOCKED":          exchange.KindInvalidKey,
+	"REQUEST_EXPIRED":         exchange.KindTimestamp,
+	"INSUFFICIENT_AVAILABLE":  exchange.KindInsufficientMargin,
+	"BALANCE_NOT_ENOUGH":      exchange.KindInsufficientMargin,
+	"SIZE_TOO_SMALL":          exchange.KindMinNotional,
+	"REDUCE_EXCEEDED":         exchange.KindReduceOnlyRejected,
+	"INCREASE_POSITION":       exchange.KindReduceOnlyRejected,
+	"TOO_MANY_REQUESTS":       exchange.KindRateLimited,
+	"ORDER_NOT_FOUND":         exchange.KindUnknownOrder,
+	"ORDER_FINISHED":          exchange.KindUnknownOrder,
+	"INVALID_PARAM_VALUE":     exchange.KindInvalidRequest,
+	"INVALID_ARGUMENT":        exchange.KindInvalidRequest,
+	"CONTRACT_NOT_FOUND":      exchange.KindInvalidRequest,
+	"ORDER_POC_IMMEDIATE":     exchange.KindPostOnlyRejected,
+}
+
+// wrapError sdk 返回的错误转换为 *exchange.Error，网络错误等原样返回
+func wrapError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var limitErr *ratelimit.LimitError
+	if errors.As(err, &limitErr) {
+		return &exchange.Error{Venue: exchange.Gate, Kind: exchange.KindRateLimited, Msg: limitErr.Error(), Err: err}
+	}
+	var gateErr gateapi.GateAPIError
+	if !errors.As(err, &gateErr) {
+		var apiErr gateapi.GenericOpenAPIError
+		if !errors.As(err, &apiErr) {
+			return err
+		}
+		// 不是 gate 格式的错误响应，如网关返回的 502 页面；错误信息不以状态码开头的是 2xx 响应体解析失败
+		status := statusCode(apiErr)
+		if status == 0 {
+			status = http.StatusOK
+		}
+		return &exchange.Error{Venue: exchange.Gate, Msg: apiErr.Error(), Status: status, Err: err}
+	}
+	e := &exchange.Error{
+		Venue:  exchange.Gate,
+		Kind:   gateErrorKinds[gateErr.Label],
+		Code:   gateErr.Label,
+		Msg:    gateErr.GetMessage(),
+		Status: statusCode(gateErr.APIError),
+		Err:    err,
+	}
+	if e.Kind == exchange.KindTimestamp {
+		// 本地时钟偏差过大，立即重新校时，调用方重试时使用新的偏移
+		go func() {
+			if syncErr := clock.Get(exchange.Gate).Sync(); syncErr != nil {
+				log.Log.Errorf("[clock gate] sync err:%+v", syncErr)
+			}
+		}()
+	}
+	return e
+}
+
+// statusCode sdk 的错误信息以 http 状态开头，如 "400 Bad Request, {...}"，解析失败时为 0
+func statusCode(err gateapi.GenericOpenAPIError) int {
+	status, _, _ := strings.Cut(err.Error(), " ")
+	code, _ := strconv.Atoi(status)
+	return code
+}
@@ -0,0 +1,256 @@
+package gate_api
+
+import (
+	"fmt"
+	"move_profit/exchange"
+	"strings"
+
+	gateapi "github.com/gateio/gateapi-go/v6"
+	"github.com/shopspring/decimal"
+)
+
+// gateExchange 将 gate U本位合约适配为 exchange.Exchange，基础币数量与合约张数按 QuantoMultiplier 换算
+type gateExchange struct{}
+
+func NewExchange() exchange.Exchange {
+	return &gateExchange{}
+}
+
+func (e *gateExchange) Name() string {
+	return exchange.Gate
+}
+
+// gateTif 下单有效方式，GTX(post only) 在 gate 为 poc
+var gateTif = map[exchange.TimeInForce]string{
+	exchange.GTC: "gtc",
+	exchange.IOC: "ioc",
+	exchange.FOK: "fok",
+	exchange.GTX: "poc",
+}
+
+// gate 自定义订单 id 必须以 t- 开头
+const clientIdPrefix = "t-"
+
+// ClientId 从订单 text 中取出下单时的自定义订单 id，不是自定义 id 时(如网页下单)返回空
+func ClientId(text string) string {
+	if !strings.HasPrefix(text, clientIdPrefix) {
+		return ""
+	}
+	return strings.TrimPrefix(text, clientIdPrefix)
+}
+
+func (e *gateExchange) PlaceOrder(req exchange.OrderRequest) (*exchange.Order, error) {
+	if err := req.Check(); err != nil {
+		return nil, err
+	}
+	contract, ok := e.GetContract(req.Market)
+	if !ok {
+		return nil, &exchange.Error{Venue: exchange.Gate, Kind: exchange.KindInvalidRequest, Msg: fmt.Sprintf("market %s not found", req.Market)}
+	}
+
+	order := gateapi.FuturesOrder{
+		Contract:   req.Market,
+		Price:      "0",
+		Tif:        "ioc",
+		ReduceOnly: req.ReduceOnly,
+	}
+	if req.ClientId != "" {
+		order.Text = clientIdPrefix + req.ClientId
+	}
+	if req.ClosePosition {
+		// 单向持仓下 size 为 0、close 为 true 平掉全部仓位，方向由持仓决定
+		order.Close = true
+	} else {
+		size := req.Quantity.Div(contract.Multiplier)
+		if size.LessThan(decimal.NewFromInt(1)) {
+			return nil, &exchange.Error{Venue: exchange.Gate, Kind: exchange.KindMinNotional,
+				Msg: fmt.Sprintf("quantity %s is less than one contract of %s", req.Quantity, contract.Multiplier)}
+		}
+		if !size.Equal(size.Truncate(0)) {
+			return nil, &exchange.Error{Venue: exchange.Gate, Kind: exchange.KindInvalidRequest,
+				Msg: fmt.Sprintf("market %s quantity %s is not a multiple of %s", req.Market, req.Quantity, contract.Multiplier)}
+		}
+		if req.Side == exchange.SideSell {
+			size = size.Neg()
+		}
+		order.Size = size.IntPart()
+	}
+	if req.OrderType() == exchange.OrderLimit {
+		order.Price = req.Price.String()
+		order.Tif = gateTif[req.TIF()]
+	} else if req.TimeInForce == exchange.FOK {
+		order.Tif = "fok"
+	}
+
+	res, err := PlaceExchagneOrder(order)
+	if err != nil {
+		return nil, err
+	}
+	o := newOrder(res, contract)
+	// close 单的 size 为 0，方向以请求为准
+	o.Side = req.Side
+	return o, nil
+}
+
+// CancelOrder 返回撤单后的订单，已成交的部分仍在 FilledQuantity 中
+func (e *gateExchange) CancelOrder(market, id string) (*exchange.Order, error) {
+	contract, ok := e.GetContract(market)
+	if !ok {
+		return nil, &exchange.Error{Venue: exchange.Gate, Kind: exchange.KindInvalidRequest, Msg: fmt.Sprintf("market %s not found", market)}
+	}
+	res, err := CancelExchangeOrder(id)
+	if err != nil {
+		return nil, err
+	}
+	return newOrder(res, contract), nil
+}
+
+// newOrder 张数换算为基础币数量，size 为负时为卖单
+func newOrder(res gateapi.FuturesOrder, contract exchange.Contract) *exchange.Order {
+	side := exchange.SideBuy
+	size, left := res.Size, res.Left
+	if size < 0 {
+		side, size, left = exchange.SideSell, -size, -left
+	}
+	fillPrice, _ := decimal.NewFromString(res.FillPrice)
+	// 已结束的订单 status 为 finished，用结束原因代替
+	status := res.Status
+	if status == "finished" && res.FinishAs != "" {
+		status = res.FinishAs
+	}
+	return &exchange.Order{
+		Id:             fmt.Sprintf("%d", res.Id),
+		ClientId:       ClientId(res.Text),
+		Market:         contract.Market,
+		Side:           side,
+		Quantity:       decimal.NewFromInt(size).Mul(contract.Multiplier),
+		FilledQuantity: decimal.NewFromInt(size - left).Mul(contract.Multiplier),
+		AvgPrice:       fillPrice,
+		State:          OrderState(status, size, left),
+		Status:         status,
+	}
+}
+
+// OrderState gate 订单状态转换为统一状态，status 为 open 或已结束订单的 finish_as，size/left 为下单和未成交张数的绝对值
+func OrderState(status string, size, left int64) exchange.OrderState {
+	switch status {
+	case "open":
+		if left < size {
+			return exchange.OrderPartiallyFilled
+		}
+		return exchange.OrderNew
+	case "filled":
+		return exchange.OrderFilled
+	case "ioc", "poc":
+		// ioc 未成交部分过期，poc 会吃单被拒
+		return exchange.OrderExpired
+	}
+	// cancelled 以及 reduce_only、position_closed、stp、liquidated 等原因被撤销，全部成交时仍为 filled
+	if left == 0 {
+		return exchange.OrderFilled
+	}
+	return exchange.OrderCancelled
+}
+
+func (e *gateExchange) GetOrder(market, id string) (*exchange.Order, error) {
+	contract, ok := e.GetContract(market)
+	if !ok {
+		return nil, &exchange.Error{Venue: exchange.Gate, Kind: exchange.KindInvalidRequest, Msg: fmt.Sprintf("market %s not found", market)}
+	}
+	res, err := GetExchangeOrder(id)
+	if err != nil {
+		return nil, err
+	}
+	return newOrder(res, contract), nil
+}
+
+// GetOrderByClientId gate 查询订单时 order_id 也可以是下单时的 text
+func (e *gateExchange) GetOrderByClientId(market, clientId string) (*exchange.Order, error) {
+	return e.GetOrder(market, clientIdPrefix+clientId)
+}
+
+func (e *gateExchange) OpenOrders(market string) ([]exchange.Order, error) {
+	list, err := ListOpenOrders(market)
+	if err != nil {
+		return nil, err
+	}
+	orders := make([]exchange.Order, 0, len(list))
+	for _, res := range list {
+		contract, ok := e.GetContract(res.Contract)
+		if !ok {
+			continue
+		}
+		orders = append(orders, *newOrder(res, contract))
+	}
+	return orders, nil
+}
+
+func (e *gateExchange) CancelAllOrders(market string) error {
+	return CancelAllExchangeOrders(market)
+}
+
+func (e *gateExchange) SetLeverage(market string, leverage int) error {
+	return SwitchPositionLeverage(market, leverage)
+}
+
+// SetMarginMode gate 单向持仓下 leverage 为 0 即为全仓，全仓模式随 SetLeverage 一起生效
+func (e *gateExchange) SetMarginMode(market string, mode exchange.MarginMode) error {
+	if mode != exchange.MarginCrossed {
+		return &exchange.Error{Venue: exchange.Gate, Kind: exchange.KindInvalidRequest, Msg: fmt.Sprintf("margin mode %s not supported", mode)}
+	}
+	return nil
+}
+
+func (e *gateExchange) GetContract(market string) (exchange.Contract, bool) {
+	info, ok := GetMarketInfo(market)
+	if !ok {
+		return exchange.Contract{}, false
+	}
+	multiplier, err := decimal.NewFromString(info.QuantoMultiplier)
+	if err != nil || !multiplier.IsPositive() {
+		return exchange.Contract{}, false
+	}
+	priceStep, _ := decimal.NewFromString(info.OrderPriceRound)
+	return exchange.Contract{
+		Market:       market,
+		Multiplier:   multiplier,
+		QuantityStep: multiplier,
+		MinQuantity:  decimal.NewFromInt(info.OrderSizeMin).Mul(multiplier),
+		MaxQuantity:  decimal.NewFromInt(info.OrderSizeMax).Mul(multiplier),
+		PriceStep:    priceStep,
+		Tradable:     !info.InDelisting,
+	}, true
+}
+
+func (e *gateExchange) GetPosition(market string) (exchange.Position, error) {
+	contract, ok := e.GetContract(market)
+	if !ok {
+		return exchange.Position{}, &exchange.Error{Venue: exchange.Gate, Kind: exchange.KindInvalidRequest, Msg: fmt.Sprintf("market %s not found", market)}
+	}
+	res, err := GetPosition(market)
+	if err != nil {
+		return exchange.Position{}, err
+	}
+	entryPrice, _ := decimal.NewFromString(res.EntryPrice)
+	return exchange.Position{
+		Market:     market,
+		Quantity:   decimal.NewFromInt(res.Size).Mul(contract.Multiplier),
+		EntryPrice: entryPrice,
+	}, nil
+}
+
+func (e *gateExchange) GetFeeRate(market string) (exchange.FeeRate, error) {
+	res, err := GetFuturesFee(market)
+	if err != nil {
+		return exchange.FeeRate{}, err
+	}
+	maker, err := decimal.NewFromString(res.MakerFee)
+	if err != nil {
+		return exchange.FeeRate{}, err
+	}
+	taker, err := decimal.NewFromString(res.TakerFee)
+	if err != nil {
+		return exchange.FeeRate{}, err
+	}
+	return exchange.FeeRate{Maker: maker, Taker: taker}, nil
+}
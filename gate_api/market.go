@@ -0,0 +1,50 @@
+package gate_api
+
+import (
+	"move_profit/exchange"
+
+	gateapi "github.com/gateio/gateapi-go/v6"
+)
+
+// RefreshMarketInfo 重新加载 U本位合约列表，返回与上次相比的合约变化，首次加载时每个市场都是 listed
+func RefreshMarketInfo() ([]exchange.MarketChange, error) {
+	list, err := GetGateMarketInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make([]exchange.MarketChange, 0)
+	seen := make(map[string]bool)
+	for _, contract := range list {
+		seen[contract.Name] = true
+		change := exchange.MarketChange{Venue: exchange.Gate, Market: contract.Name, Tradable: !contract.InDelisting}
+		old, ok := GetMarketInfo(contract.Name)
+		gateMarketInfoMap.Store(contract.Name, contract)
+		switch {
+		case !ok:
+			change.Kind = exchange.MarketListed
+		case old.InDelisting != contract.InDelisting:
+			change.Kind = exchange.MarketStatusChanged
+		case rulesChanged(old, contract):
+			change.Kind = exchange.MarketRulesChanged
+		default:
+			continue
+		}
+		changes = append(changes, change)
+	}
+	gateMarketInfoMap.Range(func(key, value any) bool {
+		if market := key.(string); !seen[market] {
+			gateMarketInfoMap.Delete(market)
+			changes = append(changes, exchange.MarketChange{Venue: exchange.Gate, Market: market, Kind: exchange.MarketRemoved})
+		}
+		return true
+	})
+	return changes, nil
+}
+
+func rulesChanged(old, cur gateapi.Contract) bool {
+	return old.QuantoMultiplier != cur.QuantoMultiplier ||
+		old.OrderPriceRound != cur.OrderPriceRound ||
+		old.OrderSizeMin != cur.OrderSizeMin ||
+		old.OrderSizeMax != cur.OrderSizeMax
+}
@@ -0,0 +1,54 @@
+package gate_api
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"move_profit/clock"
+	"move_profit/exchange"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// signTransport sdk 用本地 time.Now() 签名，这里按校正后的服务器时间重新签名，
+// 放在限频器之后，等待限频不会让时间戳变旧
+type signTransport struct {
+	secret string
+	base   http.RoundTripper
+}
+
+func (t *signTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("SIGN") == "" {
+		return t.base.RoundTrip(req)
+	}
+
+	h := sha512.New()
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		_, err = io.Copy(h, body)
+		body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	rawQuery, err := url.QueryUnescape(req.URL.RawQuery)
+	if err != nil {
+		return nil, err
+	}
+	ts := strconv.FormatInt(clock.Get(exchange.Gate).Now().Unix(), 10)
+	msg := fmt.Sprintf("%s\n%s\n%s\n%s\n%s", req.Method, req.URL.Path, rawQuery, hex.EncodeToString(h.Sum(nil)), ts)
+	mac := hmac.New(sha512.New, []byte(t.secret))
+	mac.Write([]byte(msg))
+
+	// RoundTripper 不能修改传入的请求
+	req = req.Clone(req.Context())
+	req.Header.Set("SIGN", hex.EncodeToString(mac.Sum(nil)))
+	req.Header.Set("Timestamp", ts)
+	return t.base.RoundTrip(req)
+}
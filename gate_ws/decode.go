@@ -0,0 +1,184 @@
+package gate_ws
+
+import (
+	"move_profit/gate_api"
+	"move_profit/jsonscan"
+	"move_profit/quote"
+	"sync"
+
+	"github.com/shopspring/decimal"
+)
+
+// pubMsg 公共频道推送外层 {"time":..,"channel":"futures.book_ticker","event":"update","error":null,"result":{...}}
+// 切片字段指向原始消息，只在处理本条消息期间有效
+type pubMsg struct {
+	channel []byte
+	event   []byte
+	err     []byte
+	result  []byte
+}
+
+func (m *pubMsg) decode(data []byte) error {
+	*m = pubMsg{}
+	return jsonscan.ObjectEach(data, func(key, value []byte) bool {
+		switch string(key) {
+		case "channel":
+			m.channel = jsonscan.Unquote(value)
+		case "event":
+			m.event = jsonscan.Unquote(value)
+		case "error":
+			m.err = value
+		case "result":
+			m.result = value
+		}
+		return true
+	})
+}
+
+// bookTickerMsg {"t":1615366379123,"u":2517661076,"s":"BTC_USDT","b":"54696.6","B":37000,"a":"54697","A":47061}，B/A 为合约张数
+type bookTickerMsg struct {
+	market                     []byte
+	bid, bidSize, ask, askSize jsonscan.Num
+}
+
+func (m *bookTickerMsg) decode(data []byte) error {
+	*m = bookTickerMsg{}
+	return jsonscan.ObjectEach(data, func(key, value []byte) bool {
+		switch string(key) {
+		case "s":
+			m.market = jsonscan.Unquote(value)
+		case "b":
+			m.bid, _ = jsonscan.ParseNum(value)
+		case "B":
+			m.bidSize, _ = jsonscan.ParseNum(value)
+		case "a":
+			m.ask, _ = jsonscan.ParseNum(value)
+		case "A":
+			m.askSize, _ = jsonscan.ParseNum(value)
+		}
+		return true
+	})
+}
+
+type levelNum struct {
+	price jsonscan.Num
+	size  jsonscan.Num
+}
+
+// orderBookMsg {"t":1615366381417,"contract":"BTC_USDT","id":2517661101,"asks":[{"p":"54672.1","s":95}],"bids":[{"p":"54664.5","s":5000}]}
+// 档位切片在消息间复用
+type orderBookMsg struct {
+	market     []byte
+	bids, asks []levelNum
+}
+
+func (m *orderBookMsg) decode(data []byte) error {
+	m.market, m.bids, m.asks = nil, m.bids[:0], m.asks[:0]
+	var levelErr error
+	err := jsonscan.ObjectEach(data, func(key, value []byte) bool {
+		switch string(key) {
+		case "contract":
+			m.market = jsonscan.Unquote(value)
+		case "bids":
+			m.bids, levelErr = appendLevels(m.bids, value)
+		case "asks":
+			m.asks, levelErr = appendLevels(m.asks, value)
+		}
+		return levelErr == nil
+	})
+	if err != nil {
+		return err
+	}
+	return levelErr
+}
+
+func appendLevels(levels []levelNum, data []byte) ([]levelNum, error) {
+	err := jsonscan.ArrayEach(data, func(value []byte) bool {
+		var l levelNum
+		jsonscan.ObjectEach(value, func(key, v []byte) bool {
+			switch string(key) {
+			case "p":
+				l.price, _ = jsonscan.ParseNum(v)
+			case "s":
+				l.size, _ = jsonscan.ParseNum(v)
+			}
+			return true
+		})
+		levels = append(levels, l)
+		return true
+	})
+	return levels, err
+}
+
+// tickerMsg futures.tickers 中用到的字段，funding_rate 缺失时 hasFundingRate 为 false
+type tickerMsg struct {
+	market         []byte
+	last           jsonscan.Num
+	fundingRate    jsonscan.Num
+	hasFundingRate bool
+}
+
+func (m *tickerMsg) decode(data []byte) error {
+	*m = tickerMsg{}
+	var indicative jsonscan.Num
+	hasIndicative := false
+	err := jsonscan.ObjectEach(data, func(key, value []byte) bool {
+		switch string(key) {
+		case "contract":
+			m.market = jsonscan.Unquote(value)
+		case "last":
+			m.last, _ = jsonscan.ParseNum(value)
+		case "funding_rate":
+			m.fundingRate, m.hasFundingRate = jsonscan.ParseNum(value)
+		case "funding_rate_indicative":
+			indicative, hasIndicative = jsonscan.ParseNum(value)
+		}
+		return true
+	})
+	if !m.hasFundingRate && hasIndicative {
+		m.fundingRate, m.hasFundingRate = indicative, true
+	}
+	return err
+}
+
+var markets jsonscan.Intern
+
+var (
+	multiplierMu    sync.RWMutex
+	multiplierCache = make(map[string]decimal.Decimal)
+)
+
+// multiplierOf 合约乘数，按市场缓存，避免每条推送都复制合约信息并解析字符串
+func multiplierOf(market string) (decimal.Decimal, bool) {
+	multiplierMu.RLock()
+	multiplier, ok := multiplierCache[market]
+	multiplierMu.RUnlock()
+	if ok {
+		return multiplier, true
+	}
+
+	contract, ok := gate_api.GetMarketInfo(market)
+	if !ok {
+		return decimal.Zero, false
+	}
+	multiplier, err := decimal.NewFromString(contract.QuantoMultiplier)
+	if err != nil {
+		return decimal.Zero, false
+	}
+	multiplierMu.Lock()
+	multiplierCache[market] = multiplier
+	multiplierMu.Unlock()
+	return multiplier, true
+}
+
+// quoteLevels 张数换算为基础币数量，跳过数量为 0 的档位
+func quoteLevels(levels []levelNum, multiplier decimal.Decimal) []quote.Level {
+	list := make([]quote.Level, 0, len(levels))
+	for _, l := range levels {
+		if !l.size.IsPositive() {
+			continue
+		}
+		list = append(list, quote.Level{Price: l.price.Decimal(), Quantity: l.size.Decimal().Mul(multiplier)})
+	}
+	return list
+}
@@ -0,0 +1,262 @@
+package gate_ws
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bitly/go-simplejson"
+	"github.com/shopspring/decimal"
+	"move_profit/jsonscan"
+	"move_profit/quote"
+)
+
+var (
+	bookTickerData = []byte(`{"time":1615366379,"time_ms":1615366379123,"channel":"futures.book_ticker","event":"update","error":null,` +
+		`"result":{"t":1615366379123,"u":2517661076,"s":"BTC_USDT","b":"54696.6","B":37000,"a":"54697","A":47061}}`)
+	orderBookData = []byte(`{"time":1615366381,"channel":"futures.order_book","event":"all","error":null,"result":{"t":1615366381417,"contract":"BTC_USDT","id":2517661101,` +
+		`"asks":[{"p":"54672.1","s":95},{"p":"54672.5","s":0},{"p":"54673","s":120},{"p":"54674.2","s":3},{"p":"54675","s":800}],` +
+		`"bids":[{"p":"54664.5","s":5000},{"p":"54664","s":12},{"p":"54663.1","s":0},{"p":"54662","s":40},{"p":"54660.8","s":7}]}}`)
+	multiplier = decimal.RequireFromString("0.0001")
+)
+
+func dec(s string) decimal.Decimal {
+	return decimal.RequireFromString(s)
+}
+
+func TestDecodePubMsg(t *testing.T) {
+	var m pubMsg
+	if err := m.decode(bookTickerData); err != nil {
+		t.Fatal(err)
+	}
+	if string(m.channel) != "futures.book_ticker" || string(m.event) != "update" || !jsonscan.IsNull(m.err) {
+		t.Errorf("envelope = %s %s %s", m.channel, m.event, m.err)
+	}
+
+	// 解析新消息前清空上一条的字段
+	if err := m.decode([]byte(`{"channel":"futures.tickers","event":"subscribe","error":{"code":2,"message":"unknown contract"}}`)); err != nil {
+		t.Fatal(err)
+	}
+	if string(m.err) != `{"code":2,"message":"unknown contract"}` || m.result != nil {
+		t.Errorf("error envelope = %s result = %s", m.err, m.result)
+	}
+}
+
+func TestDecodeBookTicker(t *testing.T) {
+	var env pubMsg
+	if err := env.decode(bookTickerData); err != nil {
+		t.Fatal(err)
+	}
+	var m bookTickerMsg
+	if err := m.decode(env.result); err != nil {
+		t.Fatal(err)
+	}
+	if string(m.market) != "BTC_USDT" || !m.bid.Decimal().Equal(dec("54696.6")) || !m.ask.Decimal().Equal(dec("54697")) ||
+		!m.bidSize.Decimal().Equal(dec("37000")) || !m.askSize.Decimal().Equal(dec("47061")) {
+		t.Errorf("book = %s %s %s %s %s", m.market, m.bid.Decimal(), m.bidSize.Decimal(), m.ask.Decimal(), m.askSize.Decimal())
+	}
+}
+
+func TestDecodeOrderBook(t *testing.T) {
+	var env pubMsg
+	if err := env.decode(orderBookData); err != nil {
+		t.Fatal(err)
+	}
+	var m orderBookMsg
+	if err := m.decode(env.result); err != nil {
+		t.Fatal(err)
+	}
+	if string(m.market) != "BTC_USDT" {
+		t.Errorf("market = %s", m.market)
+	}
+	tests := []struct {
+		name   string
+		levels []quote.Level
+		want   [][2]string
+	}{
+		{"bids", quoteLevels(m.bids, multiplier), [][2]string{{"54664.5", "0.5"}, {"54664", "0.0012"}, {"54662", "0.004"}, {"54660.8", "0.0007"}}},
+		{"asks", quoteLevels(m.asks, multiplier), [][2]string{{"54672.1", "0.0095"}, {"54673", "0.012"}, {"54674.2", "0.0003"}, {"54675", "0.08"}}},
+	}
+	for _, tt := range tests {
+		if len(tt.levels) != len(tt.want) {
+			t.Errorf("%s = %+v", tt.name, tt.levels)
+			continue
+		}
+		for i, l := range tt.levels {
+			if !l.Price.Equal(dec(tt.want[i][0])) || !l.Quantity.Equal(dec(tt.want[i][1])) {
+				t.Errorf("%s %d = %s %s, want %v", tt.name, i, l.Price, l.Quantity, tt.want[i])
+			}
+		}
+	}
+
+	// 档位切片复用时不能残留上一条的档位
+	if err := m.decode([]byte(`{"contract":"ETH_USDT","asks":[],"bids":[{"p":"1","s":2}]}`)); err != nil {
+		t.Fatal(err)
+	}
+	if string(m.market) != "ETH_USDT" || len(m.bids) != 1 || len(m.asks) != 0 {
+		t.Errorf("reused message = %s bids %+v asks %+v", m.market, m.bids, m.asks)
+	}
+}
+
+func TestDecodeTicker(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		last    string
+		rate    string
+		hasRate bool
+	}{
+		{
+			name:    "funding rate",
+			data:    `{"contract":"BTC_USDT","last":"54696.6","funding_rate":"0.0001","funding_rate_indicative":"0.0002"}`,
+			last:    "54696.6",
+			rate:    "0.0001",
+			hasRate: true,
+		},
+		{
+			name:    "indicative funding rate",
+			data:    `{"funding_rate_indicative":"-0.0002","contract":"BTC_USDT","last":"54696.6"}`,
+			last:    "54696.6",
+			rate:    "-0.0002",
+			hasRate: true,
+		},
+		{
+			name:    "empty funding rate falls back",
+			data:    `{"contract":"BTC_USDT","last":"1","funding_rate":"","funding_rate_indicative":"0.0003"}`,
+			last:    "1",
+			rate:    "0.0003",
+			hasRate: true,
+		},
+		{
+			name: "no funding rate",
+			data: `{"contract":"BTC_USDT","last":"1","funding_rate":null}`,
+			last: "1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var m tickerMsg
+			if err := m.decode([]byte(tt.data)); err != nil {
+				t.Fatal(err)
+			}
+			if string(m.market) != "BTC_USDT" || !m.last.Decimal().Equal(dec(tt.last)) || m.hasFundingRate != tt.hasRate {
+				t.Errorf("ticker = %s %s %v", m.market, m.last.Decimal(), m.hasFundingRate)
+			}
+			if tt.hasRate && !m.fundingRate.Decimal().Equal(dec(tt.rate)) {
+				t.Errorf("funding rate = %s, want %s", m.fundingRate.Decimal(), tt.rate)
+			}
+		})
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	var (
+		env  pubMsg
+		book orderBookMsg
+	)
+	if err := env.decode(bookTickerData[:len(bookTickerData)-1]); !errors.Is(err, jsonscan.ErrSyntax) {
+		t.Errorf("truncated envelope err = %v", err)
+	}
+	if err := book.decode([]byte(`{"contract":"BTC_USDT","bids":[{"p":"1","s":2},]}`)); !errors.Is(err, jsonscan.ErrSyntax) {
+		t.Errorf("invalid levels err = %v", err)
+	}
+}
+
+func BenchmarkDecodeBookTicker(b *testing.B) {
+	var (
+		env pubMsg
+		m   bookTickerMsg
+	)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := env.decode(bookTickerData); err != nil {
+			b.Fatal(err)
+		}
+		if err := m.decode(env.result); err != nil {
+			b.Fatal(err)
+		}
+		_ = markets.String(m.market)
+		_ = quote.BookTicker{
+			Bid:     m.bid.Decimal(),
+			BidSize: m.bidSize.Decimal().Mul(multiplier),
+			Ask:     m.ask.Decimal(),
+			AskSize: m.askSize.Decimal().Mul(multiplier),
+		}
+	}
+}
+
+// BenchmarkSimplejsonBookTicker 改用 jsonscan 之前的解析方式
+func BenchmarkSimplejsonBookTicker(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		data, err := simplejson.NewJson(bookTickerData)
+		if err != nil {
+			b.Fatal(err)
+		}
+		_, _ = data.Get("event").String()
+		_, _ = data.Get("channel").String()
+		data.CheckGet("error")
+		result := data.Get("result")
+		_, _ = result.Get("s").String()
+		bid, _ := result.Get("b").String()
+		ask, _ := result.Get("a").String()
+		bidSize, _ := result.Get("B").Int64()
+		askSize, _ := result.Get("A").Int64()
+		book := quote.BookTicker{
+			BidSize: decimal.NewFromInt(bidSize).Mul(multiplier),
+			AskSize: decimal.NewFromInt(askSize).Mul(multiplier),
+		}
+		book.Bid, _ = decimal.NewFromString(bid)
+		book.Ask, _ = decimal.NewFromString(ask)
+	}
+}
+
+func BenchmarkDecodeOrderBook(b *testing.B) {
+	var (
+		env pubMsg
+		m   orderBookMsg
+	)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := env.decode(orderBookData); err != nil {
+			b.Fatal(err)
+		}
+		if err := m.decode(env.result); err != nil {
+			b.Fatal(err)
+		}
+		_ = quote.OrderBook{Bids: quoteLevels(m.bids, multiplier), Asks: quoteLevels(m.asks, multiplier)}
+	}
+}
+
+func BenchmarkSimplejsonOrderBook(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		data, err := simplejson.NewJson(orderBookData)
+		if err != nil {
+			b.Fatal(err)
+		}
+		_, _ = data.Get("event").String()
+		_, _ = data.Get("channel").String()
+		data.CheckGet("error")
+		result := data.Get("result")
+		_, _ = result.Get("contract").String()
+		_ = quote.OrderBook{
+			Bids: simplejsonLevels(result.Get("bids")),
+			Asks: simplejsonLevels(result.Get("asks")),
+		}
+	}
+}
+
+func simplejsonLevels(data *simplejson.Json) []quote.Level {
+	list, _ := data.Array()
+	levels := make([]quote.Level, 0, len(list))
+	for i := range list {
+		price, _ := data.GetIndex(i).Get("p").String()
+		size, _ := data.GetIndex(i).Get("s").Int64()
+		level := quote.Level{Quantity: decimal.NewFromInt(size).Mul(multiplier)}
+		level.Price, _ = decimal.NewFromString(price)
+		if level.Quantity.IsPositive() {
+			levels = append(levels, level)
+		}
+	}
+	return levels
+}
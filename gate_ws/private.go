@@ -0,0 +1,243 @@
+package gate_ws
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/bitly/go-simplejson"
+	"github.com/shopspring/decimal"
+	"move_profit/account"
+	"move_profit/alert"
+	"move_profit/config"
+	"move_profit/exchange"
+	"move_profit/gate_api"
+	"move_profit/log"
+	"time"
+)
+
+// OrderEvent futures.orders 推送，size/left 为合约张数，size 为负表示卖出
+type OrderEvent struct {
+	Contract     string  `json:"contract"`
+	CreateTimeMs int64   `json:"create_time_ms"`
+	FillPrice    float64 `json:"fill_price"`
+	FinishAs     string  `json:"finish_as"`
+	FinishTimeMs int64   `json:"finish_time_ms"`
+	Id           int64   `json:"id"`
+	IsClose      bool    `json:"is_close"`
+	IsLiq        bool    `json:"is_liq"`
+	IsReduceOnly bool    `json:"is_reduce_only"`
+	Left         int64   `json:"left"`
+	Price        float64 `json:"price"`
+	Size         int64   `json:"size"`
+	Status       string  `json:"status"`
+	Text         string  `json:"text"`
+	Tif          string  `json:"tif"`
+}
+
+// UserTradeEvent futures.usertrades 推送
+type UserTradeEvent struct {
+	Id           string  `json:"id"`
+	CreateTimeMs int64   `json:"create_time_ms"`
+	Contract     string  `json:"contract"`
+	OrderId      string  `json:"order_id"`
+	Size         int64   `json:"size"`
+	Price        string  `json:"price"`
+	Role         string  `json:"role"`
+	Text         string  `json:"text"`
+	Fee          float64 `json:"fee"`
+}
+
+// PositionEvent futures.positions 推送，size 为合约张数，空仓为负
+type PositionEvent struct {
+	Contract    string  `json:"contract"`
+	EntryPrice  float64 `json:"entry_price"`
+	Leverage    float64 `json:"leverage"`
+	LiqPrice    float64 `json:"liq_price"`
+	Margin      float64 `json:"margin"`
+	Mode        string  `json:"mode"`
+	RealisedPnl float64 `json:"realised_pnl"`
+	Size        int64   `json:"size"`
+	TimeMs      int64   `json:"time_ms"`
+}
+
+// BalanceEvent futures.balances 推送
+type BalanceEvent struct {
+	Balance  float64 `json:"balance"`
+	Change   float64 `json:"change"`
+	Text     string  `json:"text"`
+	TimeMs   int64   `json:"time_ms"`
+	Type     string  `json:"type"`
+	Currency string  `json:"currency"`
+}
+
+// GatePrivate 订阅订单、成交、仓位和余额私有频道，推送更新到本地账户 account.Get(exchange.Gate)
+func GatePrivate() {
+	conf := config.Conf.Gate
+	userId, err := gate_api.GetUserId()
+	if err != nil {
+		alert.Send("gate private ws get user id err:%+v", err)
+		return
+	}
+	server, err := NewWsService(log.Log, &ConnConf{
+		URL:          wsUrl,
+		Key:          key,
+		Secret:       secret,
+		MaxRetryConn: conf.WsMaxRetry,
+		PingInterval: conf.WsPingInterval.Duration(),
+	})
+	if err != nil {
+		log.Log.Errorf("gate private ws init err:%+v", err)
+		return
+	}
+	if err = server.Start(); err != nil {
+		alert.Send("gate private ws connect failed, gate account updates stopped err:%+v", err)
+		return
+	}
+
+	user := fmt.Sprintf("%d", userId)
+	for _, channel := range []string{"futures.orders", "futures.usertrades", "futures.positions"} {
+		if err = server.Subscribe(channel, []string{user, "!all"}); err != nil {
+			log.Log.Errorf("gate private ws subscribe %s err:%+v", channel, err)
+		}
+	}
+	if err = server.Subscribe("futures.balances", []string{user}); err != nil {
+		log.Log.Errorf("gate private ws subscribe futures.balances err:%+v", err)
+	}
+
+	for message := range server.GetMsgChan() {
+		processPrivateMsg(message)
+	}
+	alert.Send("gate private ws reconnect failed, gate account updates stopped")
+}
+
+func processPrivateMsg(message []byte) {
+	data, err := simplejson.NewJson(message)
+	if err != nil {
+		return
+	}
+	event, _ := data.Get("event").String()
+	channel, _ := data.Get("channel").String()
+	if errMsg, ok := data.CheckGet("error"); ok && errMsg.Interface() != nil {
+		log.Log.Errorf("gate private ws channel:%s event:%s err:%s", channel, event, string(message))
+		return
+	}
+	if event != "update" {
+		return
+	}
+	body, err := data.Get("result").Encode()
+	if err != nil {
+		return
+	}
+
+	switch channel {
+	case "futures.orders":
+		var events []OrderEvent
+		if err = json.Unmarshal(body, &events); err == nil {
+			for _, e := range events {
+				processOrderEvent(e)
+			}
+		}
+	case "futures.usertrades":
+		var events []UserTradeEvent
+		if err = json.Unmarshal(body, &events); err == nil {
+			for _, e := range events {
+				processUserTradeEvent(e)
+			}
+		}
+	case "futures.positions":
+		var events []PositionEvent
+		if err = json.Unmarshal(body, &events); err == nil {
+			for _, e := range events {
+				processPositionEvent(e)
+			}
+		}
+	case "futures.balances":
+		var events []BalanceEvent
+		if err = json.Unmarshal(body, &events); err == nil {
+			for _, e := range events {
+				processBalanceEvent(e)
+			}
+		}
+	}
+	if err != nil {
+		log.Log.Errorf("gate private ws decode %s msg:[%s] err:%+v", channel, string(message), err)
+	}
+}
+
+// contractQuantity 合约张数换算为基础币数量
+func contractQuantity(market string, size int64) decimal.Decimal {
+	contract, ok := gate_api.GetMarketInfo(market)
+	if !ok {
+		return decimal.Zero
+	}
+	multiplier, _ := decimal.NewFromString(contract.QuantoMultiplier)
+	return decimal.NewFromInt(size).Mul(multiplier)
+}
+
+func sizeSide(size int64) exchange.Side {
+	if size < 0 {
+		return exchange.SideSell
+	}
+	return exchange.SideBuy
+}
+
+func processOrderEvent(e OrderEvent) {
+	quantity := contractQuantity(e.Contract, e.Size).Abs()
+	left := contractQuantity(e.Contract, e.Left).Abs()
+	// 未结束的订单状态为 open，结束后为结束原因 filled、cancelled、ioc 等
+	status := e.Status
+	if e.Status == "finished" {
+		status = e.FinishAs
+	}
+	size, leftSize := e.Size, e.Left
+	if size < 0 {
+		size, leftSize = -size, -leftSize
+	}
+	account.Get(exchange.Gate).UpdateOrder(exchange.Order{
+		Id:             fmt.Sprintf("%d", e.Id),
+		ClientId:       gate_api.ClientId(e.Text),
+		Market:         e.Contract,
+		Side:           sizeSide(e.Size),
+		Quantity:       quantity,
+		FilledQuantity: quantity.Sub(left),
+		AvgPrice:       decimal.NewFromFloat(e.FillPrice),
+		State:          gate_api.OrderState(status, size, leftSize),
+		Status:         status,
+	}, e.Status == "finished")
+}
+
+func processUserTradeEvent(e UserTradeEvent) {
+	t := account.Trade{
+		Id:       e.Id,
+		OrderId:  e.OrderId,
+		Market:   e.Contract,
+		Side:     sizeSide(e.Size),
+		Quantity: contractQuantity(e.Contract, e.Size).Abs(),
+		Fee:      decimal.NewFromFloat(e.Fee),
+		Maker:    e.Role == "maker",
+		Time:     time.UnixMilli(e.CreateTimeMs),
+	}
+	t.Price, _ = decimal.NewFromString(e.Price)
+	account.Get(exchange.Gate).AddTrade(t)
+	log.Log.Infof("[gate fill] market:%s order:%s side:%s quantity:%s price:%s fee:%s", t.Market, t.OrderId, t.Side, t.Quantity, t.Price, t.Fee)
+}
+
+func processPositionEvent(e PositionEvent) {
+	account.Get(exchange.Gate).UpdatePosition(exchange.Position{
+		Market:     e.Contract,
+		Quantity:   contractQuantity(e.Contract, e.Size),
+		EntryPrice: decimal.NewFromFloat(e.EntryPrice),
+	})
+}
+
+func processBalanceEvent(e BalanceEvent) {
+	// usdt 结算合约的推送可能不带 currency
+	asset := e.Currency
+	if asset == "" {
+		asset = "usdt"
+	}
+	account.Get(exchange.Gate).UpdateBalance(account.Balance{
+		Asset:      asset,
+		Balance:    decimal.NewFromFloat(e.Balance),
+		UpdateTime: time.UnixMilli(e.TimeMs),
+	})
+}
@@ -0,0 +1,299 @@
+package gate_ws
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/op/go-logging"
+	"move_profit/clock"
+	"move_profit/exchange"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+type status int
+
+const (
+	disconnected status = iota
+	connected
+	reconnecting
+
+	// 重连退避：从 defaultRetryInterval 开始每次翻倍，最长 maxRetryInterval
+	defaultRetryInterval = 500 * time.Millisecond
+	maxRetryInterval     = 30 * time.Second
+
+	defaultPingInterval = 10 * time.Second
+
+	// 默认 msg chan 长度 2000
+	DefaultMsgChanLength = 2000
+)
+
+func (s status) String() string {
+	switch s {
+	case connected:
+		return "connected"
+	case reconnecting:
+		return "reconnecting"
+	default:
+		return "disconnected"
+	}
+}
+
+type ConnConf struct {
+	URL          string
+	Key          string
+	Secret       string
+	MaxRetryConn int           // 每次断线后最多重连次数，0 为一直重连
+	PingInterval time.Duration // futures.ping 发送间隔，超过 3 个间隔没有收到任何消息视为断线
+	MsgChanLen   uint64
+}
+
+// WsService gate 合约 ws 连接：断线后按退避重连，重连后重新订阅已订阅的频道，定时发送 futures.ping
+type WsService struct {
+	logger *logging.Logger
+	conf   *ConnConf
+
+	mu            sync.Mutex // 保护 client、status、subscriptions，并串行化写消息
+	client        *websocket.Conn
+	status        status
+	subscriptions []*Msg
+
+	msgChan chan []byte
+}
+
+func NewWsService(logger *logging.Logger, conf *ConnConf) (*WsService, error) {
+	if conf.URL == "" {
+		return nil, fmt.Errorf("gate ws url must not be empty")
+	}
+	if conf.MaxRetryConn < 0 {
+		return nil, fmt.Errorf("gate ws max retry must not be negative")
+	}
+	if conf.PingInterval <= 0 {
+		conf.PingInterval = defaultPingInterval
+	}
+	if conf.MsgChanLen == 0 {
+		conf.MsgChanLen = DefaultMsgChanLength
+	}
+
+	return &WsService{
+		logger:  logger,
+		conf:    conf,
+		msgChan: make(chan []byte, conf.MsgChanLen),
+	}, nil
+}
+
+// Start 建立连接并开始读消息和心跳，首次连接同样按重连策略重试
+func (ws *WsService) Start() error {
+	ws.logger.Warning("gate ws service started")
+	if err := ws.connect(); err != nil {
+		return err
+	}
+
+	go ws.readMsg()
+	go ws.ping()
+	return nil
+}
+
+// GetMsgChan 收到的原始消息，服务放弃重连后关闭
+func (ws *WsService) GetMsgChan() <-chan []byte {
+	return ws.msgChan
+}
+
+// Status 当前连接状态
+func (ws *WsService) Status() string {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	return ws.status.String()
+}
+
+// Subscribe 订阅频道并记录，断线重连后自动重新订阅；未连接时只记录
+func (ws *WsService) Subscribe(channel string, payload []string) error {
+	msg := NewMsg(channel, "subscribe", clock.Get(exchange.Gate).Now().Unix(), payload)
+
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	ws.subscriptions = append(ws.subscriptions, msg)
+	if ws.status != connected {
+		return nil
+	}
+	return ws.write(msg)
+}
+
+// Unsubscribe 取消订阅，并移除 payload 完全相同的订阅记录
+func (ws *WsService) Unsubscribe(channel string, payload []string) error {
+	return ws.unsubscribe(channel, payload, func(sub []string) []string {
+		if equalPayload(sub, payload) {
+			return nil
+		}
+		return sub
+	})
+}
+
+// UnsubscribeMarkets 用于 payload 为市场列表的频道(如 futures.tickers)，从订阅记录中移除这些市场
+func (ws *WsService) UnsubscribeMarkets(channel string, markets []string) error {
+	drop := make(map[string]bool, len(markets))
+	for _, market := range markets {
+		drop[market] = true
+	}
+	return ws.unsubscribe(channel, markets, func(sub []string) []string {
+		left := make([]string, 0, len(sub))
+		for _, market := range sub {
+			if !drop[market] {
+				left = append(left, market)
+			}
+		}
+		return left
+	})
+}
+
+// unsubscribe filter 返回订阅记录剩余的 payload，为空时移除该记录
+func (ws *WsService) unsubscribe(channel string, payload []string, filter func([]string) []string) error {
+	msg := NewMsg(channel, "unsubscribe", clock.Get(exchange.Gate).Now().Unix(), payload)
+
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	subs := ws.subscriptions[:0]
+	for _, sub := range ws.subscriptions {
+		if sub.Channel == channel {
+			if sub.Payload = filter(sub.Payload); len(sub.Payload) == 0 {
+				continue
+			}
+		}
+		subs = append(subs, sub)
+	}
+	ws.subscriptions = subs
+	if ws.status != connected {
+		return nil
+	}
+	return ws.write(msg)
+}
+
+func equalPayload(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// write 调用方需持有 mu，每次发送都用当前时间重新签名
+func (ws *WsService) write(msg *Msg) error {
+	msg.Time = clock.Get(exchange.Gate).Now().Unix()
+	msg.sign(ws.conf.Key, ws.conf.Secret)
+	return msg.send(ws.client)
+}
+
+func (ws *WsService) dial() (*websocket.Conn, error) {
+	c, _, err := websocket.DefaultDialer.Dial(ws.conf.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// connect 按退避重试建立连接，成功后重新发送所有订阅
+func (ws *WsService) connect() error {
+	ws.mu.Lock()
+	if ws.client != nil {
+		ws.client.Close()
+	}
+	ws.status = reconnecting
+	ws.mu.Unlock()
+
+	interval := defaultRetryInterval
+	retry := 0
+	var conn *websocket.Conn
+	for {
+		c, err := ws.dial()
+		if err == nil {
+			conn = c
+			break
+		}
+		if ws.conf.MaxRetryConn > 0 && retry >= ws.conf.MaxRetryConn {
+			ws.mu.Lock()
+			ws.status = disconnected
+			ws.mu.Unlock()
+			ws.logger.Errorf("gate ws max reconnect time %d reached, give it up, err:%+v", ws.conf.MaxRetryConn, err)
+			return err
+		}
+		retry++
+		ws.logger.Warningf("gate ws failed to connect for the %d time, retry after %s, err:%+v", retry, interval, err)
+		time.Sleep(interval)
+		interval *= 2
+		if interval > maxRetryInterval {
+			interval = maxRetryInterval
+		}
+	}
+	if retry > 0 {
+		ws.logger.Warningf("gate ws reconnect succeeded after retrying %d times", retry)
+	}
+
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	ws.client = conn
+	ws.status = connected
+	conn.SetReadDeadline(time.Now().Add(3 * ws.conf.PingInterval))
+	// resubscribe after reconnect
+	for _, msg := range ws.subscriptions {
+		if err := ws.write(msg); err != nil {
+			ws.logger.Warningf("gate ws failed to subscribe at reconnect, channel:%s, err:%+v", msg.Channel, err)
+		}
+	}
+	return nil
+}
+
+func (ws *WsService) getClient() *websocket.Conn {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	return ws.client
+}
+
+func (ws *WsService) readMsg() {
+	defer close(ws.msgChan)
+
+	for {
+		client := ws.getClient()
+		_, message, err := client.ReadMessage()
+		if err != nil {
+			ws.logger.Warningf("gate ws read err:%+v, reconnecting", err)
+			if err = ws.connect(); err != nil {
+				return
+			}
+			continue
+		}
+		// 任何消息(包括 futures.pong)都说明连接正常
+		client.SetReadDeadline(time.Now().Add(3 * ws.conf.PingInterval))
+		ws.msgChan <- message
+	}
+}
+
+// ping 应用层心跳，断线期间跳过
+func (ws *WsService) ping() {
+	ticker := time.NewTicker(ws.conf.PingInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		ws.mu.Lock()
+		if ws.status == disconnected {
+			ws.mu.Unlock()
+			return
+		}
+		if ws.status == connected {
+			body, _ := json.Marshal(&Msg{Time: time.Now().Unix(), Channel: "futures.ping"})
+			if err := ws.client.WriteMessage(websocket.TextMessage, body); err != nil {
+				ws.logger.Warningf("gate ws ping err:%+v", err)
+			}
+		}
+		ws.mu.Unlock()
+	}
+}
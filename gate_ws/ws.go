@@ -3,15 +3,18 @@ package gate_ws
 import (
 	"crypto/hmac"
 	"crypto/sha512"
-	"crypto/tls"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"github.com/bitly/go-simplejson"
-	"github.com/shopspring/decimal"
 	"io"
+	"move_profit/alert"
+	"move_profit/config"
+	"move_profit/exchange"
 	"move_profit/gate_api"
-	"net/url"
+	"move_profit/jsonscan"
+	"move_profit/log"
+	"move_profit/quote"
+	"move_profit/strategy"
 	"sync"
 	"time"
 
@@ -41,9 +44,9 @@ type Ticker struct {
 type Msg struct {
 	Time    int64    `json:"time"`
 	Channel string   `json:"channel"`
-	Event   string   `json:"event"`
-	Payload []string `json:"payload"`
-	Auth    *Auth    `json:"auth"`
+	Event   string   `json:"event,omitempty"`
+	Payload []string `json:"payload,omitempty"`
+	Auth    *Auth    `json:"auth,omitempty"`
 }
 
 type Auth struct {
@@ -52,23 +55,30 @@ type Auth struct {
 	SIGN   string `json:"SIGN"`
 }
 
-const (
-	Key    = "YOUR_API_KEY"
-	Secret = "YOUR_API_SECRETY"
+var (
+	wsUrl  string
+	key    string
+	secret string
 )
 
-func sign(channel, event string, t int64) string {
+func InitGateWs(url, apiKey, apiSecret string) {
+	wsUrl = url
+	key = apiKey
+	secret = apiSecret
+}
+
+func sign(secret, channel, event string, t int64) string {
 	message := fmt.Sprintf("channel=%s&event=%s&time=%d", channel, event, t)
-	h2 := hmac.New(sha512.New, []byte(Secret))
+	h2 := hmac.New(sha512.New, []byte(secret))
 	io.WriteString(h2, message)
 	return hex.EncodeToString(h2.Sum(nil))
 }
 
-func (msg *Msg) sign() {
-	signStr := sign(msg.Channel, msg.Event, msg.Time)
+func (msg *Msg) sign(key, secret string) {
+	signStr := sign(secret, msg.Channel, msg.Event, msg.Time)
 	msg.Auth = &Auth{
 		Method: "api_key",
-		KEY:    Key,
+		KEY:    key,
 		SIGN:   signStr,
 	}
 }
@@ -90,62 +100,245 @@ func NewMsg(channel, event string, t int64, payload []string) *Msg {
 	}
 }
 
-func GateTicker() {
-	u := url.URL{Scheme: "wss", Host: "fx-ws.gateio.ws", Path: "/v4/ws/usdt"}
-	websocket.DefaultDialer.TLSClientConfig = &tls.Config{RootCAs: nil, InsecureSkipVerify: true}
-	c, _, err := websocket.DefaultDialer.Dial(u.String(), nil)
+// GateTicker 订阅全市场 ticker 和盘口，以及 depthMarkets 的前 N 档深度
+func GateTicker(depthMarkets []string) {
+	conf := config.Conf.Gate
+	server, err := NewWsService(log.Log, &ConnConf{
+		URL:          wsUrl,
+		Key:          key,
+		Secret:       secret,
+		MaxRetryConn: conf.WsMaxRetry,
+		PingInterval: conf.WsPingInterval.Duration(),
+		MsgChanLen:   5000,
+	})
 	if err != nil {
-		panic(err)
+		log.Log.Errorf("gate ws init err:%+v", err)
+		return
+	}
+	if err = server.Start(); err != nil {
+		alert.Send("gate ws connect failed, gate market data stopped err:%+v", err)
+		return
 	}
-	c.SetPingHandler(nil)
 
-	// read msg
-	go func() {
-		for {
-			_, message, err := c.ReadMessage()
-			if err != nil {
-				c.Close()
-				panic(err)
-			}
-			data, err := simplejson.NewJson(message)
-			if err != nil {
-				continue
-			}
-			channel, _ := data.Get("channel").String()
-			if channel != "futures.tickers" {
-				continue
-			}
-			event, _ := data.Get("event").String()
-			if event != "update" {
-				continue
+	pubMu.Lock()
+	pubServer = server
+	pubMu.Unlock()
+	SetMarkets(gate_api.GetMarketList())
+	SetDepthMarkets(depthMarkets)
+
+	d := new(pubDecoder)
+	for message := range server.GetMsgChan() {
+		d.process(message)
+	}
+	alert.Send("gate ws reconnect failed, gate market data stopped")
+}
+
+var (
+	pubMu      sync.Mutex
+	pubServer  *WsService
+	tickerSubs = make(map[string]bool) // 已订阅 ticker 和盘口的市场
+	depthSubs  = make(map[string]bool) // 已订阅深度的市场
+)
+
+// SetMarkets 订阅新上线市场的 ticker 和盘口，取消已下线市场的订阅；公共连接还没建立时由建立后的初始订阅处理
+func SetMarkets(markets []string) {
+	pubMu.Lock()
+	defer pubMu.Unlock()
+	if pubServer == nil {
+		return
+	}
+
+	added, removed := diffMarkets(tickerSubs, markets)
+	if len(tickerSubs) > 0 && len(added)+len(removed) > 0 {
+		log.Log.Warningf("gate ticker markets added:%v removed:%v", added, removed)
+	}
+	if len(added) > 0 {
+		for _, channel := range []string{"futures.tickers", "futures.book_ticker"} {
+			if err := pubServer.Subscribe(channel, added); err != nil {
+				log.Log.Errorf("gate ws subscribe %s err:%+v", channel, err)
 			}
-			resultList, _ := data.Get("result").Array()
-			for _, result := range resultList {
-				resultMap := result.(map[string]interface{})
-				market := resultMap["contract"]
-				lastPrice := resultMap["last"]
-				price := lastPrice.(string)
-				priceD, _ := decimal.NewFromString(price)
-				GateLastPriceMap.Store(market.(string), priceD)
+		}
+	}
+	if len(removed) > 0 {
+		for _, channel := range []string{"futures.tickers", "futures.book_ticker"} {
+			if err := pubServer.UnsubscribeMarkets(channel, removed); err != nil {
+				log.Log.Errorf("gate ws unsubscribe %s err:%+v", channel, err)
 			}
 		}
-	}()
+	}
+	// 订阅会被记录并在重连后重新发送，写失败也视为已订阅
+	for _, market := range added {
+		tickerSubs[market] = true
+	}
+	for _, market := range removed {
+		delete(tickerSubs, market)
+	}
+}
 
-	t := time.Now().Unix()
-	marketInfoList, err := gate_api.GetGateMarketInfo()
-	if len(marketInfoList) <= 0 {
+// SetDepthMarkets 订阅新增市场的深度，取消已移除市场的深度
+func SetDepthMarkets(markets []string) {
+	pubMu.Lock()
+	defer pubMu.Unlock()
+	if pubServer == nil {
 		return
 	}
-	marketNameList := make([]string, 0, len(marketInfoList))
-	for _, m := range marketInfoList {
-		marketNameList = append(marketNameList, m.Name)
+
+	added, removed := diffMarkets(depthSubs, markets)
+	if len(depthSubs) > 0 && len(added)+len(removed) > 0 {
+		log.Log.Warningf("gate depth markets added:%v removed:%v", added, removed)
+	}
+	for _, market := range added {
+		if err := pubServer.Subscribe("futures.order_book", orderBookPayload(market)); err != nil {
+			log.Log.Errorf("gate ws subscribe order_book %s err:%+v", market, err)
+		}
+		depthSubs[market] = true
+	}
+	for _, market := range removed {
+		if err := pubServer.Unsubscribe("futures.order_book", orderBookPayload(market)); err != nil {
+			log.Log.Errorf("gate ws unsubscribe order_book %s err:%+v", market, err)
+		}
+		delete(depthSubs, market)
+	}
+}
+
+func orderBookPayload(market string) []string {
+	return []string{market, fmt.Sprintf("%d", config.Conf.Strategy.DepthLevels), "0"}
+}
+
+// diffMarkets 相对已订阅的 subs，markets 中新增和缺少的市场
+func diffMarkets(subs map[string]bool, markets []string) (added, removed []string) {
+	want := make(map[string]bool, len(markets))
+	for _, market := range markets {
+		want[market] = true
+		if !subs[market] {
+			added = append(added, market)
+		}
+	}
+	for market := range subs {
+		if !want[market] {
+			removed = append(removed, market)
+		}
+	}
+	return added, removed
+}
+
+// OnMarketChange 合约乘数可能变化，清除缓存
+func OnMarketChange(changes []exchange.MarketChange) {
+	multiplierMu.Lock()
+	defer multiplierMu.Unlock()
+
+	for _, c := range changes {
+		if c.Venue == exchange.Gate && c.Kind != exchange.MarketListed {
+			delete(multiplierCache, c.Market)
+		}
+	}
+}
+
+// pubDecoder 公共频道各类消息的解码缓冲，在消息间复用以减少分配，只能在一个 goroutine 中使用
+type pubDecoder struct {
+	msg        pubMsg
+	bookTicker bookTickerMsg
+	orderBook  orderBookMsg
+	ticker     tickerMsg
+}
+
+func (d *pubDecoder) process(message []byte) {
+	if err := d.msg.decode(message); err != nil {
+		log.Log.Errorf("gate ws parse msg:[%s] err:%+v", string(message), err)
+		return
+	}
+	channel, event := string(d.msg.channel), string(d.msg.event)
+	if !jsonscan.IsNull(d.msg.err) {
+		log.Log.Errorf("gate ws channel:%s event:%s err:%s", channel, event, string(message))
+		return
+	}
+	var err error
+	switch {
+	// order_book 订阅 interval 为 0 时推送的是完整快照
+	case channel == "futures.order_book" && event == "all":
+		err = d.processOrderBook()
+	case channel == "futures.book_ticker" && event == "update":
+		err = d.processBookTicker()
+	case channel == "futures.tickers" && event == "update":
+		err = d.processTicker()
 	}
-	tickerMsg := NewMsg("futures.tickers", "subscribe", t, marketNameList)
-	tickerMsg.sign()
-	err = tickerMsg.send(c)
 	if err != nil {
-		panic(err)
+		log.Log.Errorf("gate ws decode %s msg:[%s] err:%+v", channel, string(message), err)
+	}
+}
+
+// processTicker 记录最新价和资金费率，funding_rate 为下一次结算使用的费率
+func (d *pubDecoder) processTicker() error {
+	now := time.Now()
+	var err error
+	arrErr := jsonscan.ArrayEach(d.msg.result, func(value []byte) bool {
+		if err = d.ticker.decode(value); err != nil {
+			return false
+		}
+		market := markets.String(d.ticker.market)
+		GateLastPriceMap.Store(market, d.ticker.last.Decimal())
+		if !d.ticker.hasFundingRate {
+			return true
+		}
+
+		rate := d.ticker.fundingRate.Decimal()
+		// 费率没变且结算时间未到时不必重新计算结算时间
+		if prev, ok := quote.GetFunding(exchange.Gate, market); ok && prev.Rate.Equal(rate) && prev.NextTime.After(now) {
+			return true
+		}
+		contract, ok := gate_api.GetMarketInfo(market)
+		if !ok {
+			return true
+		}
+		funding := quote.Funding{Rate: rate, Interval: time.Duration(contract.FundingInterval) * time.Second}
+		// 元数据中的下次结算时间是拉取时的快照，按结算间隔推到当前时间之后
+		funding.NextTime = time.Unix(int64(contract.FundingNextApply), 0)
+		for funding.Interval > 0 && !funding.NextTime.After(now) {
+			funding.NextTime = funding.NextTime.Add(funding.Interval)
+		}
+		quote.StoreFunding(exchange.Gate, market, funding)
+		return true
+	})
+	if arrErr != nil {
+		return arrErr
 	}
+	return err
+}
 
-	select {}
+// processBookTicker 挂单数量为合约张数，按 QuantoMultiplier 换算为基础币数量
+func (d *pubDecoder) processBookTicker() error {
+	m := &d.bookTicker
+	if err := m.decode(d.msg.result); err != nil {
+		return err
+	}
+	market := markets.String(m.market)
+	multiplier, ok := multiplierOf(market)
+	if !ok || !m.bid.IsPositive() || !m.ask.IsPositive() {
+		return nil
+	}
+	quote.StoreBookTicker(exchange.Gate, market, quote.BookTicker{
+		Bid:     m.bid.Decimal(),
+		BidSize: m.bidSize.Decimal().Mul(multiplier),
+		Ask:     m.ask.Decimal(),
+		AskSize: m.askSize.Decimal().Mul(multiplier),
+	})
+	strategy.OnTick(market)
+	return nil
+}
+
+func (d *pubDecoder) processOrderBook() error {
+	m := &d.orderBook
+	if err := m.decode(d.msg.result); err != nil {
+		return err
+	}
+	market := markets.String(m.market)
+	multiplier, ok := multiplierOf(market)
+	if !ok {
+		return nil
+	}
+	quote.StoreOrderBook(exchange.Gate, market, quote.OrderBook{
+		Bids: quoteLevels(m.bids, multiplier),
+		Asks: quoteLevels(m.asks, multiplier),
+	})
+	return nil
 }
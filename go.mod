@@ -8,7 +8,6 @@ require (
 	github.com/bitly/go-simplejson v0.5.1
 	github.com/gateio/gateapi-go/v6 v6.60.1
 	github.com/gorilla/websocket v1.5.1
-	github.com/levigross/grequests v0.0.0-20231203190023-9c307ef1f48d
 	github.com/op/go-logging v0.0.0-20160315200505-970db520ece7
 	github.com/robfig/cron v1.2.0
 	github.com/shopspring/decimal v1.3.1
@@ -0,0 +1,306 @@
+// Package jsonscan 行情推送的低分配 json 扫描：直接在原始字节上遍历字段，不构建中间对象，
+// 回调拿到的 key/value 都是原始消息的子切片，只在回调内有效
+package jsonscan
+
+import (
+	"errors"
+	"math"
+	"sync"
+
+	"github.com/shopspring/decimal"
+)
+
+var ErrSyntax = errors.New("jsonscan: syntax error")
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func skipSpace(data []byte, i int) int {
+	for i < len(data) && isSpace(data[i]) {
+		i++
+	}
+	return i
+}
+
+// stringEnd data[i] 为 '"'，返回字符串结束引号之后的位置
+func stringEnd(data []byte, i int) (int, error) {
+	for j := i + 1; j < len(data); j++ {
+		switch data[j] {
+		case '\\':
+			j++
+		case '"':
+			return j + 1, nil
+		}
+	}
+	return 0, ErrSyntax
+}
+
+// valueEnd 返回从 data[i] 开始的 json 值结束之后的位置
+func valueEnd(data []byte, i int) (int, error) {
+	switch data[i] {
+	case '"':
+		return stringEnd(data, i)
+	case '{', '[':
+		depth := 0
+		for j := i; j < len(data); j++ {
+			switch data[j] {
+			case '"':
+				end, err := stringEnd(data, j)
+				if err != nil {
+					return 0, err
+				}
+				j = end - 1
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+				if depth == 0 {
+					return j + 1, nil
+				}
+			}
+		}
+		return 0, ErrSyntax
+	default:
+		j := i
+		for j < len(data) {
+			c := data[j]
+			if c == ',' || c == '}' || c == ']' || isSpace(c) {
+				break
+			}
+			j++
+		}
+		// 缺少值，如 {"a":} 或 [1,]
+		if j == i {
+			return 0, ErrSyntax
+		}
+		return j, nil
+	}
+}
+
+// ObjectEach 遍历对象的顶层字段，fn 返回 false 时停止
+func ObjectEach(data []byte, fn func(key, value []byte) bool) error {
+	i := skipSpace(data, 0)
+	if i >= len(data) || data[i] != '{' {
+		return ErrSyntax
+	}
+	i = skipSpace(data, i+1)
+	if i < len(data) && data[i] == '}' {
+		return nil
+	}
+	for i < len(data) {
+		if data[i] != '"' {
+			return ErrSyntax
+		}
+		end, err := stringEnd(data, i)
+		if err != nil {
+			return err
+		}
+		key := data[i+1 : end-1]
+		i = skipSpace(data, end)
+		if i >= len(data) || data[i] != ':' {
+			return ErrSyntax
+		}
+		i = skipSpace(data, i+1)
+		if i >= len(data) {
+			return ErrSyntax
+		}
+		end, err = valueEnd(data, i)
+		if err != nil {
+			return err
+		}
+		if !fn(key, data[i:end]) {
+			return nil
+		}
+		i = skipSpace(data, end)
+		if i >= len(data) {
+			return ErrSyntax
+		}
+		if data[i] == '}' {
+			return nil
+		}
+		if data[i] != ',' {
+			return ErrSyntax
+		}
+		i = skipSpace(data, i+1)
+	}
+	return ErrSyntax
+}
+
+// ArrayEach 遍历数组元素，fn 返回 false 时停止
+func ArrayEach(data []byte, fn func(value []byte) bool) error {
+	i := skipSpace(data, 0)
+	if i >= len(data) || data[i] != '[' {
+		return ErrSyntax
+	}
+	i = skipSpace(data, i+1)
+	if i < len(data) && data[i] == ']' {
+		return nil
+	}
+	for i < len(data) {
+		end, err := valueEnd(data, i)
+		if err != nil {
+			return err
+		}
+		if !fn(data[i:end]) {
+			return nil
+		}
+		i = skipSpace(data, end)
+		if i >= len(data) {
+			return ErrSyntax
+		}
+		if data[i] == ']' {
+			return nil
+		}
+		if data[i] != ',' {
+			return ErrSyntax
+		}
+		i = skipSpace(data, i+1)
+	}
+	return ErrSyntax
+}
+
+// IsArray 值是否为数组
+func IsArray(data []byte) bool {
+	i := skipSpace(data, 0)
+	return i < len(data) && data[i] == '['
+}
+
+// IsNull 值为空或 null
+func IsNull(value []byte) bool {
+	return len(value) == 0 || string(value) == "null"
+}
+
+// Unquote 去掉字符串两端的引号，不处理转义(行情中的市场名和数字不含转义)
+func Unquote(value []byte) []byte {
+	if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// Num 定点数，值为 Mantissa * 10^Exp
+type Num struct {
+	Mantissa int64
+	Exp      int32
+}
+
+func (n Num) Decimal() decimal.Decimal {
+	return decimal.New(n.Mantissa, n.Exp)
+}
+
+func (n Num) IsPositive() bool {
+	return n.Mantissa > 0
+}
+
+// ParseNum 解析数字或数字字符串，超出 int64 精度的低位数字被舍弃
+func ParseNum(value []byte) (Num, bool) {
+	b := Unquote(value)
+	if len(b) == 0 {
+		return Num{}, false
+	}
+	i := 0
+	neg := false
+	if b[0] == '-' || b[0] == '+' {
+		neg = b[0] == '-'
+		i++
+	}
+
+	var n Num
+	digits, dot := false, false
+	for ; i < len(b); i++ {
+		c := b[i]
+		if c == '.' {
+			if dot {
+				return Num{}, false
+			}
+			dot = true
+			continue
+		}
+		if c < '0' || c > '9' {
+			break
+		}
+		digits = true
+		if n.Mantissa > (math.MaxInt64-9)/10 {
+			// 精度溢出，整数部分的低位按指数补回，小数部分直接舍弃
+			if !dot {
+				n.Exp++
+			}
+			continue
+		}
+		n.Mantissa = n.Mantissa*10 + int64(c-'0')
+		if dot {
+			n.Exp--
+		}
+	}
+	if !digits {
+		return Num{}, false
+	}
+	if i < len(b) {
+		if b[i] != 'e' && b[i] != 'E' {
+			return Num{}, false
+		}
+		exp, ok := ParseInt(b[i+1:])
+		if !ok || exp > math.MaxInt16 || exp < math.MinInt16 {
+			return Num{}, false
+		}
+		n.Exp += int32(exp)
+	}
+	if neg {
+		n.Mantissa = -n.Mantissa
+	}
+	return n, true
+}
+
+// ParseInt 解析整数或整数字符串
+func ParseInt(value []byte) (int64, bool) {
+	b := Unquote(value)
+	if len(b) == 0 {
+		return 0, false
+	}
+	i := 0
+	neg := false
+	if b[0] == '-' || b[0] == '+' {
+		neg = b[0] == '-'
+		i++
+	}
+	if i >= len(b) {
+		return 0, false
+	}
+	var n int64
+	for ; i < len(b); i++ {
+		c := b[i]
+		if c < '0' || c > '9' || n > (math.MaxInt64-9)/10 {
+			return 0, false
+		}
+		n = n*10 + int64(c-'0')
+	}
+	if neg {
+		n = -n
+	}
+	return n, true
+}
+
+// Intern 缓存 []byte 到 string 的转换，市场名等取值有限的字段每条消息不再分配
+type Intern struct {
+	mu sync.RWMutex
+	m  map[string]string
+}
+
+func (in *Intern) String(b []byte) string {
+	in.mu.RLock()
+	s, ok := in.m[string(b)]
+	in.mu.RUnlock()
+	if ok {
+		return s
+	}
+
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if in.m == nil {
+		in.m = make(map[string]string)
+	}
+	s = string(b)
+	in.m[s] = s
+	return s
+}
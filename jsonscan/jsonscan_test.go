@@ -0,0 +1,271 @@
+package jsonscan
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+type field struct {
+	key, value string
+}
+
+func TestObjectEach(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    []field
+		wantErr bool
+	}{
+		{name: "empty", data: `{}`},
+		{name: "empty with spaces", data: " \n{ \t}\r\n"},
+		{
+			name: "scalars",
+			data: `{"s":"BTCUSDT","T":1568014460891,"b":"25.35","ok":true,"no":false,"r":null,"n":-1.5e-3}`,
+			want: []field{{"s", `"BTCUSDT"`}, {"T", "1568014460891"}, {"b", `"25.35"`}, {"ok", "true"}, {"no", "false"}, {"r", "null"}, {"n", "-1.5e-3"}},
+		},
+		{
+			name: "spaces around tokens",
+			data: "{ \"a\" :\t1 ,\n\"b\" : \"x\" }",
+			want: []field{{"a", "1"}, {"b", `"x"`}},
+		},
+		{
+			name: "escaped quotes and backslashes",
+			data: `{"a\"b":"c\"d","e":"f\\","g":"\\\"","h":"中"}`,
+			want: []field{{`a\"b`, `"c\"d"`}, {"e", `"f\\"`}, {"g", `"\\\""`}, {"h", `"中"`}},
+		},
+		{
+			name: "brackets inside strings",
+			data: `{"a":"}]{[","b":{"c":"}"}}`,
+			want: []field{{"a", `"}]{["`}, {"b", `{"c":"}"}`}},
+		},
+		{
+			name: "nested objects and arrays",
+			data: `{"b":[["7403.89","0.002"],["7403.90","1"]],"o":{"x":[1,{"y":[]}],"z":{}},"e":[]}`,
+			want: []field{{"b", `[["7403.89","0.002"],["7403.90","1"]]`}, {"o", `{"x":[1,{"y":[]}],"z":{}}`}, {"e", "[]"}},
+		},
+		{name: "not an object", data: `[1,2]`, wantErr: true},
+		{name: "empty input", data: ``, wantErr: true},
+		{name: "only spaces", data: "  ", wantErr: true},
+		{name: "missing colon", data: `{"a" 1}`, wantErr: true},
+		{name: "missing comma", data: `{"a":1 "b":2}`, wantErr: true},
+		{name: "unquoted key", data: `{a:1}`, wantErr: true},
+		{name: "trailing comma", data: `{"a":1,}`, wantErr: true},
+		{name: "missing value", data: `{"a":}`, wantErr: true},
+		{name: "missing value before comma", data: `{"a":,"b":1}`, wantErr: true},
+		{name: "truncated after brace", data: `{`, wantErr: true},
+		{name: "truncated in key", data: `{"a`, wantErr: true},
+		{name: "truncated after key", data: `{"a"`, wantErr: true},
+		{name: "truncated after colon", data: `{"a":`, wantErr: true},
+		{name: "truncated in string", data: `{"a":"bc`, wantErr: true},
+		{name: "truncated after escape", data: `{"a":"bc\`, wantErr: true},
+		{name: "truncated in number", data: `{"a":12`, wantErr: true},
+		{name: "truncated in nested", data: `{"a":[[1,2],[3`, wantErr: true},
+		{name: "truncated in nested string", data: `{"a":{"b":"}`, wantErr: true},
+		{name: "truncated before brace", data: `{"a":1,"b":"c"`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []field
+			err := ObjectEach([]byte(tt.data), func(key, value []byte) bool {
+				got = append(got, field{string(key), string(value)})
+				return true
+			})
+			if tt.wantErr {
+				if !errors.Is(err, ErrSyntax) {
+					t.Errorf("err = %v, want ErrSyntax", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("err:%v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("fields = %q, want %q", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("field %d = %q, want %q", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestObjectEachStop(t *testing.T) {
+	var keys []string
+	// 停止后不再检查剩余部分
+	err := ObjectEach([]byte(`{"a":1,"b":2,"c":`), func(key, value []byte) bool {
+		keys = append(keys, string(key))
+		return string(key) != "b"
+	})
+	if err != nil || strings.Join(keys, ",") != "a,b" {
+		t.Errorf("keys = %v err:%v", keys, err)
+	}
+}
+
+func TestArrayEach(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    []string
+		wantErr bool
+	}{
+		{name: "empty", data: `[]`},
+		{name: "empty with spaces", data: ` [ ] `},
+		{name: "scalars", data: `[1,"a",null,true,-0.5]`, want: []string{"1", `"a"`, "null", "true", "-0.5"}},
+		{name: "spaces", data: "[ 1 ,\n 2 ]", want: []string{"1", "2"}},
+		{name: "nested", data: `[["1","2"],{"p":"3","s":[4]},[]]`, want: []string{`["1","2"]`, `{"p":"3","s":[4]}`, "[]"}},
+		{name: "escaped string", data: `["a\"]","\\"]`, want: []string{`"a\"]"`, `"\\"`}},
+		{name: "not an array", data: `{"a":1}`, wantErr: true},
+		{name: "empty input", data: ``, wantErr: true},
+		{name: "missing comma", data: `[1 2]`, wantErr: true},
+		{name: "trailing comma", data: `[1,]`, wantErr: true},
+		{name: "leading comma", data: `[,1]`, wantErr: true},
+		{name: "truncated after bracket", data: `[`, wantErr: true},
+		{name: "truncated after value", data: `[1,2`, wantErr: true},
+		{name: "truncated in string", data: `["ab`, wantErr: true},
+		{name: "truncated in nested", data: `[[1,2],[3,4]`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			err := ArrayEach([]byte(tt.data), func(value []byte) bool {
+				got = append(got, string(value))
+				return true
+			})
+			if tt.wantErr {
+				if !errors.Is(err, ErrSyntax) {
+					t.Errorf("err = %v, want ErrSyntax", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("err:%v", err)
+			}
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") || len(got) != len(tt.want) {
+				t.Errorf("values = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseNum(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+		ok    bool
+	}{
+		{`0`, "0", true},
+		{`"0"`, "0", true},
+		{`-0`, "0", true},
+		{`"25.35190000"`, "25.3519", true},
+		{`54696.6`, "54696.6", true},
+		{`"-0.00038167"`, "-0.00038167", true},
+		{`+1.5`, "1.5", true},
+		{`.5`, "0.5", true},
+		{`5.`, "5", true},
+		{`1e3`, "1000", true},
+		{`1.5E-3`, "0.0015", true},
+		{`"2e+2"`, "200", true},
+		{`-1.25e1`, "-12.5", true},
+		{`37000`, "37000", true},
+		// 超出 int64 的整数低位按指数补回，小数低位舍弃
+		{`123456789012345678901234`, "123456789012345678900000", true},
+		{`"0.12345678901234567890123"`, "0.1234567890123456789", true},
+		{`1.2345678901234567890e5`, "123456.7890123456789", true},
+		{``, "", false},
+		{`""`, "", false},
+		{`null`, "", false},
+		{`true`, "", false},
+		{`-`, "", false},
+		{`.`, "", false},
+		{`"-."`, "", false},
+		{`1.2.3`, "", false},
+		{`12a`, "", false},
+		{`1e`, "", false},
+		{`1e+`, "", false},
+		{`1e1.5`, "", false},
+		{`1e99999`, "", false},
+		{`"1 "`, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, ok := ParseNum([]byte(tt.value))
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v", ok, tt.ok)
+			}
+			if ok && !got.Decimal().Equal(decimal.RequireFromString(tt.want)) {
+				t.Errorf("num = %s, want %s", got.Decimal(), tt.want)
+			}
+		})
+	}
+}
+
+func TestParseInt(t *testing.T) {
+	tests := []struct {
+		value string
+		want  int64
+		ok    bool
+	}{
+		{`0`, 0, true},
+		{`1568014460891`, 1568014460891, true},
+		{`"1568014460891"`, 1568014460891, true},
+		{`-42`, -42, true},
+		{`+7`, 7, true},
+		{`922337203685477579`, 922337203685477579, true},
+		{`9223372036854775807`, 0, false},
+		{``, 0, false},
+		{`""`, 0, false},
+		{`-`, 0, false},
+		{`null`, 0, false},
+		{`1.5`, 0, false},
+		{`1e3`, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, ok := ParseInt([]byte(tt.value))
+			if ok != tt.ok || (ok && got != tt.want) {
+				t.Errorf("ParseInt = %d %v, want %d %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestHelpers(t *testing.T) {
+	unquote := map[string]string{
+		`"BTC_USDT"`: "BTC_USDT",
+		`""`:         "",
+		`"`:          `"`,
+		`12`:         "12",
+		`null`:       "null",
+		``:           "",
+	}
+	for in, want := range unquote {
+		if got := string(Unquote([]byte(in))); got != want {
+			t.Errorf("Unquote(%q) = %q, want %q", in, got, want)
+		}
+	}
+
+	for in, want := range map[string]bool{``: true, `null`: true, `"null"`: false, `0`: false, `{}`: false} {
+		if got := IsNull([]byte(in)); got != want {
+			t.Errorf("IsNull(%q) = %v, want %v", in, got, want)
+		}
+	}
+
+	for in, want := range map[string]bool{`[1]`: true, " \n[]": true, `{}`: false, `"["`: false, ``: false} {
+		if got := IsArray([]byte(in)); got != want {
+			t.Errorf("IsArray(%q) = %v, want %v", in, got, want)
+		}
+	}
+
+	var in Intern
+	b := []byte("BTC_USDT")
+	s := in.String(b)
+	b[0] = 'X'
+	if s != "BTC_USDT" || in.String([]byte("BTC_USDT")) != "BTC_USDT" || in.String(b) != "XTC_USDT" {
+		t.Errorf("Intern must copy the bytes, got %q", s)
+	}
+}
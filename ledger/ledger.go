@@ -0,0 +1,114 @@
+package ledger
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"move_profit/exchange"
+)
+
+// Fill 一条腿的成交，Price 为成交均价
+type Fill struct {
+	Venue    string          `json:"venue"`
+	Side     exchange.Side   `json:"side"`
+	Quantity decimal.Decimal `json:"quantity"`
+	Price    decimal.Decimal `json:"price"`
+}
+
+// Record 一次完整的开平仓，部分平仓时按本次平掉的数量记录，开仓手续费和资金费按数量分摊
+type Record struct {
+	Market    string          `json:"market"`
+	GateSide  exchange.Side   `json:"gate_side"` // 开仓时 gate 腿的方向
+	Paper     bool            `json:"paper"`
+	OpenTime  time.Time       `json:"open_time"`
+	CloseTime time.Time       `json:"close_time"`
+	Entry     []Fill          `json:"entry"`
+	Exit      []Fill          `json:"exit"`
+	EntryRate decimal.Decimal `json:"entry_rate"` // 开仓实际成交价差
+	ExitRate  decimal.Decimal `json:"exit_rate"`  // 平仓实际成交价差
+	Gross     decimal.Decimal `json:"gross"`      // 两腿价格盈亏之和(USDT)
+	Fees      decimal.Decimal `json:"fees"`       // 开平仓手续费(USDT)
+	Funding   decimal.Decimal `json:"funding"`    // 持仓期间的资金费，正为收取(USDT)
+	Net       decimal.Decimal `json:"net"`        // Gross - Fees + Funding
+}
+
+// Ledger 每条记录一行 json，只追加不修改
+type Ledger struct {
+	mu   sync.Mutex
+	path string
+}
+
+func NewLedger(path string) *Ledger {
+	return &Ledger{path: path}
+}
+
+// Append 追加一条记录并刷盘，l 为空时不记录
+func (l *Ledger) Append(r Record) error {
+	if l == nil {
+		return nil
+	}
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err = os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Load 读取全部记录，文件不存在时返回空。最后一行不完整(写入时崩溃)时忽略该行
+func Load(path string) ([]Record, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var (
+		records []Record
+		bad     error
+	)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		// 不完整的行之后还有记录，说明文件已损坏
+		if bad != nil {
+			return nil, bad
+		}
+		var r Record
+		if err = json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			bad = fmt.Errorf("ledger %s line %d err:%+v", path, n, err)
+			continue
+		}
+		records = append(records, r)
+	}
+	return records, scanner.Err()
+}
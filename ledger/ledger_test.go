@@ -0,0 +1,73 @@
+package ledger
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func dec(s string) decimal.Decimal {
+	return decimal.RequireFromString(s)
+}
+
+func record(market string, closeTime time.Time, gross, fees, funding string) Record {
+	r := Record{Market: market, CloseTime: closeTime, Gross: dec(gross), Fees: dec(fees), Funding: dec(funding)}
+	r.Net = r.Gross.Sub(r.Fees).Add(r.Funding)
+	return r
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "ledger", "records.jsonl")
+
+	records, err := Load(path)
+	if err != nil || records != nil {
+		t.Fatalf("missing file records = %+v err:%v", records, err)
+	}
+
+	l := NewLedger(path)
+	for _, m := range []string{"BTC_USDT", "ETH_USDT"} {
+		if err = l.Append(record(m, time.Now(), "1", "0.1", "0")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var nilLedger *Ledger
+	if err = nilLedger.Append(Record{}); err != nil {
+		t.Errorf("nil ledger err:%v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		content string
+		want    int
+		wantErr bool
+	}{
+		{name: "complete", content: string(data), want: 2},
+		{name: "empty lines", content: "\n" + string(data) + "\n\n", want: 2},
+		{name: "truncated last line", content: string(data) + `{"market":"BTC_`, want: 2},
+		{name: "corrupted line in the middle", content: `{"market":` + "\n" + string(data), wantErr: true},
+		{name: "empty file", content: "", want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := filepath.Join(dir, tt.name+".jsonl")
+			if err := os.WriteFile(p, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			records, err := Load(p)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(records) != tt.want {
+				t.Errorf("records = %d, want %d", len(records), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package ledger
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Summary 一组记录的盈亏汇总
+type Summary struct {
+	Key     string
+	Trades  int
+	Wins    int // Net 为正的次数
+	Gross   decimal.Decimal
+	Fees    decimal.Decimal
+	Funding decimal.Decimal
+	Net     decimal.Decimal
+}
+
+// Summarize 按 key 分组汇总，结果按 key 排序
+func Summarize(records []Record, key func(Record) string) []Summary {
+	groups := make(map[string]*Summary)
+	for _, r := range records {
+		k := key(r)
+		s, ok := groups[k]
+		if !ok {
+			s = &Summary{Key: k}
+			groups[k] = s
+		}
+		s.Trades++
+		if r.Net.IsPositive() {
+			s.Wins++
+		}
+		s.Gross = s.Gross.Add(r.Gross)
+		s.Fees = s.Fees.Add(r.Fees)
+		s.Funding = s.Funding.Add(r.Funding)
+		s.Net = s.Net.Add(r.Net)
+	}
+	list := make([]Summary, 0, len(groups))
+	for _, s := range groups {
+		list = append(list, *s)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Key < list[j].Key
+	})
+	return list
+}
+
+// Day 按平仓日期(本地时区)分组
+func Day(r Record) string {
+	return r.CloseTime.Local().Format(time.DateOnly)
+}
+
+// Week 按平仓所在的 ISO 周分组
+func Week(r Record) string {
+	year, week := r.CloseTime.Local().ISOWeek()
+	return fmt.Sprintf("%d-W%02d", year, week)
+}
+
+func Market(r Record) string {
+	return r.Market
+}
@@ -0,0 +1,74 @@
+package ledger
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSummarize(t *testing.T) {
+	day := func(d, h int) time.Time {
+		return time.Date(2024, 12, d, h, 0, 0, 0, time.Local)
+	}
+	records := []Record{
+		record("BTC_USDT", day(29, 10), "1", "0.2", "0.1"),   // 周日，2024-W52
+		record("ETH_USDT", day(29, 23), "-1", "0.2", "0"),    // 周日，2024-W52
+		record("BTC_USDT", day(30, 0), "0.5", "0.1", "-0.1"), // 周一，跨年的 2025-W01
+		record("BTC_USDT", day(31, 12), "0", "0.1", "0"),
+	}
+	type row struct {
+		key          string
+		trades, wins int
+		gross, fees  string
+		funding, net string
+	}
+	tests := []struct {
+		name string
+		key  func(Record) string
+		want []row
+	}{
+		{
+			name: "day",
+			key:  Day,
+			want: []row{
+				{"2024-12-29", 2, 1, "0", "0.4", "0.1", "-0.3"},
+				{"2024-12-30", 1, 1, "0.5", "0.1", "-0.1", "0.3"},
+				{"2024-12-31", 1, 0, "0", "0.1", "0", "-0.1"},
+			},
+		},
+		{
+			name: "week",
+			key:  Week,
+			want: []row{
+				{"2024-W52", 2, 1, "0", "0.4", "0.1", "-0.3"},
+				{"2025-W01", 2, 1, "0.5", "0.2", "-0.1", "0.2"},
+			},
+		},
+		{
+			name: "market",
+			key:  Market,
+			want: []row{
+				{"BTC_USDT", 3, 2, "1.5", "0.4", "0", "1.1"},
+				{"ETH_USDT", 1, 0, "-1", "0.2", "0", "-1.2"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Summarize(records, tt.key)
+			if len(got) != len(tt.want) {
+				t.Fatalf("summaries = %+v, want %d", got, len(tt.want))
+			}
+			for i, w := range tt.want {
+				s := got[i]
+				if s.Key != w.key || s.Trades != w.trades || s.Wins != w.wins || !s.Gross.Equal(dec(w.gross)) ||
+					!s.Fees.Equal(dec(w.fees)) || !s.Funding.Equal(dec(w.funding)) || !s.Net.Equal(dec(w.net)) {
+					t.Errorf("summary %d = %+v, want %+v", i, s, w)
+				}
+			}
+		})
+	}
+
+	if got := Summarize(nil, Day); len(got) != 0 {
+		t.Errorf("empty summaries = %+v", got)
+	}
+}
@@ -1,50 +1,161 @@
 package main
 
 import (
+	"flag"
+	"fmt"
+	"move_profit/account"
+	"move_profit/alert"
 	"move_profit/binance_api"
 	"move_profit/binance_ws"
+	"move_profit/clock"
+	"move_profit/config"
+	"move_profit/exchange"
 	"move_profit/gate_api"
 	"move_profit/gate_ws"
+	"move_profit/ledger"
 	"move_profit/log"
+	"move_profit/metadata"
+	"move_profit/position"
+	"move_profit/quote"
+	"move_profit/ratelimit"
+	"move_profit/sizing"
+	"move_profit/strategy"
+	"move_profit/utils"
+	"os"
+	"time"
 )
 
+// 模拟盘挂单按盘口撮合的间隔
+const paperMatchInterval = 100 * time.Millisecond
+
 func main() {
+	confPath := flag.String("c", "config.json", "config file path")
+	flag.Parse()
+
+	if err := config.Init(*confPath); err != nil {
+		fmt.Fprintf(os.Stderr, "load config err:%+v\n", err)
+		os.Exit(1)
+	}
+	conf := config.Conf
+
 	log.InitLog()
-	binance_api.InitBinanceApi("02rw4kB2Lla22hGzFEkD77Cxnm55ogQYeZk5hthXmfRUM2NuyVYBRMCRcL6tb0nd", "arMz2bClKB0F3nekZc8JNIw2YBZ1ONpxfaOhKRJyMPceyLBEZcawauYXc9kNwJz5")
-	gate_api.InitGateClient()
-	binance_api.BinanceApiClient.SwitchPositionMode()
-	gate_api.SwitchPositionMode()
+	alert.Init(conf.Alert.WebhookUrl)
+	binance_api.InitBinanceApi(conf.Binance.FapiEndpoint, conf.Binance.Key, conf.Binance.Secret)
+	gate_api.InitGateClient(conf.Gate.Key, conf.Gate.Secret)
+	metadata.Register(exchange.Binance, binance_api.RefreshMarketInfo)
+	metadata.Register(exchange.Gate, gate_api.RefreshMarketInfo)
+	if err := binance_api.LoadFundingInfo(); err != nil {
+		// 加载失败时所有市场按默认 8 小时结算
+		log.Log.Errorf("load binance funding info err:%+v", err)
+	}
+	clock.Get(exchange.Binance).Start(binance_api.ServerTime, conf.Clock.SyncInterval.Duration(), conf.Clock.MaxSkew.Duration())
+	clock.Get(exchange.Gate).Start(gate_api.ServerTime, conf.Clock.SyncInterval.Duration(), conf.Clock.MaxSkew.Duration())
+	gate_ws.InitGateWs(conf.Gate.WsUrl, conf.Gate.Key, conf.Gate.Secret)
 
-	binance_ws.AsyncProcessBinancePubChan()
+	binanceEx, gateEx := binance_api.NewExchange(), gate_api.NewExchange()
+	var store position.Store
+	if conf.Paper.Enabled {
+		binancePaper := exchange.NewPaperExchange(binanceEx, quote.QuoteFunc(exchange.Binance),
+			exchange.FeeRate{Maker: conf.Paper.BinanceMakerFee, Taker: conf.Paper.BinanceTakerFee})
+		gatePaper := exchange.NewPaperExchange(gateEx, quote.QuoteFunc(exchange.Gate),
+			exchange.FeeRate{Maker: conf.Paper.GateMakerFee, Taker: conf.Paper.GateTakerFee})
+		// 模拟订单写入本地账户，maker 模式据此得到挂单成交
+		binancePaper.OnOrderUpdate(account.Get(exchange.Binance).UpdateOrder)
+		gatePaper.OnOrderUpdate(account.Get(exchange.Gate).UpdateOrder)
+		go binancePaper.Run(paperMatchInterval)
+		go gatePaper.Run(paperMatchInterval)
+		go reportPaper(binancePaper, gatePaper)
+		binanceEx, gateEx = binancePaper, gatePaper
+		log.Log.Warning("paper trading enabled, orders will not be sent to exchanges")
+	} else {
+		// 下单按单向持仓处理，切换失败时不能启动
+		if err := binance_api.BinanceApiClient.SwitchPositionMode(); err != nil {
+			log.Log.Errorf("binance switch position mode err:%+v", err)
+			fmt.Fprintf(os.Stderr, "binance switch position mode err:%+v\n", err)
+			os.Exit(1)
+		}
+		if err := gate_api.SwitchPositionMode(); err != nil {
+			log.Log.Errorf("gate switch position mode err:%+v", err)
+			fmt.Fprintf(os.Stderr, "gate switch position mode err:%+v\n", err)
+			os.Exit(1)
+		}
+		store = position.NewFileStore(conf.StatePath)
+	}
 
-	go gate_ws.GateTicker()
+	strategy.Init(binanceEx, gateEx, store, ledger.NewLedger(conf.LedgerPath))
+	// 首次加载合约元数据，之后定时刷新；监听需在首次加载前注册，初始的 listed 带有每个市场的可交易状态
+	metadata.OnChange(strategy.OnMarketChange)
+	metadata.OnChange(gate_ws.OnMarketChange)
+	if err := metadata.Refresh(); err != nil {
+		log.Log.Errorf("load market info err:%+v", err)
+		fmt.Fprintf(os.Stderr, "load market info err:%+v\n", err)
+		os.Exit(1)
+	}
+	if err := strategy.Recover(); err != nil {
+		log.Log.Errorf("recover positions err:%+v", err)
+		fmt.Fprintf(os.Stderr, "recover positions err:%+v\n", err)
+		os.Exit(1)
+	}
+	depthMarkets := commonMarkets(binanceEx, gateEx)
+	binance_ws.AsyncProcessBinancePubChan(depthMarkets)
+
+	go gate_ws.GateTicker(depthMarkets)
+	// 上下架和状态变化后调整行情订阅
+	metadata.OnChange(func([]exchange.MarketChange) {
+		gate_ws.SetMarkets(gate_api.GetMarketList())
+		markets := commonMarkets(binanceEx, gateEx)
+		binance_ws.SetDepthMarkets(markets)
+		gate_ws.SetDepthMarkets(markets)
+	})
+	go metadata.Run(conf.Strategy.MetadataRefreshInterval.Duration())
+	go reportRateLimit()
+	if !conf.Paper.Enabled {
+		binance_ws.AsyncProcessBinancePrivateChan()
+		go gate_ws.GatePrivate()
+	}
 	select {}
+}
+
+func reportPaper(list ...*exchange.PaperExchange) {
+	ticker := time.NewTicker(time.Minute)
+	for range ticker.C {
+		for _, p := range list {
+			log.Log.Info(p.Report())
+		}
+	}
+}
+
+func reportRateLimit() {
+	ticker := time.NewTicker(time.Minute)
+	for range ticker.C {
+		log.Log.Info(ratelimit.Get(exchange.Binance).Report())
+		log.Log.Info(ratelimit.Get(exchange.Gate).Report())
+	}
+}
 
-	//quantoMultiplier := ws.GetGateMarketQuantoMultiplier("BTC_USDT")
-	//if quantoMultiplier.IsZero() {
-	//	return
-	//}
-	//size, _ := decimal.NewFromString("0.01")
-	//binanceSize := size.String()
-	//gateSize := size.Div(quantoMultiplier).IntPart()
-	//pre1 设置持仓模式为单向持仓
-	//设置binance持仓模式为单向持仓
-
-	//pre2 设置小币种为5倍杠杆
-	//pre3 设置大币种为10倍杠杆
-
-	//1检测到价差大于双倍taker手续费
-	//2暂定用120U计算下单数量
-	//3低价交易所挂多单，高价交易所挂卖单
-	//4当交易所没有价差的时候，双腿平仓
-	//fmt.Println(binanceSize)
-	//fmt.Println(gateSize)
-	//gate_api.PlaceExchagneOrder("BTC_USDT", 1)
-
-	////processPushMsg("", "")
-	//binance_api.BinanceApiClient.Order("BTC_USDT", "0.01", "SELL")
-	////signalQuit()
-	//AsyncProcessBinancePubChan()
-
-	//select {}
+// commonMarkets 两个交易所都上线、可交易、未被排除且存在两边都能精确下单的数量的市场
+func commonMarkets(binanceEx, gateEx exchange.Exchange) []string {
+	list := make([]string, 0)
+	for _, market := range gate_api.GetMarketList() {
+		if _, ok := binance_api.GetMarketInfo(market); !ok {
+			continue
+		}
+		if utils.InArrayString(market, config.Conf.Strategy.ExcludeMarkets) {
+			continue
+		}
+		binanceContract, ok := binanceEx.GetContract(market)
+		if !ok {
+			continue
+		}
+		gateContract, ok := gateEx.GetContract(market)
+		if !ok || !binanceContract.Tradable || !gateContract.Tradable {
+			continue
+		}
+		if _, err := sizing.Match(gateContract, binanceContract); err != nil {
+			log.Log.Warningf("reject market:%s err:%+v", market, err)
+			continue
+		}
+		list = append(list, market)
+	}
+	return list
 }
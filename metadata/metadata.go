@@ -0,0 +1,81 @@
+package metadata
+
+import (
+	"sync"
+	"time"
+
+	"move_profit/exchange"
+	"move_profit/log"
+)
+
+// Refresher 重新加载一个交易所的合约元数据，返回与上次相比的变化
+type Refresher func() ([]exchange.MarketChange, error)
+
+var (
+	mu         sync.Mutex
+	refreshers = make(map[string]Refresher)
+	listeners  []func([]exchange.MarketChange)
+)
+
+// Register 注册交易所的刷新函数
+func Register(venue string, f Refresher) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	refreshers[venue] = f
+}
+
+// OnChange 每次刷新后收到本次所有交易所的变化，没有变化时不回调。需在首次 Refresh 前注册才能收到初始的 listed
+func OnChange(f func([]exchange.MarketChange)) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	listeners = append(listeners, f)
+}
+
+// Refresh 依次刷新所有交易所，某个交易所失败时保留其上次的元数据，其它交易所的变化照常分发
+func Refresh() error {
+	mu.Lock()
+	list := make(map[string]Refresher, len(refreshers))
+	for venue, f := range refreshers {
+		list[venue] = f
+	}
+	fs := append([]func([]exchange.MarketChange){}, listeners...)
+	mu.Unlock()
+
+	var (
+		changes  []exchange.MarketChange
+		firstErr error
+	)
+	for venue, f := range list {
+		res, err := f()
+		if err != nil {
+			log.Log.Errorf("[metadata] refresh %s err:%+v", venue, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		changes = append(changes, res...)
+	}
+	for _, c := range changes {
+		if c.Kind != exchange.MarketListed {
+			log.Log.Warningf("[metadata] %s %s %s tradable:%t", c.Venue, c.Market, c.Kind, c.Tradable)
+		}
+	}
+	if len(changes) > 0 {
+		for _, f := range fs {
+			f(changes)
+		}
+	}
+	return firstErr
+}
+
+// Run 定时刷新
+func Run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		Refresh()
+	}
+}
@@ -0,0 +1,379 @@
+package order
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"move_profit/account"
+	"move_profit/alert"
+	"move_profit/exchange"
+	"move_profit/log"
+)
+
+const (
+	// 已结束的订单保留的时间，之后从内存中移除
+	keepFinished = 10 * time.Minute
+	// 未结束的订单持续查询失败超过该时长后告警并停止跟踪
+	expireUnresolved = 30 * time.Minute
+)
+
+// Manager 跟踪策略下的每一笔订单：new → partially_filled → filled / cancelled / rejected / expired。
+// 状态来自下单、撤单、查询的 REST 响应和私有频道推送，按状态机合并，已结束的订单不再变化。
+// 订单按交易所和自定义订单 id 索引，下单时必须带 ClientId
+type Manager struct {
+	exchanges map[string]exchange.Exchange
+
+	mu       sync.Mutex
+	orders   map[string]*tracked               // venue:clientId
+	watchers map[string]map[chan struct{}]bool // venue:clientId -> 状态变化通知
+}
+
+type tracked struct {
+	venue      string
+	order      exchange.Order
+	updateTime time.Time // 最近一次状态变化的时间
+	failSince  time.Time // 定时查询开始连续失败的时间，查询成功后清零
+	placing    bool      // 下单请求还没有返回，此时查不到订单不代表交易所没有收到
+}
+
+// NewManager 同时订阅各交易所本地账户的订单推送
+func NewManager(exs ...exchange.Exchange) *Manager {
+	m := &Manager{
+		exchanges: make(map[string]exchange.Exchange),
+		orders:    make(map[string]*tracked),
+		watchers:  make(map[string]map[chan struct{}]bool),
+	}
+	for _, ex := range exs {
+		venue := ex.Name()
+		m.exchanges[venue] = ex
+		account.Get(venue).OnOrderUpdate(func(o exchange.Order) {
+			m.OnUpdate(venue, o)
+		})
+	}
+	return m
+}
+
+func key(venue, clientId string) string {
+	return venue + ":" + clientId
+}
+
+// stateRank 未结束的状态只能前进
+func stateRank(s exchange.OrderState) int {
+	switch s {
+	case exchange.OrderNew:
+		return 1
+	case exchange.OrderPartiallyFilled:
+		return 2
+	}
+	if s.Final() {
+		return 3
+	}
+	return 0
+}
+
+// advance 合并新的订单状态，返回是否有变化。已结束的订单只接受成交数量的增加(撤单响应可能早于最后一笔成交推送)，
+// 未结束的订单状态不回退，成交数量只增不减
+func advance(cur *exchange.Order, next exchange.Order) bool {
+	changed := false
+	if cur.Id == "" && next.Id != "" {
+		cur.Id = next.Id
+		changed = true
+	}
+	if next.FilledQuantity.GreaterThan(cur.FilledQuantity) {
+		cur.FilledQuantity = next.FilledQuantity
+		cur.AvgPrice = next.AvgPrice
+		changed = true
+	}
+	if !cur.Quantity.IsPositive() && next.Quantity.IsPositive() {
+		cur.Quantity = next.Quantity
+	}
+	if cur.State.Final() || stateRank(next.State) < stateRank(cur.State) {
+		return changed
+	}
+	if next.State == exchange.OrderNew && cur.FilledQuantity.IsPositive() {
+		next.State = exchange.OrderPartiallyFilled
+	}
+	if next.State != cur.State {
+		cur.State, cur.Status = next.State, next.Status
+		changed = true
+	}
+	return changed
+}
+
+// update 调用方需持有锁
+func (m *Manager) update(venue string, next exchange.Order) (exchange.Order, bool) {
+	t, ok := m.orders[key(venue, next.ClientId)]
+	if !ok {
+		return exchange.Order{}, false
+	}
+	prev := t.order.State
+	if !advance(&t.order, next) {
+		return t.order, true
+	}
+	t.updateTime = time.Now()
+	if prev != t.order.State {
+		log.Log.Debugf("[order] %s %s %s %s -> %s filled:%s/%s", venue, t.order.Market, t.order.ClientId, prev, t.order.State, t.order.FilledQuantity, t.order.Quantity)
+	}
+	for ch := range m.watchers[key(venue, next.ClientId)] {
+		// 通知只表示有变化，最新状态通过 Get 读取，已有未读通知时不必重复发送
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+	return t.order, true
+}
+
+// Place 下单并开始跟踪，返回下单响应合并后的订单状态。交易所明确拒绝的订单记为 rejected
+func (m *Manager) Place(ex exchange.Exchange, req exchange.OrderRequest) (*exchange.Order, error) {
+	if req.ClientId == "" {
+		return nil, &exchange.Error{Kind: exchange.KindInvalidRequest, Msg: fmt.Sprintf("market %s: tracked order must have a client id", req.Market)}
+	}
+	venue := ex.Name()
+	m.mu.Lock()
+	// 被拒绝(包括 Resolve 确认交易所没有)的订单可以用同一个自定义订单 id 重新下单
+	if t, ok := m.orders[key(venue, req.ClientId)]; ok && !(t.order.State == exchange.OrderRejected && t.order.Id == "") {
+		m.mu.Unlock()
+		return nil, &exchange.Error{Kind: exchange.KindInvalidRequest, Msg: fmt.Sprintf("market %s: duplicate client id %s", req.Market, req.ClientId)}
+	}
+	// 下单前登记，推送可能早于下单响应
+	m.orders[key(venue, req.ClientId)] = &tracked{
+		venue:      venue,
+		order:      exchange.Order{ClientId: req.ClientId, Market: req.Market, Side: req.Side, Quantity: req.Quantity},
+		updateTime: time.Now(),
+		placing:    true,
+	}
+	m.mu.Unlock()
+
+	res, err := ex.PlaceOrder(req)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if t, ok := m.orders[key(venue, req.ClientId)]; ok {
+		t.placing = false
+	}
+	if err != nil {
+		// 网络错误等无法确定交易所是否收到，保持未知状态，之后的推送或 Resolve 查询会更新
+		if !exchange.OutcomeUnknown(err) {
+			m.update(venue, exchange.Order{ClientId: req.ClientId, State: exchange.OrderRejected, Status: exchange.KindOf(err).String()})
+		}
+		return nil, err
+	}
+	res.ClientId = req.ClientId
+	o, _ := m.update(venue, *res)
+	return &o, nil
+}
+
+// Get 订单当前状态
+func (m *Manager) Get(venue, clientId string) (exchange.Order, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, ok := m.orders[key(venue, clientId)]
+	if !ok {
+		return exchange.Order{}, false
+	}
+	return t.order, true
+}
+
+// Open 未结束的订单
+func (m *Manager) Open(venue string) []exchange.Order {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	list := make([]exchange.Order, 0)
+	for _, t := range m.orders {
+		if t.venue == venue && t.order.Id != "" && !t.order.State.Final() {
+			list = append(list, t.order)
+		}
+	}
+	return list
+}
+
+// Watch 订阅订单状态变化，可以在下单前订阅；用完后调用返回的 cancel
+func (m *Manager) Watch(venue, clientId string) (<-chan struct{}, func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	k := key(venue, clientId)
+	ch := make(chan struct{}, 1)
+	if m.watchers[k] == nil {
+		m.watchers[k] = make(map[chan struct{}]bool)
+	}
+	m.watchers[k][ch] = true
+	return ch, func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		delete(m.watchers[k], ch)
+		if len(m.watchers[k]) == 0 {
+			delete(m.watchers, k)
+		}
+	}
+}
+
+// Wait 等待订单结束，超时返回当前状态和 false
+func (m *Manager) Wait(venue, clientId string, timeout time.Duration) (exchange.Order, bool) {
+	updates, stop := m.Watch(venue, clientId)
+	defer stop()
+
+	deadline := time.After(timeout)
+	for {
+		o, ok := m.Get(venue, clientId)
+		if ok && o.State.Final() {
+			return o, true
+		}
+		select {
+		case <-updates:
+		case <-deadline:
+			return o, false
+		}
+	}
+}
+
+// OnUpdate 私有频道推送的订单，不是策略下的单时忽略
+func (m *Manager) OnUpdate(venue string, o exchange.Order) {
+	if o.ClientId == "" {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.update(venue, o)
+}
+
+// lookup acked 为 true 时要求已经拿到交易所订单 id
+func (m *Manager) lookup(venue, clientId string, acked bool) (exchange.Exchange, exchange.Order, error) {
+	ex, ok := m.exchanges[venue]
+	if !ok {
+		return nil, exchange.Order{}, &exchange.Error{Venue: venue, Kind: exchange.KindInvalidRequest, Msg: "order manager unknown venue"}
+	}
+	o, ok := m.Get(venue, clientId)
+	if !ok || (acked && o.Id == "") {
+		return nil, exchange.Order{}, &exchange.Error{Venue: venue, Kind: exchange.KindUnknownOrder, Msg: fmt.Sprintf("order %s not tracked", clientId)}
+	}
+	return ex, o, nil
+}
+
+// Refresh 按自定义订单 id 通过 REST 查询订单最新状态，下单结果未知、还没有交易所订单 id 时也可以查询
+func (m *Manager) Refresh(venue, clientId string) (exchange.Order, error) {
+	ex, o, err := m.lookup(venue, clientId, false)
+	if err != nil {
+		return exchange.Order{}, err
+	}
+	res, err := ex.GetOrderByClientId(o.Market, clientId)
+	if err != nil {
+		return o, err
+	}
+	res.ClientId = clientId
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	o, _ = m.update(venue, *res)
+	return o, nil
+}
+
+// Resolve 确认下单结果未知的订单是否到达交易所：查到时返回最新状态和 true；
+// 交易所没有该订单时记为 rejected 并返回 false，之后可以用同一个自定义订单 id 重新下单
+func (m *Manager) Resolve(venue, clientId string) (exchange.Order, bool, error) {
+	o, err := m.Refresh(venue, clientId)
+	if errors.Is(err, exchange.ErrUnknownOrder) && o.ClientId != "" && o.Id == "" {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		o, _ = m.update(venue, exchange.Order{ClientId: clientId, State: exchange.OrderRejected, Status: "not_found"})
+		return o, false, nil
+	}
+	if err != nil {
+		return o, false, err
+	}
+	return o, true, nil
+}
+
+// Cancel 撤单，返回撤单后的订单状态(含撤单前已成交的数量)。订单已经结束时查询最终状态返回
+func (m *Manager) Cancel(venue, clientId string) (exchange.Order, error) {
+	ex, o, err := m.lookup(venue, clientId, true)
+	if err != nil {
+		return exchange.Order{}, err
+	}
+	if o.State.Final() {
+		return o, nil
+	}
+	res, err := ex.CancelOrder(o.Market, o.Id)
+	if errors.Is(err, exchange.ErrUnknownOrder) {
+		// 撤单时订单已经成交或撤销
+		if o, e := m.Refresh(venue, clientId); e == nil && o.State.Final() {
+			return o, nil
+		}
+	}
+	if err != nil {
+		return o, err
+	}
+	res.ClientId = clientId
+	// 撤单成功后订单即已结束，部分交易所的撤单响应状态仍为 open
+	if !res.State.Final() {
+		res.State = exchange.OrderCancelled
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	o, _ = m.update(venue, *res)
+	return o, nil
+}
+
+// Run 定时用 REST 查询长时间没有推送的未结束订单(包括下单结果未知的订单)，弥补推送丢失；
+// 清理结束已久的订单，持续查询失败的订单告警后停止跟踪
+func (m *Manager) Run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		m.refreshStale(interval)
+	}
+}
+
+func (m *Manager) refreshStale(age time.Duration) {
+	type ref struct {
+		venue string
+		order exchange.Order
+	}
+	var stale []ref
+
+	m.mu.Lock()
+	for k, t := range m.orders {
+		switch {
+		case t.order.State.Final():
+			if time.Since(t.updateTime) > keepFinished {
+				delete(m.orders, k)
+			}
+		case !t.failSince.IsZero() && time.Since(t.failSince) > expireUnresolved:
+			delete(m.orders, k)
+			alert.Send("[order] %s %s order:%s client id:%s %s state unresolved for %s, stop tracking, check it manually",
+				t.venue, t.order.Market, t.order.Id, t.order.ClientId, t.order.Side, expireUnresolved)
+		case !t.placing && time.Since(t.updateTime) > age:
+			stale = append(stale, ref{t.venue, t.order})
+		}
+	}
+	m.mu.Unlock()
+
+	for _, r := range stale {
+		var err error
+		if r.order.Id == "" {
+			_, _, err = m.Resolve(r.venue, r.order.ClientId)
+		} else {
+			_, err = m.Refresh(r.venue, r.order.ClientId)
+		}
+		if err != nil {
+			log.Log.Warningf("[order] refresh %s %s err:%+v", r.venue, r.order.ClientId, err)
+		}
+		m.mu.Lock()
+		if t, ok := m.orders[key(r.venue, r.order.ClientId)]; ok {
+			if err == nil {
+				t.failSince = time.Time{}
+			} else if t.failSince.IsZero() {
+				t.failSince = time.Now()
+			}
+		}
+		m.mu.Unlock()
+	}
+}
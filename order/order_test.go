@@ -0,0 +1,284 @@
+package order
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/op/go-logging"
+	"github.com/shopspring/decimal"
+	"move_profit/exchange"
+	"move_profit/exchange/exchangetest"
+	"move_profit/log"
+)
+
+func TestMain(m *testing.M) {
+	log.Log = logging.MustGetLogger("test")
+	logging.SetLevel(logging.CRITICAL, "")
+	os.Exit(m.Run())
+}
+
+func dec(s string) decimal.Decimal {
+	return decimal.RequireFromString(s)
+}
+
+func TestAdvance(t *testing.T) {
+	tests := []struct {
+		name    string
+		cur     exchange.Order
+		next    exchange.Order
+		want    exchange.Order
+		changed bool
+	}{
+		{
+			name:    "ack sets id",
+			cur:     exchange.Order{Quantity: dec("1")},
+			next:    exchange.Order{Id: "1", State: exchange.OrderNew},
+			want:    exchange.Order{Id: "1", Quantity: dec("1"), State: exchange.OrderNew},
+			changed: true,
+		},
+		{
+			name:    "id is not overwritten",
+			cur:     exchange.Order{Id: "1", Quantity: dec("1"), State: exchange.OrderNew},
+			next:    exchange.Order{Id: "2", State: exchange.OrderNew},
+			want:    exchange.Order{Id: "1", Quantity: dec("1"), State: exchange.OrderNew},
+			changed: false,
+		},
+		{
+			name:    "partial fill",
+			cur:     exchange.Order{Id: "1", Quantity: dec("1"), State: exchange.OrderNew},
+			next:    exchange.Order{FilledQuantity: dec("0.4"), AvgPrice: dec("100"), State: exchange.OrderPartiallyFilled},
+			want:    exchange.Order{Id: "1", Quantity: dec("1"), FilledQuantity: dec("0.4"), AvgPrice: dec("100"), State: exchange.OrderPartiallyFilled},
+			changed: true,
+		},
+		{
+			name:    "new with fills becomes partially filled",
+			cur:     exchange.Order{Id: "1", Quantity: dec("1"), State: exchange.OrderNew},
+			next:    exchange.Order{FilledQuantity: dec("0.4"), State: exchange.OrderNew},
+			want:    exchange.Order{Id: "1", Quantity: dec("1"), FilledQuantity: dec("0.4"), State: exchange.OrderPartiallyFilled},
+			changed: true,
+		},
+		{
+			name:    "state does not go back",
+			cur:     exchange.Order{Id: "1", Quantity: dec("1"), FilledQuantity: dec("0.4"), State: exchange.OrderPartiallyFilled},
+			next:    exchange.Order{State: exchange.OrderNew},
+			want:    exchange.Order{Id: "1", Quantity: dec("1"), FilledQuantity: dec("0.4"), State: exchange.OrderPartiallyFilled},
+			changed: false,
+		},
+		{
+			name:    "filled quantity does not decrease",
+			cur:     exchange.Order{Id: "1", Quantity: dec("1"), FilledQuantity: dec("0.6"), AvgPrice: dec("101"), State: exchange.OrderPartiallyFilled},
+			next:    exchange.Order{FilledQuantity: dec("0.4"), AvgPrice: dec("100"), State: exchange.OrderPartiallyFilled},
+			want:    exchange.Order{Id: "1", Quantity: dec("1"), FilledQuantity: dec("0.6"), AvgPrice: dec("101"), State: exchange.OrderPartiallyFilled},
+			changed: false,
+		},
+		{
+			name:    "finished",
+			cur:     exchange.Order{Id: "1", Quantity: dec("1"), FilledQuantity: dec("0.4"), State: exchange.OrderPartiallyFilled},
+			next:    exchange.Order{FilledQuantity: dec("1"), AvgPrice: dec("100"), State: exchange.OrderFilled, Status: "FILLED"},
+			want:    exchange.Order{Id: "1", Quantity: dec("1"), FilledQuantity: dec("1"), AvgPrice: dec("100"), State: exchange.OrderFilled, Status: "FILLED"},
+			changed: true,
+		},
+		{
+			name:    "final state does not change",
+			cur:     exchange.Order{Id: "1", Quantity: dec("1"), State: exchange.OrderCancelled},
+			next:    exchange.Order{State: exchange.OrderFilled},
+			want:    exchange.Order{Id: "1", Quantity: dec("1"), State: exchange.OrderCancelled},
+			changed: false,
+		},
+		{
+			name:    "late fill after cancel",
+			cur:     exchange.Order{Id: "1", Quantity: dec("1"), FilledQuantity: dec("0.4"), State: exchange.OrderCancelled},
+			next:    exchange.Order{FilledQuantity: dec("0.5"), AvgPrice: dec("100"), State: exchange.OrderPartiallyFilled},
+			want:    exchange.Order{Id: "1", Quantity: dec("1"), FilledQuantity: dec("0.5"), AvgPrice: dec("100"), State: exchange.OrderCancelled},
+			changed: true,
+		},
+		{
+			name:    "quantity from response",
+			cur:     exchange.Order{},
+			next:    exchange.Order{Id: "1", Quantity: dec("2"), State: exchange.OrderNew},
+			want:    exchange.Order{Id: "1", Quantity: dec("2"), State: exchange.OrderNew},
+			changed: true,
+		},
+		{
+			name:    "unknown state ignored",
+			cur:     exchange.Order{Id: "1", Quantity: dec("1"), State: exchange.OrderNew},
+			next:    exchange.Order{State: ""},
+			want:    exchange.Order{Id: "1", Quantity: dec("1"), State: exchange.OrderNew},
+			changed: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cur := tt.cur
+			changed := advance(&cur, tt.next)
+			if changed != tt.changed {
+				t.Errorf("changed = %v, want %v", changed, tt.changed)
+			}
+			if cur.Id != tt.want.Id || cur.State != tt.want.State || cur.Status != tt.want.Status ||
+				!cur.Quantity.Equal(tt.want.Quantity) || !cur.FilledQuantity.Equal(tt.want.FilledQuantity) || !cur.AvgPrice.Equal(tt.want.AvgPrice) {
+				t.Errorf("order = %+v, want %+v", cur, tt.want)
+			}
+		})
+	}
+}
+
+func newManager() (*Manager, *exchangetest.Fake) {
+	ex := exchangetest.NewFake(exchange.Gate, dec("100"))
+	return NewManager(ex), ex
+}
+
+func TestUpdate(t *testing.T) {
+	m := NewManager()
+	m.orders[key(exchange.Gate, "a")] = &tracked{venue: exchange.Gate, order: exchange.Order{ClientId: "a", Quantity: dec("1")}}
+	ch, stop := m.Watch(exchange.Gate, "a")
+	defer stop()
+
+	steps := []struct {
+		next   exchange.Order
+		state  exchange.OrderState
+		notify bool
+	}{
+		{exchange.Order{ClientId: "a", Id: "1", State: exchange.OrderNew}, exchange.OrderNew, true},
+		{exchange.Order{ClientId: "a", Id: "1", State: exchange.OrderNew}, exchange.OrderNew, false},
+		{exchange.Order{ClientId: "a", FilledQuantity: dec("1"), State: exchange.OrderFilled}, exchange.OrderFilled, true},
+		{exchange.Order{ClientId: "a", State: exchange.OrderCancelled}, exchange.OrderFilled, false},
+	}
+	for i, s := range steps {
+		m.mu.Lock()
+		o, ok := m.update(exchange.Gate, s.next)
+		m.mu.Unlock()
+		if !ok || o.State != s.state {
+			t.Errorf("step %d: state = %s ok = %v, want %s", i, o.State, ok, s.state)
+		}
+		select {
+		case <-ch:
+			if !s.notify {
+				t.Errorf("step %d: unexpected notification", i)
+			}
+		default:
+			if s.notify {
+				t.Errorf("step %d: missing notification", i)
+			}
+		}
+	}
+
+	m.mu.Lock()
+	_, ok := m.update(exchange.Gate, exchange.Order{ClientId: "b", State: exchange.OrderNew})
+	m.mu.Unlock()
+	if ok {
+		t.Errorf("untracked order updated")
+	}
+}
+
+func TestPlace(t *testing.T) {
+	m, ex := newManager()
+	req := exchange.OrderRequest{Market: "BTC_USDT", Side: exchange.SideBuy, Quantity: dec("1"), ClientId: "a"}
+
+	if _, err := m.Place(ex, exchange.OrderRequest{Market: "BTC_USDT", Side: exchange.SideBuy, Quantity: dec("1")}); err == nil {
+		t.Errorf("order without client id placed")
+	}
+	o, err := m.Place(ex, req)
+	if err != nil || o.State != exchange.OrderFilled || o.Id == "" {
+		t.Fatalf("place = %+v err:%+v", o, err)
+	}
+	if _, err := m.Place(ex, req); err == nil {
+		t.Errorf("duplicate client id placed")
+	}
+
+	// 明确拒绝的订单记为 rejected，可以用同一个 id 重新下单
+	req.ClientId = "b"
+	ex.Script(exchangetest.Result{Err: &exchange.Error{Venue: exchange.Gate, Kind: exchange.KindInsufficientMargin, Status: 400}})
+	if _, err := m.Place(ex, req); err == nil {
+		t.Fatalf("rejected order placed")
+	}
+	if o, _ := m.Get(exchange.Gate, "b"); o.State != exchange.OrderRejected {
+		t.Errorf("rejected order state = %s", o.State)
+	}
+	if _, err := m.Place(ex, req); err != nil {
+		t.Errorf("replace rejected order err:%+v", err)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	m, ex := newManager()
+	lost := os.ErrDeadlineExceeded
+	req := exchange.OrderRequest{Market: "BTC_USDT", Side: exchange.SideBuy, Quantity: dec("1"), ClientId: "a"}
+
+	// 请求没有到达交易所：记为 rejected，可以用同一个 id 重发
+	ex.Script(exchangetest.Result{Err: lost})
+	if _, err := m.Place(ex, req); err == nil {
+		t.Fatalf("lost order placed")
+	}
+	if o, _ := m.Get(exchange.Gate, "a"); o.State != "" {
+		t.Errorf("unknown outcome state = %s, want empty", o.State)
+	}
+	if _, found, err := m.Resolve(exchange.Gate, "a"); found || err != nil {
+		t.Fatalf("resolve found = %v err:%+v", found, err)
+	}
+	if _, err := m.Place(ex, req); err != nil {
+		t.Fatalf("resend err:%+v", err)
+	}
+
+	// 请求到达交易所但响应丢失：查到订单，不能重发
+	req.ClientId = "b"
+	ex.Script(exchangetest.Result{Err: lost, Accepted: true})
+	if _, err := m.Place(ex, req); err == nil {
+		t.Fatalf("lost order placed")
+	}
+	o, found, err := m.Resolve(exchange.Gate, "b")
+	if !found || err != nil || o.State != exchange.OrderFilled {
+		t.Fatalf("resolve = %+v found = %v err:%+v", o, found, err)
+	}
+	if _, err := m.Place(ex, req); err == nil {
+		t.Errorf("resolved order placed again")
+	}
+	if n := len(ex.Requests()); n != 3 {
+		t.Errorf("requests = %d, want 3", n)
+	}
+}
+
+func TestRefreshStale(t *testing.T) {
+	m, ex := newManager()
+	old := time.Now().Add(-time.Hour)
+	m.orders[key(exchange.Gate, "finished")] = &tracked{venue: exchange.Gate, updateTime: old,
+		order: exchange.Order{ClientId: "finished", Id: "1", State: exchange.OrderFilled}}
+	m.orders[key(exchange.Gate, "expired")] = &tracked{venue: exchange.Gate, updateTime: old, failSince: old,
+		order: exchange.Order{ClientId: "expired", Id: "2", Market: "BTC_USDT", State: exchange.OrderNew}}
+	m.orders[key(exchange.Gate, "placing")] = &tracked{venue: exchange.Gate, updateTime: old, placing: true,
+		order: exchange.Order{ClientId: "placing", Market: "BTC_USDT"}}
+	m.orders[key(exchange.Gate, "lost")] = &tracked{venue: exchange.Gate, updateTime: old,
+		order: exchange.Order{ClientId: "lost", Market: "BTC_USDT"}}
+	m.orders[key(exchange.Gate, "failing")] = &tracked{venue: exchange.Gate, updateTime: old,
+		order: exchange.Order{ClientId: "failing", Id: "3", Market: "BTC_USDT", State: exchange.OrderNew}}
+	if _, err := ex.PlaceOrder(exchange.OrderRequest{Market: "BTC_USDT", Side: exchange.SideBuy, Quantity: dec("1"), ClientId: "open"}); err != nil {
+		t.Fatal(err)
+	}
+	m.orders[key(exchange.Gate, "open")] = &tracked{venue: exchange.Gate, updateTime: old,
+		order: exchange.Order{ClientId: "open", Market: "BTC_USDT", State: exchange.OrderNew}}
+
+	m.refreshStale(time.Minute)
+
+	want := map[string]exchange.OrderState{
+		"placing": "",
+		"lost":    exchange.OrderRejected,
+		"open":    exchange.OrderFilled,
+	}
+	for id, state := range want {
+		o, ok := m.Get(exchange.Gate, id)
+		if !ok || o.State != state {
+			t.Errorf("%s state = %s ok = %v, want %s", id, o.State, ok, state)
+		}
+	}
+	for _, id := range []string{"finished", "expired"} {
+		if _, ok := m.Get(exchange.Gate, id); ok {
+			t.Errorf("%s still tracked", id)
+		}
+	}
+	m.mu.Lock()
+	failSince := m.orders[key(exchange.Gate, "failing")].failSince
+	m.mu.Unlock()
+	if failSince.IsZero() {
+		t.Errorf("failing order fail time not set")
+	}
+}
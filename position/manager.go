@@ -0,0 +1,304 @@
+package position
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"move_profit/exchange"
+	"move_profit/log"
+)
+
+type State string
+
+const (
+	StateOpening State = "opening" // 已占用额度，正在下单
+	StateOpen    State = "open"
+	StateClosing State = "closing"
+	// 重启对账时两边实际仓位与记录不一致，禁止该市场交易，需要人工处理
+	StateMismatch State = "mismatch"
+)
+
+// Position 一组跨交易所对冲仓位
+type Position struct {
+	Market          string          `json:"market"`
+	State           State           `json:"state"`
+	BinanceSide     exchange.Side   `json:"binance_side"`
+	BinanceQuantity decimal.Decimal `json:"binance_quantity"`
+	GateQuantity    decimal.Decimal `json:"gate_quantity"` // 多仓为正，空仓为负
+	// 开仓时两腿实际成交均价计算的价差比例，平仓收益按此计算
+	DiffRate          decimal.Decimal `json:"diff_rate"`
+	SignalRate        decimal.Decimal `json:"signal_rate"`         // 开仓信号的价差比例
+	BinanceEntryPrice decimal.Decimal `json:"binance_entry_price"` // binance 腿开仓成交均价
+	GateEntryPrice    decimal.Decimal `json:"gate_entry_price"`    // gate 腿开仓成交均价
+	Notional          decimal.Decimal `json:"notional"`
+	MakerVenue        string          `json:"maker_venue,omitempty"` // 开仓时挂单成交的交易所，为空时两边都是吃单开仓；平仓按同样的往返费率计算成本
+	EntryFee          decimal.Decimal `json:"entry_fee"`             // 开仓手续费(USDT)
+	Funding           decimal.Decimal `json:"funding"`               // 持仓以来累计的资金费，正为收取(USDT)
+	OpenTime          time.Time       `json:"open_time"`
+}
+
+// Manager 按市场管理多个同时持有的对冲仓位，限制持仓数量和总名义价值。
+// store 不为空时每次变更都会落盘，重启后通过 Restore/Reconcile 恢复
+type Manager struct {
+	mu           sync.Mutex
+	positions    map[string]*Position
+	orders       map[string]*PendingOrder
+	orderSeq     int64
+	maxPositions int
+	maxNotional  decimal.Decimal
+	store        Store
+
+	// 锁内标记需要落盘的部分，unlock 时复制快照，释放锁后再写入
+	positionsDirty bool
+	ordersDirty    bool
+	version        int64
+	// saveMu 串行写入 store，saved* 为已写入的快照版本，并发写入时不会用旧快照覆盖新快照
+	saveMu         sync.Mutex
+	savedPositions int64
+	savedOrders    int64
+}
+
+func NewManager(maxPositions int, maxNotional decimal.Decimal, store Store) *Manager {
+	return &Manager{
+		positions:    make(map[string]*Position),
+		orders:       make(map[string]*PendingOrder),
+		maxPositions: maxPositions,
+		maxNotional:  maxNotional,
+		store:        store,
+	}
+}
+
+// Reserve 开仓前占用额度，超过持仓数或总名义价值上限时返回错误
+func (m *Manager) Reserve(p *Position) error {
+	m.mu.Lock()
+	defer m.unlock()
+
+	if _, ok := m.positions[p.Market]; ok {
+		return fmt.Errorf("market %s already has position", p.Market)
+	}
+	if len(m.positions) >= m.maxPositions {
+		return fmt.Errorf("max positions %d reached", m.maxPositions)
+	}
+	if m.totalNotional().Add(p.Notional).GreaterThan(m.maxNotional) {
+		return fmt.Errorf("max total notional %s reached", m.maxNotional)
+	}
+	p.State = StateOpening
+	m.positions[p.Market] = p
+	m.positionsDirty = true
+	return nil
+}
+
+// Release 开仓失败时释放额度
+func (m *Manager) Release(market string) {
+	m.mu.Lock()
+	defer m.unlock()
+
+	if p, ok := m.positions[market]; ok && p.State == StateOpening {
+		delete(m.positions, market)
+		m.positionsDirty = true
+	}
+}
+
+// Open 双腿成交后记录仓位
+func (m *Manager) Open(p *Position) {
+	m.mu.Lock()
+	defer m.unlock()
+
+	p.State = StateOpen
+	if p.OpenTime.IsZero() {
+		p.OpenTime = time.Now()
+	}
+	m.positions[p.Market] = p
+	m.positionsDirty = true
+}
+
+// BeginClose 标记为平仓中，避免重复平仓
+func (m *Manager) BeginClose(market string) (Position, bool) {
+	m.mu.Lock()
+	defer m.unlock()
+
+	p, ok := m.positions[market]
+	if !ok || p.State != StateOpen {
+		return Position{}, false
+	}
+	p.State = StateClosing
+	m.positionsDirty = true
+	return *p, true
+}
+
+// Update 按执行结果覆盖仓位，例如平仓失败回滚后恢复为 open，或单腿敞口时标记为 mismatch
+func (m *Manager) Update(p Position) {
+	m.mu.Lock()
+	defer m.unlock()
+
+	m.positions[p.Market] = &p
+	m.positionsDirty = true
+}
+
+// AddFunding 累加资金费结算，仓位已不存在时忽略
+func (m *Manager) AddFunding(market string, amount decimal.Decimal) {
+	m.mu.Lock()
+	defer m.unlock()
+
+	p, ok := m.positions[market]
+	if !ok {
+		return
+	}
+	p.Funding = p.Funding.Add(amount)
+	m.positionsDirty = true
+}
+
+// Close 平仓完成后移除
+func (m *Manager) Close(market string) {
+	m.mu.Lock()
+	defer m.unlock()
+
+	delete(m.positions, market)
+	m.positionsDirty = true
+}
+
+// OrderIdPrefix 策略下单的自定义订单 id 前缀，用于区分手动下的单
+const OrderIdPrefix = "mp-"
+
+// BeginOrder 下单前记录在途订单，返回本地 id，符合两个交易所自定义订单 id 的格式
+func (m *Manager) BeginOrder(market, venue string, side exchange.Side, quantity decimal.Decimal) string {
+	m.mu.Lock()
+	defer m.unlock()
+
+	m.orderSeq++
+	id := fmt.Sprintf("%s%d-%d", OrderIdPrefix, time.Now().UnixMilli(), m.orderSeq)
+	m.orders[id] = &PendingOrder{
+		Id:         id,
+		Market:     market,
+		Venue:      venue,
+		Side:       side,
+		Quantity:   quantity,
+		CreateTime: time.Now(),
+	}
+	m.ordersDirty = true
+	return id
+}
+
+// EndOrder 拿到下单结果(成功或失败)后移除在途订单
+func (m *Manager) EndOrder(id string) {
+	m.mu.Lock()
+	defer m.unlock()
+
+	delete(m.orders, id)
+	m.ordersDirty = true
+}
+
+// Get 返回仓位副本
+func (m *Manager) Get(market string) (Position, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.positions[market]
+	if !ok {
+		return Position{}, false
+	}
+	return *p, true
+}
+
+// List 按市场排序返回所有仓位副本
+func (m *Manager) List() []Position {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	list := make([]Position, 0, len(m.positions))
+	for _, p := range m.positions {
+		list = append(list, *p)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Market < list[j].Market
+	})
+	return list
+}
+
+func (m *Manager) TotalNotional() decimal.Decimal {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.totalNotional()
+}
+
+// Restore 从 store 加载上次的仓位和在途订单，需在交易开始前调用，随后应调用 Reconcile
+func (m *Manager) Restore() error {
+	if m.store == nil {
+		return nil
+	}
+	snapshot, err := m.store.Load()
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range snapshot.Positions {
+		p := snapshot.Positions[i]
+		m.positions[p.Market] = &p
+	}
+	for i := range snapshot.Orders {
+		o := snapshot.Orders[i]
+		m.orders[o.Id] = &o
+	}
+	return nil
+}
+
+// unlock 释放锁，锁内有变更且 store 不为空时先在锁内复制快照，释放锁后再写入，fsync 期间不阻塞其它调用
+func (m *Manager) unlock() {
+	if m.store == nil || (!m.positionsDirty && !m.ordersDirty) {
+		m.positionsDirty, m.ordersDirty = false, false
+		m.mu.Unlock()
+		return
+	}
+	m.version++
+	version := m.version
+	var positions []Position
+	var orders []PendingOrder
+	savePositions, saveOrders := m.positionsDirty, m.ordersDirty
+	if savePositions {
+		positions = make([]Position, 0, len(m.positions))
+		for _, p := range m.positions {
+			positions = append(positions, *p)
+		}
+	}
+	if saveOrders {
+		orders = make([]PendingOrder, 0, len(m.orders))
+		for _, o := range m.orders {
+			orders = append(orders, *o)
+		}
+	}
+	m.positionsDirty, m.ordersDirty = false, false
+	m.mu.Unlock()
+
+	m.saveMu.Lock()
+	defer m.saveMu.Unlock()
+
+	if savePositions && version > m.savedPositions {
+		if err := m.store.SavePositions(positions); err != nil {
+			log.Log.Errorf("persist positions err:%+v", err)
+		} else {
+			m.savedPositions = version
+		}
+	}
+	if saveOrders && version > m.savedOrders {
+		if err := m.store.SaveOrders(orders); err != nil {
+			log.Log.Errorf("persist pending orders err:%+v", err)
+		} else {
+			m.savedOrders = version
+		}
+	}
+}
+
+func (m *Manager) totalNotional() decimal.Decimal {
+	total := decimal.Zero
+	for _, p := range m.positions {
+		total = total.Add(p.Notional)
+	}
+	return total
+}
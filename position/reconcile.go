@@ -0,0 +1,108 @@
+package position
+
+import (
+	"move_profit/exchange"
+	"move_profit/log"
+
+	"github.com/shopspring/decimal"
+)
+
+// Reconcile 用两个交易所的实际持仓校正恢复出来的仓位，交易开始前调用：
+//   - 两边都无持仓：丢弃记录
+//   - 两边方向相反且数量完全相等：视为正常对冲仓位
+//   - 其它情况：按实际持仓记录并标记为 mismatch，该市场不再交易，等待人工处理
+func (m *Manager) Reconcile(binance, gate exchange.Exchange) error {
+	m.mu.Lock()
+	markets := make(map[string]struct{})
+	for market := range m.positions {
+		markets[market] = struct{}{}
+	}
+	for _, o := range m.orders {
+		markets[o.Market] = struct{}{}
+	}
+	m.mu.Unlock()
+
+	for market := range markets {
+		binancePos, err := binance.GetPosition(market)
+		if err != nil {
+			return err
+		}
+		gatePos, err := gate.GetPosition(market)
+		if err != nil {
+			return err
+		}
+		m.reconcileMarket(market, binancePos, gatePos)
+	}
+
+	m.mu.Lock()
+	defer m.unlock()
+
+	// 在途订单的结果已经体现在实际持仓里
+	m.orders = make(map[string]*PendingOrder)
+	m.positionsDirty, m.ordersDirty = true, true
+	return nil
+}
+
+func (m *Manager) reconcileMarket(market string, binancePos, gatePos exchange.Position) {
+	binanceQuantity, gateQuantity := binancePos.Quantity, gatePos.Quantity
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	recorded, ok := m.positions[market]
+	if binanceQuantity.IsZero() && gateQuantity.IsZero() {
+		if ok {
+			log.Log.Warningf("[reconcile] market:%s state:%s has no position on both exchanges, drop it", market, recorded.State)
+			delete(m.positions, market)
+		}
+		return
+	}
+
+	p := recorded
+	if !ok {
+		p = &Position{Market: market}
+		m.positions[market] = p
+	}
+
+	binanceSide := exchange.SideBuy
+	if binanceQuantity.IsNegative() {
+		binanceSide = exchange.SideSell
+	}
+	// 两腿按同一个基础币数量下单，对冲仓位两边数量必然相等，差一点也是单腿敞口
+	hedged := !binanceQuantity.IsZero() && binanceQuantity.Equal(gateQuantity.Neg())
+
+	p.BinanceSide = binanceSide
+	p.BinanceQuantity = binanceQuantity.Abs()
+	p.GateQuantity = gateQuantity
+	if hedged {
+		if p.State != StateOpen {
+			log.Log.Warningf("[reconcile] market:%s state:%s adopt hedged position binance:%s gate:%s", market, p.State, binanceQuantity, gateQuantity)
+		}
+		p.State = StateOpen
+		// 没有记录成交价(如崩溃前未落盘)时按交易所的持仓均价计算开仓价差
+		if p.BinanceEntryPrice.IsZero() || p.GateEntryPrice.IsZero() {
+			p.BinanceEntryPrice, p.GateEntryPrice = binancePos.EntryPrice, gatePos.EntryPrice
+			p.DiffRate = entryRate(binanceSide, p.BinanceEntryPrice, p.GateEntryPrice)
+			log.Log.Warningf("[reconcile] market:%s use exchange entry price binance:%s gate:%s diffRate:%s", market, p.BinanceEntryPrice, p.GateEntryPrice, p.DiffRate)
+		}
+		// 本地没有记录的仓位按交易所持仓均价计算名义价值，计入总名义价值上限
+		if p.Notional.IsZero() {
+			p.Notional = p.BinanceQuantity.Mul(p.BinanceEntryPrice)
+		}
+		return
+	}
+
+	p.State = StateMismatch
+	log.Log.Errorf("[reconcile] market:%s position mismatch binance:%s gate:%s, trading on this market is blocked", market, binanceQuantity, gateQuantity)
+}
+
+// entryRate (卖出腿价格 - 买入腿价格) / 买入腿价格
+func entryRate(binanceSide exchange.Side, binancePrice, gatePrice decimal.Decimal) decimal.Decimal {
+	buy, sell := gatePrice, binancePrice
+	if binanceSide == exchange.SideBuy {
+		buy, sell = binancePrice, gatePrice
+	}
+	if !buy.IsPositive() {
+		return decimal.Zero
+	}
+	return sell.Sub(buy).Div(buy)
+}
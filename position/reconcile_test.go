@@ -0,0 +1,90 @@
+package position
+
+import (
+	"os"
+	"testing"
+
+	"github.com/op/go-logging"
+	"github.com/shopspring/decimal"
+	"move_profit/exchange"
+	"move_profit/log"
+)
+
+func TestMain(m *testing.M) {
+	log.Log = logging.MustGetLogger("test")
+	logging.SetLevel(logging.CRITICAL, "")
+	os.Exit(m.Run())
+}
+
+func dec(s string) decimal.Decimal {
+	return decimal.RequireFromString(s)
+}
+
+func TestReconcileMarket(t *testing.T) {
+	const market = "BTC_USDT"
+	recorded := func(state State) *Position {
+		return &Position{
+			Market:            market,
+			State:             state,
+			BinanceSide:       exchange.SideSell,
+			BinanceQuantity:   dec("1"),
+			GateQuantity:      dec("1"),
+			DiffRate:          dec("0.01"),
+			BinanceEntryPrice: dec("101"),
+			GateEntryPrice:    dec("100"),
+			Notional:          dec("101"),
+		}
+	}
+	tests := []struct {
+		name     string
+		recorded *Position
+		binance  string
+		gate     string
+		want     State // 为空表示记录被丢弃
+		diffRate string
+		notional string
+	}{
+		{name: "hedged as recorded", recorded: recorded(StateOpen), binance: "-1", gate: "1", want: StateOpen, diffRate: "0.01", notional: "101"},
+		{name: "hedged while closing", recorded: recorded(StateClosing), binance: "-1", gate: "1", want: StateOpen, diffRate: "0.01", notional: "101"},
+		{name: "hedged after partial close", recorded: recorded(StateClosing), binance: "-0.4", gate: "0.4", want: StateOpen, diffRate: "0.01", notional: "101"},
+		{name: "hedged without record", binance: "-2", gate: "2", want: StateOpen, diffRate: "0.01", notional: "202"},
+		{name: "quantity off by one step", recorded: recorded(StateOpen), binance: "-1", gate: "1.001", want: StateMismatch},
+		{name: "binance leg short", recorded: recorded(StateOpening), binance: "-0.999", gate: "1", want: StateMismatch},
+		{name: "same direction", recorded: recorded(StateOpen), binance: "1", gate: "1", want: StateMismatch},
+		{name: "naked gate", recorded: recorded(StateOpening), binance: "0", gate: "1", want: StateMismatch},
+		{name: "naked binance without record", binance: "-1", gate: "0", want: StateMismatch},
+		{name: "flat on both", recorded: recorded(StateOpen), binance: "0", gate: "0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewManager(10, dec("1000"), nil)
+			if tt.recorded != nil {
+				m.positions[market] = tt.recorded
+			}
+			binancePos := exchange.Position{Market: market, Quantity: dec(tt.binance), EntryPrice: dec("101")}
+			gatePos := exchange.Position{Market: market, Quantity: dec(tt.gate), EntryPrice: dec("100")}
+			m.reconcileMarket(market, binancePos, gatePos)
+
+			p, ok := m.Get(market)
+			if tt.want == "" {
+				if ok {
+					t.Fatalf("position = %+v, want dropped", p)
+				}
+				return
+			}
+			if !ok || p.State != tt.want {
+				t.Fatalf("position = %+v, want state %s", p, tt.want)
+			}
+			// 无论是否对冲都按实际持仓记录
+			if !p.BinanceQuantity.Equal(dec(tt.binance).Abs()) || !p.GateQuantity.Equal(dec(tt.gate)) {
+				t.Errorf("quantity binance:%s gate:%s, want %s %s", p.BinanceQuantity, p.GateQuantity, tt.binance, tt.gate)
+			}
+			if tt.want != StateOpen {
+				return
+			}
+			if !p.DiffRate.Round(8).Equal(dec(tt.diffRate)) || !p.Notional.Equal(dec(tt.notional)) {
+				t.Errorf("diffRate:%s notional:%s, want %s %s", p.DiffRate, p.Notional, tt.diffRate, tt.notional)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package position
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"move_profit/exchange"
+)
+
+// PendingOrder 已发出但还未拿到结果的订单，崩溃后据此判断哪些腿可能已成交
+type PendingOrder struct {
+	Id         string          `json:"id"`
+	Market     string          `json:"market"`
+	Venue      string          `json:"venue"`
+	Side       exchange.Side   `json:"side"`
+	Quantity   decimal.Decimal `json:"quantity"`
+	CreateTime time.Time       `json:"create_time"`
+}
+
+// Snapshot 仓位和在途订单分别落盘，Load 时合并为一个快照
+type Snapshot struct {
+	Positions  []Position     `json:"positions,omitempty"`
+	Orders     []PendingOrder `json:"orders,omitempty"`
+	UpdateTime time.Time      `json:"update_time"`
+}
+
+// Store 在途订单每次下单都会变化，与仓位分开保存，记录在途订单时不需要重写所有仓位
+type Store interface {
+	SavePositions(list []Position) error
+	SaveOrders(list []PendingOrder) error
+	Load() (*Snapshot, error)
+}
+
+// FileStore 仓位保存在 path，在途订单保存在同目录的 .orders 文件(如 state.orders.json)，
+// 都以 json 快照先写临时文件再 rename 保证原子性
+type FileStore struct {
+	path       string
+	ordersPath string
+}
+
+func NewFileStore(path string) *FileStore {
+	ext := filepath.Ext(path)
+	return &FileStore{path: path, ordersPath: strings.TrimSuffix(path, ext) + ".orders" + ext}
+}
+
+func (s *FileStore) SavePositions(list []Position) error {
+	return writeSnapshot(s.path, &Snapshot{Positions: list, UpdateTime: time.Now()})
+}
+
+func (s *FileStore) SaveOrders(list []PendingOrder) error {
+	return writeSnapshot(s.ordersPath, &Snapshot{Orders: list, UpdateTime: time.Now()})
+}
+
+func writeSnapshot(path string, snapshot *Snapshot) error {
+	body, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(body); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Load 文件不存在时返回空快照；旧版本把在途订单也写在 path 中，同样会加载
+func (s *FileStore) Load() (*Snapshot, error) {
+	snapshot, err := readSnapshot(s.path)
+	if err != nil {
+		return nil, err
+	}
+	orders, err := readSnapshot(s.ordersPath)
+	if err != nil {
+		return nil, err
+	}
+	snapshot.Orders = append(snapshot.Orders, orders.Orders...)
+	return snapshot, nil
+}
+
+func readSnapshot(path string) (*Snapshot, error) {
+	body, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &Snapshot{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	snapshot := new(Snapshot)
+	if err = json.Unmarshal(body, snapshot); err != nil {
+		return nil, fmt.Errorf("parse %s err:%w", path, err)
+	}
+	return snapshot, nil
+}
@@ -0,0 +1,162 @@
+package position
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"move_profit/exchange"
+)
+
+func TestFileStore(t *testing.T) {
+	positions := []Position{
+		{Market: "BTC_USDT", State: StateOpen, BinanceSide: exchange.SideSell, BinanceQuantity: dec("0.01"), GateQuantity: dec("0.01"), DiffRate: dec("0.0012")},
+		{Market: "ETH_USDT", State: StateMismatch, BinanceSide: exchange.SideBuy, GateQuantity: dec("-0.5")},
+	}
+	orders := []PendingOrder{{Id: "mp-1-1", Market: "BTC_USDT", Venue: exchange.Gate, Side: exchange.SideBuy, Quantity: dec("0.01")}}
+
+	tests := []struct {
+		name      string
+		positions string // 仓位文件内容，为空时不创建
+		orders    string // 在途订单文件内容，为空时不创建
+		save      bool
+		want      *Snapshot
+		wantErr   bool
+	}{
+		{name: "missing files", want: &Snapshot{}},
+		{name: "round trip", save: true, want: &Snapshot{Positions: positions, Orders: orders}},
+		{
+			name:      "legacy file with orders",
+			positions: `{"positions":[{"market":"BTC_USDT","state":"open","gate_quantity":"1"}],"orders":[{"id":"mp-1-1","market":"BTC_USDT"}]}`,
+			want:      &Snapshot{Positions: []Position{{Market: "BTC_USDT", State: StateOpen, GateQuantity: dec("1")}}, Orders: []PendingOrder{{Id: "mp-1-1", Market: "BTC_USDT"}}},
+		},
+		{name: "corrupt positions", positions: `{"positions":[{"market":`, wantErr: true},
+		{name: "corrupt orders", positions: `{"positions":[]}`, orders: `not json`, wantErr: true},
+		{name: "wrong type", positions: `{"positions":{"market":"BTC_USDT"}}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			s := NewFileStore(filepath.Join(dir, "data", "state.json"))
+			if s.ordersPath != filepath.Join(dir, "data", "state.orders.json") {
+				t.Fatalf("orders path = %s", s.ordersPath)
+			}
+			write := func(path, body string) {
+				if body == "" {
+					return
+				}
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			write(s.path, tt.positions)
+			write(s.ordersPath, tt.orders)
+			if tt.save {
+				if err := s.SavePositions(positions); err != nil {
+					t.Fatal(err)
+				}
+				if err := s.SaveOrders(orders); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := s.Load()
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("snapshot = %+v, want err", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("err:%v", err)
+			}
+			if len(got.Positions) != len(tt.want.Positions) || len(got.Orders) != len(tt.want.Orders) {
+				t.Fatalf("snapshot = %+v, want %+v", got, tt.want)
+			}
+			for i, p := range got.Positions {
+				w := tt.want.Positions[i]
+				if p.Market != w.Market || p.State != w.State || p.BinanceSide != w.BinanceSide ||
+					!p.BinanceQuantity.Equal(w.BinanceQuantity) || !p.GateQuantity.Equal(w.GateQuantity) || !p.DiffRate.Equal(w.DiffRate) {
+					t.Errorf("position %d = %+v, want %+v", i, p, w)
+				}
+			}
+			for i, o := range got.Orders {
+				w := tt.want.Orders[i]
+				if o.Id != w.Id || o.Market != w.Market || o.Venue != w.Venue || o.Side != w.Side || !o.Quantity.Equal(w.Quantity) {
+					t.Errorf("order %d = %+v, want %+v", i, o, w)
+				}
+			}
+		})
+	}
+}
+
+// countStore 记录每个文件的写入次数和最后一次写入的内容
+type countStore struct {
+	mu        sync.Mutex
+	positions [][]Position
+	orders    [][]PendingOrder
+}
+
+func (s *countStore) SavePositions(list []Position) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.positions = append(s.positions, list)
+	return nil
+}
+
+func (s *countStore) SaveOrders(list []PendingOrder) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.orders = append(s.orders, list)
+	return nil
+}
+
+func (s *countStore) Load() (*Snapshot, error) {
+	return &Snapshot{}, nil
+}
+
+func TestManagerPersist(t *testing.T) {
+	store := &countStore{}
+	m := NewManager(10, dec("1000"), store)
+
+	id := m.BeginOrder("BTC_USDT", exchange.Gate, exchange.SideBuy, dec("1"))
+	m.EndOrder(id)
+	if len(store.positions) != 0 || len(store.orders) != 2 || len(store.orders[0]) != 1 || len(store.orders[1]) != 0 {
+		t.Fatalf("order bookkeeping writes positions:%d orders:%+v", len(store.positions), store.orders)
+	}
+
+	if err := m.Reserve(&Position{Market: "BTC_USDT", Notional: dec("100")}); err != nil {
+		t.Fatal(err)
+	}
+	// 额度不足时没有变更，不写入
+	if err := m.Reserve(&Position{Market: "BTC_USDT", Notional: dec("100")}); err == nil {
+		t.Fatal("duplicate reserve succeeded")
+	}
+	m.Release("ETH_USDT")
+	if len(store.positions) != 1 || len(store.orders) != 2 {
+		t.Fatalf("writes positions:%d orders:%d, want 1 2", len(store.positions), len(store.orders))
+	}
+	if got := store.positions[0]; len(got) != 1 || got[0].State != StateOpening {
+		t.Errorf("saved positions = %+v", got)
+	}
+
+	// 并发变更时最后写入的快照必须包含所有仓位
+	var wg sync.WaitGroup
+	for _, market := range []string{"ETH_USDT", "SOL_USDT", "XRP_USDT", "DOGE_USDT"} {
+		wg.Add(1)
+		go func(market string) {
+			defer wg.Done()
+			if err := m.Reserve(&Position{Market: market, Notional: dec("100")}); err != nil {
+				t.Error(err)
+			}
+		}(market)
+	}
+	wg.Wait()
+	if last := store.positions[len(store.positions)-1]; len(last) != 5 {
+		t.Errorf("last saved positions = %d, want 5", len(last))
+	}
+}
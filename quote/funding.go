@@ -0,0 +1,55 @@
+package quote
+
+import (
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"move_profit/exchange"
+)
+
+// Funding 资金费率，Rate 为正时多仓向空仓支付
+type Funding struct {
+	Rate       decimal.Decimal // 下一次结算的资金费率
+	NextTime   time.Time       // 下一次结算时间
+	Interval   time.Duration   // 结算间隔
+	UpdateTime time.Time       // 本地收到的时间
+}
+
+// Settlements 返回 (now, now+horizon] 内的结算次数
+func (f Funding) Settlements(now time.Time, horizon time.Duration) int {
+	if f.NextTime.IsZero() {
+		return 0
+	}
+	end := now.Add(horizon)
+	n := 0
+	for t := f.NextTime; !t.After(end); t = t.Add(f.Interval) {
+		if t.After(now) {
+			n++
+		}
+		if f.Interval <= 0 {
+			break
+		}
+	}
+	return n
+}
+
+var fundingMap = map[string]*sync.Map{
+	exchange.Binance: {},
+	exchange.Gate:    {},
+}
+
+func StoreFunding(venue, market string, f Funding) {
+	if f.UpdateTime.IsZero() {
+		f.UpdateTime = time.Now()
+	}
+	fundingMap[venue].Store(market, f)
+}
+
+func GetFunding(venue, market string) (Funding, bool) {
+	val, ok := fundingMap[venue].Load(market)
+	if !ok {
+		return Funding{}, false
+	}
+	return val.(Funding), true
+}
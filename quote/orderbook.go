@@ -0,0 +1,64 @@
+package quote
+
+import (
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"move_profit/exchange"
+)
+
+type Level struct {
+	Price    decimal.Decimal
+	Quantity decimal.Decimal // 基础币数量
+}
+
+// OrderBook 前 N 档盘口快照，Bids 价格从高到低，Asks 价格从低到高
+type OrderBook struct {
+	Bids       []Level
+	Asks       []Level
+	UpdateTime time.Time // 本地收到的时间
+}
+
+// ImpactPrice 按方向吃掉 quantity 的成交均价(VWAP)，盘口深度不足时 ok 为 false
+func (b OrderBook) ImpactPrice(side exchange.Side, quantity decimal.Decimal) (decimal.Decimal, bool) {
+	levels := b.Asks
+	if side == exchange.SideSell {
+		levels = b.Bids
+	}
+	if !quantity.IsPositive() {
+		return decimal.Zero, false
+	}
+
+	left := quantity
+	cost := decimal.Zero
+	for _, l := range levels {
+		fill := decimal.Min(left, l.Quantity)
+		cost = cost.Add(fill.Mul(l.Price))
+		left = left.Sub(fill)
+		if !left.IsPositive() {
+			return cost.Div(quantity), true
+		}
+	}
+	return decimal.Zero, false
+}
+
+var orderBookMap = map[string]*sync.Map{
+	exchange.Binance: {},
+	exchange.Gate:    {},
+}
+
+func StoreOrderBook(venue, market string, b OrderBook) {
+	if b.UpdateTime.IsZero() {
+		b.UpdateTime = time.Now()
+	}
+	orderBookMap[venue].Store(market, b)
+}
+
+func GetOrderBook(venue, market string) (OrderBook, bool) {
+	val, ok := orderBookMap[venue].Load(market)
+	if !ok {
+		return OrderBook{}, false
+	}
+	return val.(OrderBook), true
+}
@@ -0,0 +1,58 @@
+package quote
+
+import (
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"move_profit/exchange"
+)
+
+// BookTicker 最优买卖价
+type BookTicker struct {
+	Bid        decimal.Decimal
+	BidSize    decimal.Decimal
+	Ask        decimal.Decimal
+	AskSize    decimal.Decimal
+	UpdateTime time.Time // 本地收到的时间
+}
+
+// Price 按方向返回可成交价格：买入用卖一价，卖出用买一价
+func (b BookTicker) Price(side exchange.Side) decimal.Decimal {
+	if side == exchange.SideBuy {
+		return b.Ask
+	}
+	return b.Bid
+}
+
+var bookTickerMap = map[string]*sync.Map{
+	exchange.Binance: {},
+	exchange.Gate:    {},
+}
+
+func StoreBookTicker(venue, market string, b BookTicker) {
+	if b.UpdateTime.IsZero() {
+		b.UpdateTime = time.Now()
+	}
+	bookTickerMap[venue].Store(market, b)
+}
+
+func GetBookTicker(venue, market string) (BookTicker, bool) {
+	val, ok := bookTickerMap[venue].Load(market)
+	if !ok {
+		return BookTicker{}, false
+	}
+	return val.(BookTicker), true
+}
+
+// QuoteFunc 返回某个交易所的可成交价格查询函数，供模拟盘和滑点检查使用
+func QuoteFunc(venue string) exchange.QuoteFunc {
+	return func(market string, side exchange.Side) (decimal.Decimal, bool) {
+		b, ok := GetBookTicker(venue, market)
+		if !ok {
+			return decimal.Zero, false
+		}
+		price := b.Price(side)
+		return price, price.IsPositive()
+	}
+}
@@ -0,0 +1,111 @@
+package ratelimit
+
+import (
+	"move_profit/exchange"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// binance U本位合约限频：IP 权重 REQUEST_WEIGHT 和账户下单数 ORDERS，
+// 计数器名为 weight:1m、orders:10s、orders:1m，与响应头 X-MBX-USED-WEIGHT-1M、X-MBX-ORDER-COUNT-10S 对应
+const (
+	binanceWeightPrefix = "x-mbx-used-weight-"
+	binanceOrderPrefix  = "x-mbx-order-count-"
+)
+
+// binanceWeights 各接口的 IP 权重，未列出的按 1 计
+var binanceWeights = map[string]int{
+	"POST /fapi/v1/order":         0,
+	"GET /fapi/v2/positionRisk":   5,
+	"GET /fapi/v1/commissionRate": 20,
+	"GET /fapi/v1/openOrders":     1,
+	"GET /fapi/v1/allOrders":      5,
+	"GET /fapi/v2/account":        5,
+	"GET /fapi/v2/balance":        5,
+}
+
+// binanceOrderPaths 计入下单数的接口
+var binanceOrderPaths = map[string]bool{
+	"POST /fapi/v1/order":       true,
+	"POST /fapi/v1/batchOrders": true,
+}
+
+func newBinanceLimiter() *Limiter {
+	l := &Limiter{
+		venue:    exchange.Binance,
+		cost:     binanceCost,
+		observe:  observeBinance,
+		counters: make(map[string]*counter),
+	}
+	// 默认值，启动后用 exchangeInfo 中的 rateLimits 覆盖
+	l.SetLimit(BinanceCounter("REQUEST_WEIGHT", "MINUTE", 1), 2400, time.Minute)
+	l.SetLimit(BinanceCounter("ORDERS", "SECOND", 10), 300, 10*time.Second)
+	l.SetLimit(BinanceCounter("ORDERS", "MINUTE", 1), 1200, time.Minute)
+	return l
+}
+
+// BinanceCounter exchangeInfo rateLimits 对应的计数器名，如 REQUEST_WEIGHT/MINUTE/1 -> weight:1m
+func BinanceCounter(rateLimitType, interval string, intervalNum int64) string {
+	prefix := "weight:"
+	if rateLimitType == "ORDERS" {
+		prefix = "orders:"
+	}
+	return prefix + strconv.FormatInt(intervalNum, 10) + strings.ToLower(interval[:1])
+}
+
+// BinanceInterval exchangeInfo rateLimits 的窗口长度
+func BinanceInterval(interval string, intervalNum int64) time.Duration {
+	unit := time.Minute
+	switch interval {
+	case "SECOND":
+		unit = time.Second
+	case "HOUR":
+		unit = time.Hour
+	case "DAY":
+		unit = 24 * time.Hour
+	}
+	return time.Duration(intervalNum) * unit
+}
+
+func binanceCost(req *http.Request) []Cost {
+	key := req.Method + " " + req.URL.Path
+	weight, ok := binanceWeights[key]
+	if !ok {
+		weight = 1
+	}
+	// 不带 symbol 查询所有市场的挂单权重为 40
+	if key == "GET /fapi/v1/openOrders" && req.URL.Query().Get("symbol") == "" {
+		weight = 40
+	}
+	costs := []Cost{{Counter: "weight:1m", Weight: weight}}
+	if binanceOrderPaths[key] {
+		costs = append(costs, Cost{Counter: "orders:10s", Weight: 1}, Cost{Counter: "orders:1m", Weight: 1})
+	}
+	return costs
+}
+
+// observeBinance 读取 X-MBX-USED-WEIGHT-<interval> / X-MBX-ORDER-COUNT-<interval>
+func observeBinance(l *Limiter, req *http.Request, header http.Header) {
+	for key, values := range header {
+		if len(values) == 0 {
+			continue
+		}
+		lower := strings.ToLower(key)
+		var name string
+		switch {
+		case strings.HasPrefix(lower, binanceWeightPrefix):
+			name = "weight:" + lower[len(binanceWeightPrefix):]
+		case strings.HasPrefix(lower, binanceOrderPrefix):
+			name = "orders:" + lower[len(binanceOrderPrefix):]
+		default:
+			continue
+		}
+		used, err := strconv.Atoi(values[0])
+		if err != nil {
+			continue
+		}
+		l.setUsed(name, used, 0, time.Time{})
+	}
+}
@@ -0,0 +1,61 @@
+package ratelimit
+
+import (
+	"move_profit/exchange"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// gate 按接口限频，额度和窗口只能从响应头 X-Gate-RateLimit-Limit / -Requests-Remain / -Reset-Timestamp 得到，
+// 收到第一个响应之前不限制
+const (
+	gateLimitHeader  = "X-Gate-RateLimit-Limit"
+	gateRemainHeader = "X-Gate-RateLimit-Requests-Remain"
+	gateResetHeader  = "X-Gate-RateLimit-Reset-Timestamp"
+)
+
+func newGateLimiter() *Limiter {
+	return &Limiter{
+		venue:    exchange.Gate,
+		cost:     gateCost,
+		observe:  observeGate,
+		counters: make(map[string]*counter),
+	}
+}
+
+// gateEndpoint 接口名，路径中的合约名和订单号替换为 *，如 POST /api/v4/futures/usdt/positions/*/leverage
+func gateEndpoint(req *http.Request) string {
+	segments := strings.Split(req.URL.Path, "/")
+	for i, s := range segments {
+		if strings.IndexFunc(s, func(r rune) bool { return (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') }) >= 0 && s != "v4" {
+			segments[i] = "*"
+		}
+	}
+	return req.Method + " " + strings.Join(segments, "/")
+}
+
+func gateCost(req *http.Request) []Cost {
+	return []Cost{{Counter: gateEndpoint(req), Weight: 1}}
+}
+
+func observeGate(l *Limiter, req *http.Request, header http.Header) {
+	limit, err := strconv.Atoi(header.Get(gateLimitHeader))
+	if err != nil {
+		return
+	}
+	remain, err := strconv.Atoi(header.Get(gateRemainHeader))
+	if err != nil {
+		return
+	}
+	var reset time.Time
+	if ms, err := strconv.ParseInt(header.Get(gateResetHeader), 10, 64); err == nil {
+		// 文档为毫秒，兼容秒级时间戳
+		if ms < 1e12 {
+			ms *= 1000
+		}
+		reset = time.UnixMilli(ms)
+	}
+	l.setUsed(gateEndpoint(req), limit-remain, limit, reset)
+}
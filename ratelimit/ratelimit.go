@@ -0,0 +1,281 @@
+// Package ratelimit 交易所 REST 限频：本地按权重计数，用服务器返回的用量头校正，
+// 429/418 时按 Retry-After 暂停该交易所的所有请求
+package ratelimit
+
+import (
+	"fmt"
+	"move_profit/alert"
+	"move_profit/exchange"
+	"move_profit/log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	maxWait     = 2 * time.Second // 超过该等待时间直接返回 LimitError，不阻塞调用方
+	baseBackoff = time.Second
+	maxBackoff  = 5 * time.Minute
+)
+
+// LimitError 请求因限频未发出
+type LimitError struct {
+	Venue   string
+	Counter string
+	Until   time.Time
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s rate limited by %s until %s", e.Venue, e.Counter, e.Until.Format("15:04:05.000"))
+}
+
+// Cost 一次请求在某个计数器上的权重
+type Cost struct {
+	Counter string
+	Weight  int
+}
+
+// Usage 计数器当前用量，Reset 为零时表示窗口未知
+type Usage struct {
+	Counter string
+	Used    int
+	Limit   int
+	Reset   time.Time
+}
+
+// counter 固定窗口计数器，period 为 0 时窗口结束时间只来自服务器头部
+type counter struct {
+	used   int
+	limit  int
+	period time.Duration
+	reset  time.Time
+}
+
+func (c *counter) roll(now time.Time) {
+	if c.reset.IsZero() || now.Before(c.reset) {
+		return
+	}
+	c.used = 0
+	if c.period > 0 {
+		c.reset = now.Truncate(c.period).Add(c.period)
+	} else {
+		c.reset = time.Time{}
+	}
+}
+
+type Limiter struct {
+	venue   string
+	cost    func(req *http.Request) []Cost
+	observe func(l *Limiter, req *http.Request, header http.Header)
+
+	mu       sync.Mutex
+	counters map[string]*counter
+	until    time.Time // 429/418 后暂停到该时间
+	failures int       // 连续 429/418 次数
+}
+
+var limiters = map[string]*Limiter{
+	exchange.Binance: newBinanceLimiter(),
+	exchange.Gate:    newGateLimiter(),
+}
+
+// Get 返回某个交易所的限频器
+func Get(venue string) *Limiter {
+	return limiters[venue]
+}
+
+func (l *Limiter) Venue() string {
+	return l.venue
+}
+
+// SetLimit 设置计数器上限和窗口长度，已有用量保留
+func (l *Limiter) SetLimit(name string, limit int, period time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	c := l.counter(name)
+	c.limit, c.period = limit, period
+	if period > 0 && c.reset.IsZero() {
+		c.reset = time.Now().Truncate(period).Add(period)
+	}
+}
+
+func (l *Limiter) counter(name string) *counter {
+	c, ok := l.counters[name]
+	if !ok {
+		c = &counter{}
+		l.counters[name] = c
+	}
+	return c
+}
+
+// Acquire 占用权重，需要等待的时间不超过 maxWait 时阻塞等待，否则返回 *LimitError
+func (l *Limiter) Acquire(costs []Cost) error {
+	for {
+		wait, err := l.tryAcquire(costs, time.Now())
+		if err != nil || wait == 0 {
+			return err
+		}
+		time.Sleep(wait)
+	}
+}
+
+func (l *Limiter) tryAcquire(costs []Cost, now time.Time) (time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Before(l.until) {
+		return l.waitOrFail("retry-after", l.until, now)
+	}
+	for _, cost := range costs {
+		c := l.counter(cost.Counter)
+		c.roll(now)
+		if c.limit > 0 && !c.reset.IsZero() && c.used+cost.Weight > c.limit {
+			return l.waitOrFail(cost.Counter, c.reset, now)
+		}
+	}
+	for _, cost := range costs {
+		l.counters[cost.Counter].used += cost.Weight
+	}
+	return 0, nil
+}
+
+func (l *Limiter) waitOrFail(name string, until time.Time, now time.Time) (time.Duration, error) {
+	wait := until.Sub(now)
+	if wait > maxWait {
+		return 0, &LimitError{Venue: l.venue, Counter: name, Until: until}
+	}
+	return wait, nil
+}
+
+// setUsed 用服务器返回的用量校正本地计数，同一窗口内取两者较大值(本地还包含在途请求)；
+// reset 为服务器给出的窗口结束时间，为零时沿用本地窗口
+func (l *Limiter) setUsed(name string, used, limit int, reset time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	c := l.counter(name)
+	c.roll(time.Now())
+	if !reset.IsZero() && !reset.Equal(c.reset) {
+		c.used, c.reset = used, reset
+	} else if used > c.used {
+		c.used = used
+	}
+	if limit > 0 {
+		c.limit = limit
+	}
+}
+
+// onResponse 处理响应状态和用量头部
+func (l *Limiter) onResponse(req *http.Request, resp *http.Response) {
+	if l.observe != nil {
+		l.observe(l, req, resp.Header)
+	}
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusTeapot {
+		l.mu.Lock()
+		l.failures = 0
+		l.mu.Unlock()
+		return
+	}
+
+	l.mu.Lock()
+	backoff := baseBackoff << l.failures
+	if backoff > maxBackoff || backoff <= 0 {
+		backoff = maxBackoff
+	}
+	l.failures++
+	if retryAfter := parseRetryAfter(resp.Header.Get("Retry-After")); retryAfter > backoff {
+		backoff = retryAfter
+	}
+	until := time.Now().Add(backoff)
+	if until.After(l.until) {
+		l.until = until
+	}
+	l.mu.Unlock()
+
+	if resp.StatusCode == http.StatusTeapot {
+		alert.Send("[%s] ip banned (418) on %s %s, requests paused until %s", l.venue, req.Method, req.URL.Path, until.Format("15:04:05"))
+	} else {
+		log.Log.Warningf("[%s] rate limited (429) on %s %s, requests paused until %s", l.venue, req.Method, req.URL.Path, until.Format("15:04:05.000"))
+	}
+}
+
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
+
+// Usage 各计数器当前用量，按名称排序
+func (l *Limiter) Usage() []Usage {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	list := make([]Usage, 0, len(l.counters))
+	for name, c := range l.counters {
+		c.roll(now)
+		list = append(list, Usage{Counter: name, Used: c.used, Limit: c.limit, Reset: c.reset})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Counter < list[j].Counter
+	})
+	return list
+}
+
+// PausedUntil 429/418 后的暂停截止时间，未暂停时为零值
+func (l *Limiter) PausedUntil() time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if time.Now().Before(l.until) {
+		return l.until
+	}
+	return time.Time{}
+}
+
+// Report 用量汇总，只列出有额度的计数器，如 [ratelimit binance] weight:1m:35/2400 orders:10s:2/300
+func (l *Limiter) Report() string {
+	items := make([]string, 0)
+	for _, u := range l.Usage() {
+		if u.Limit > 0 {
+			items = append(items, fmt.Sprintf("%s:%d/%d", u.Counter, u.Used, u.Limit))
+		}
+	}
+	report := fmt.Sprintf("[ratelimit %s] %s", l.venue, strings.Join(items, " "))
+	if until := l.PausedUntil(); !until.IsZero() {
+		report += " paused until " + until.Format("15:04:05")
+	}
+	return report
+}
+
+// Transport 包装 http.RoundTripper，发请求前占用权重，收到响应后更新用量
+func (l *Limiter) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{limiter: l, base: base}
+}
+
+type transport struct {
+	limiter *Limiter
+	base    http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.limiter.Acquire(t.limiter.cost(req)); err != nil {
+		return nil, err
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	t.limiter.onResponse(req, resp)
+	return resp, nil
+}
@@ -0,0 +1,90 @@
+package sizing
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/shopspring/decimal"
+	"move_profit/exchange"
+)
+
+// Rule 两个交易所都能精确下单的数量规则：Step 为两边数量步长的最小公倍数，
+// Min、Max 为两边限制的交集并对齐到 Step，Max 为 0 时不限制
+type Rule struct {
+	Step        decimal.Decimal
+	Min         decimal.Decimal
+	Max         decimal.Decimal
+	MinNotional decimal.Decimal
+}
+
+// Match 计算两个合约的共同数量规则，不存在两边都能下单的数量时返回错误
+func Match(a, b exchange.Contract) (Rule, error) {
+	if !a.QuantityStep.IsPositive() || !b.QuantityStep.IsPositive() {
+		return Rule{}, fmt.Errorf("market %s quantity step %s/%s invalid", a.Market, a.QuantityStep, b.QuantityStep)
+	}
+	step := lcm(a.QuantityStep, b.QuantityStep)
+	r := Rule{
+		Step:        step,
+		Min:         ceil(decimal.Max(a.MinQuantity, b.MinQuantity, step), step),
+		Max:         minLimit(a.MaxQuantity, b.MaxQuantity),
+		MinNotional: decimal.Max(a.MinNotional, b.MinNotional),
+	}
+	if r.Max.IsPositive() {
+		r.Max = floor(r.Max, step)
+		if r.Max.LessThan(r.Min) {
+			return Rule{}, fmt.Errorf("market %s no quantity fits both venues: step %s min %s max %s", a.Market, step, r.Min, r.Max)
+		}
+	}
+	return r, nil
+}
+
+// Quantity 名义价值不超过 notional 的最大可下单数量，price 为估算成交价；
+// 低于最小数量或最小名义价值时返回错误
+func (r Rule) Quantity(notional, price decimal.Decimal) (decimal.Decimal, error) {
+	if !price.IsPositive() {
+		return decimal.Zero, fmt.Errorf("invalid price %s", price)
+	}
+	quantity := floor(notional.Div(price), r.Step)
+	if r.Max.IsPositive() && quantity.GreaterThan(r.Max) {
+		quantity = r.Max
+	}
+	if quantity.LessThan(r.Min) {
+		return decimal.Zero, fmt.Errorf("notional %s at price %s is less than min quantity %s", notional, price, r.Min)
+	}
+	if quantity.Mul(price).LessThan(r.MinNotional) {
+		return decimal.Zero, fmt.Errorf("quantity %s at price %s is less than min notional %s", quantity, price, r.MinNotional)
+	}
+	return quantity, nil
+}
+
+// lcm 两个正数步长的最小公倍数，按两者中较多的小数位放大为整数后计算
+func lcm(a, b decimal.Decimal) decimal.Decimal {
+	exp := a.Exponent()
+	if b.Exponent() < exp {
+		exp = b.Exponent()
+	}
+	x, y := a.Shift(-exp).BigInt(), b.Shift(-exp).BigInt()
+	gcd := new(big.Int).GCD(nil, nil, x, y)
+	l := new(big.Int).Mul(x, y)
+	l.Quo(l, gcd)
+	return decimal.NewFromBigInt(l, exp)
+}
+
+func floor(d, step decimal.Decimal) decimal.Decimal {
+	return d.Div(step).Floor().Mul(step)
+}
+
+func ceil(d, step decimal.Decimal) decimal.Decimal {
+	return d.Div(step).Ceil().Mul(step)
+}
+
+// minLimit 两个上限中较小的一个，0 为不限制
+func minLimit(a, b decimal.Decimal) decimal.Decimal {
+	if !a.IsPositive() {
+		return b
+	}
+	if !b.IsPositive() {
+		return a
+	}
+	return decimal.Min(a, b)
+}
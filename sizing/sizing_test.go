@@ -0,0 +1,132 @@
+package sizing
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"move_profit/exchange"
+)
+
+func dec(s string) decimal.Decimal {
+	return decimal.RequireFromString(s)
+}
+
+func contract(step, min, max, minNotional string) exchange.Contract {
+	return exchange.Contract{Market: "BTC_USDT", QuantityStep: dec(step), MinQuantity: dec(min), MaxQuantity: dec(max), MinNotional: dec(minNotional)}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b    exchange.Contract
+		want    Rule
+		wantErr bool
+	}{
+		{
+			name: "same step",
+			a:    contract("0.001", "0.001", "100", "5"),
+			b:    contract("0.001", "0.001", "0", "0"),
+			want: Rule{Step: dec("0.001"), Min: dec("0.001"), Max: dec("100"), MinNotional: dec("5")},
+		},
+		{
+			name: "step is a multiple",
+			a:    contract("0.1", "0", "0", "0"),
+			b:    contract("0.3", "0", "0", "0"),
+			want: Rule{Step: dec("0.3"), Min: dec("0.3"), Max: dec("0"), MinNotional: dec("0")},
+		},
+		{
+			name: "step is the least common multiple",
+			a:    contract("0.04", "0", "0", "0"),
+			b:    contract("0.06", "0", "0", "0"),
+			want: Rule{Step: dec("0.12"), Min: dec("0.12"), Max: dec("0"), MinNotional: dec("0")},
+		},
+		{
+			name: "different decimal places",
+			a:    contract("0.0001", "0", "0", "0"),
+			b:    contract("10", "10", "0", "0"),
+			want: Rule{Step: dec("10"), Min: dec("10"), Max: dec("0"), MinNotional: dec("0")},
+		},
+		{
+			name: "min and max aligned to step",
+			a:    contract("0.2", "0.5", "10.5", "0"),
+			b:    contract("0.3", "0.1", "20", "10"),
+			want: Rule{Step: dec("0.6"), Min: dec("0.6"), Max: dec("10.2"), MinNotional: dec("10")},
+		},
+		{
+			name: "smaller max wins",
+			a:    contract("1", "1", "50", "0"),
+			b:    contract("1", "2", "30", "0"),
+			want: Rule{Step: dec("1"), Min: dec("2"), Max: dec("30"), MinNotional: dec("0")},
+		},
+		{
+			name:    "max below min",
+			a:       contract("1", "5", "0", "0"),
+			b:       contract("1", "1", "4", "0"),
+			wantErr: true,
+		},
+		{
+			name:    "max below step",
+			a:       contract("0.3", "0", "0", "0"),
+			b:       contract("0.1", "0", "0.2", "0"),
+			wantErr: true,
+		},
+		{
+			name:    "invalid step",
+			a:       contract("0", "0", "0", "0"),
+			b:       contract("0.1", "0", "0", "0"),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Match(tt.a, tt.b)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !got.Step.Equal(tt.want.Step) || !got.Min.Equal(tt.want.Min) || !got.Max.Equal(tt.want.Max) || !got.MinNotional.Equal(tt.want.MinNotional) {
+				t.Errorf("rule = %+v, want %+v", got, tt.want)
+			}
+			// 顺序无关
+			swapped, err := Match(tt.b, tt.a)
+			if err != nil || !swapped.Step.Equal(got.Step) || !swapped.Min.Equal(got.Min) || !swapped.Max.Equal(got.Max) {
+				t.Errorf("swapped rule = %+v err:%v, want %+v", swapped, err, got)
+			}
+		})
+	}
+}
+
+func TestQuantity(t *testing.T) {
+	rule := Rule{Step: dec("0.3"), Min: dec("0.6"), Max: dec("3"), MinNotional: dec("5")}
+	tests := []struct {
+		name     string
+		rule     Rule
+		notional string
+		price    string
+		want     string
+		wantErr  bool
+	}{
+		{name: "rounded down to step", rule: rule, notional: "100", price: "50", want: "1.8"},
+		{name: "exact multiple", rule: rule, notional: "90", price: "50", want: "1.8"},
+		{name: "capped by max", rule: rule, notional: "10000", price: "50", want: "3"},
+		{name: "min quantity", rule: rule, notional: "30", price: "50", want: "0.6"},
+		{name: "below min quantity", rule: rule, notional: "29", price: "50", wantErr: true},
+		{name: "below min notional", rule: rule, notional: "4.5", price: "7", wantErr: true},
+		{name: "no max", rule: Rule{Step: dec("1"), Min: dec("1")}, notional: "1000000", price: "1", want: "1000000"},
+		{name: "zero price", rule: rule, notional: "100", price: "0", wantErr: true},
+		{name: "negative price", rule: rule, notional: "100", price: "-1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.rule.Quantity(dec(tt.notional), dec(tt.price))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !got.Equal(dec(tt.want)) {
+				t.Errorf("quantity = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
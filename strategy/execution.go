@@ -0,0 +1,290 @@
+package strategy
+
+import (
+	"errors"
+	"fmt"
+	"move_profit/alert"
+	"move_profit/config"
+	"move_profit/exchange"
+	"move_profit/log"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+type pairResult int
+
+const (
+	pairDone        pairResult = iota
+	pairFirstFailed            // 第一腿没有成交，无需处理
+	pairUnwound                // 第二腿失败，第一腿已成交部分已回滚
+	pairNaked                  // 第二腿失败且回滚失败，存在单腿敞口
+)
+
+func (r pairResult) String() string {
+	switch r {
+	case pairDone:
+		return "done"
+	case pairFirstFailed:
+		return "first_failed"
+	case pairUnwound:
+		return "unwound"
+	case pairNaked:
+		return "naked"
+	}
+	return "unknown"
+}
+
+type leg struct {
+	ex         exchange.Exchange
+	contract   exchange.Contract
+	req        exchange.OrderRequest
+	quote      exchange.QuoteFunc
+	quotePrice decimal.Decimal // 信号价格，用于判断滑点
+}
+
+// slipped 当前价格相对信号价格的不利变动超过预算
+func (l *leg) slipped(maxSlippage decimal.Decimal) bool {
+	if l.quote == nil || !l.quotePrice.IsPositive() {
+		return false
+	}
+	cur, ok := l.quote(l.req.Market, l.req.Side)
+	if !ok {
+		return false
+	}
+	move := cur.Sub(l.quotePrice).Div(l.quotePrice)
+	if l.req.Side == exchange.SideSell {
+		move = move.Neg()
+	}
+	return move.GreaterThan(maxSlippage)
+}
+
+type pairExecution struct {
+	result      pairResult
+	firstOrder  *exchange.Order
+	secondOrder *exchange.Order
+	// 两腿实际成交的数量和均价
+	firstQuantity  decimal.Decimal
+	secondQuantity decimal.Decimal
+	firstPrice     decimal.Decimal
+	secondPrice    decimal.Decimal
+	secondRetries  int
+	err            error
+}
+
+// executePair 先下第一腿(IOC 或市价单)，按第一腿成交比例下第二腿。
+// 第二腿在次数、时间和滑点预算内重试，仍失败则反向回滚第一腿已成交部分
+func executePair(first, second *leg) *pairExecution {
+	res := &pairExecution{}
+
+	firstOrder, _, err := placeOrder(first.ex, first.req, nil)
+	if err == nil {
+		firstOrder, err = settleOrder(first.ex, firstOrder)
+	}
+	if err != nil {
+		res.result, res.err = pairFirstFailed, err
+		return res
+	}
+	res.firstOrder = firstOrder
+	res.firstQuantity = firstOrder.FilledQuantity
+	res.firstPrice = fillPrice(firstOrder, first.quotePrice)
+	if !res.firstQuantity.IsPositive() {
+		res.result, res.err = pairFirstFailed, fmt.Errorf("%s order %s not filled", first.ex.Name(), firstOrder.Id)
+		return res
+	}
+
+	res.secondQuantity = second.req.Quantity
+	if res.firstQuantity.LessThan(first.req.Quantity) {
+		res.secondQuantity = second.contract.RoundQuantity(second.req.Quantity.Mul(res.firstQuantity).Div(first.req.Quantity))
+	}
+
+	if res.secondQuantity.IsPositive() {
+		req := second.req
+		req.Quantity = res.secondQuantity
+		res.secondOrder, res.secondRetries, err = retryOrder(second, req)
+		if err == nil {
+			res.secondQuantity = res.secondOrder.FilledQuantity
+			res.secondPrice = fillPrice(res.secondOrder, second.quotePrice)
+			res.result = pairDone
+			return res
+		}
+	} else {
+		err = fmt.Errorf("%s quantity for filled %s is too small to hedge", second.ex.Name(), res.firstQuantity)
+	}
+	res.err = err
+
+	// 回滚第一腿
+	// 开仓的回滚是平掉刚开的仓位，只减仓；平仓的回滚是重新开回仓位
+	left := unwindOrder(first.ex, exchange.OrderRequest{Market: first.req.Market, Side: first.req.Side.Opposite(), Quantity: res.firstQuantity, ReduceOnly: !first.req.ReduceOnly})
+	if left.IsZero() {
+		res.result = pairUnwound
+		return res
+	}
+	// 未能回滚的数量仍由第一腿持有
+	res.firstQuantity = left
+	res.result = pairNaked
+	return res
+}
+
+const (
+	// 下单结果未知时等待该时长再按自定义订单 id 查询，避免交易所还查不到刚收到的订单
+	resolveDelay = 500 * time.Millisecond
+	// 查到的订单还未结束时等待其结束的时间，吃单很快就会结束
+	resolveWait = 2 * time.Second
+)
+
+// errUnresolved 下单结果未知且查询失败，订单可能已经在交易所，不能重发
+var errUnresolved = errors.New("order outcome unresolved")
+
+// sendOrder 按 req.ClientId 下一个逻辑订单，每次重试都使用同一个自定义订单 id。下单结果未知(网络错误、5xx)时
+// 先按自定义订单 id 查询：交易所已经收到时返回查询到的订单；确认没有该订单时才按 retry 重发；查询失败时告警并返回 errUnresolved。
+// 结果明确的错误只有 exchange.Retryable 时才重发。retry 在每次重发前调用，返回 false 时停止，为 nil 时不重发，返回重发次数
+func sendOrder(ex exchange.Exchange, req exchange.OrderRequest, retry func(i int, err error) bool) (*exchange.Order, int, error) {
+	for i := 0; ; i++ {
+		order, err := orders.Place(ex, req)
+		if err == nil {
+			return order, i, nil
+		}
+		log.Log.Warningf("[execution] %s %s %s %s failed %d times err:%+v", ex.Name(), req.Market, req.Side, req.Quantity, i+1, err)
+		if exchange.OutcomeUnknown(err) {
+			o, found, lookupErr := resolveOrder(ex.Name(), req.ClientId)
+			if lookupErr != nil {
+				alert.Send("[execution] %s %s %s %s order:%s outcome unknown and lookup failed, check it manually err:%+v lookup err:%+v",
+					ex.Name(), req.Market, req.Side, req.Quantity, req.ClientId, err, lookupErr)
+				return nil, i, fmt.Errorf("%w: %s order %s err:%+v lookup err:%+v", errUnresolved, ex.Name(), req.ClientId, err, lookupErr)
+			}
+			if found {
+				log.Log.Warningf("[execution] %s %s order:%s reached exchange despite err, state:%s filled:%s", ex.Name(), req.Market, req.ClientId, o.State, o.FilledQuantity)
+				return o, i, nil
+			}
+			log.Log.Warningf("[execution] %s %s order:%s not found on exchange", ex.Name(), req.Market, req.ClientId)
+		} else if !exchange.Retryable(err) {
+			return nil, i, err
+		}
+		if retry == nil || !retry(i, err) {
+			return nil, i, err
+		}
+	}
+}
+
+// resolveOrder 按自定义订单 id 查询结果未知的订单，查到未结束的订单时等待其结束
+func resolveOrder(venue, clientId string) (*exchange.Order, bool, error) {
+	time.Sleep(resolveDelay)
+	o, found, err := orders.Resolve(venue, clientId)
+	if err != nil || !found {
+		return nil, false, err
+	}
+	if !o.State.Final() {
+		var final bool
+		if o, final = orders.Wait(venue, clientId, resolveWait); !final {
+			if res, err := orders.Refresh(venue, clientId); err == nil {
+				o = res
+			}
+		}
+	}
+	return &o, true, nil
+}
+
+// settleOrder 吃单的下单响应可能只是受理(如 binance 市价单返回 NEW)，等待订单结束；
+// 仍未结束时撤单，以撤单后的最终成交为准。撤单也失败时订单可能仍在成交，告警并返回 errUnresolved
+func settleOrder(ex exchange.Exchange, o *exchange.Order) (*exchange.Order, error) {
+	if o.State.Final() {
+		return o, nil
+	}
+	venue := ex.Name()
+	res, final := orders.Wait(venue, o.ClientId, resolveWait)
+	if !final {
+		if r, err := orders.Refresh(venue, o.ClientId); err == nil {
+			res, final = r, r.State.Final()
+		}
+	}
+	if !final {
+		r, err := orders.Cancel(venue, o.ClientId)
+		if err != nil {
+			alert.Send("[execution] %s %s order:%s state:%s not finished and cancel failed, check it manually err:%+v", venue, o.Market, o.ClientId, res.State, err)
+			return nil, fmt.Errorf("%w: %s order %s not finished, cancel err:%+v", errUnresolved, venue, o.ClientId, err)
+		}
+		res = r
+	}
+	return &res, nil
+}
+
+// retryOrder 吃单腿下单，在次数、时间和滑点预算内重试，返回有成交的订单和重试次数。
+// 同一个订单结果未知时按同一个自定义订单 id 重发；订单结束但没有成交(IOC 过期、被拒)时用新的订单重试
+func retryOrder(l *leg, req exchange.OrderRequest) (*exchange.Order, int, error) {
+	conf := config.Conf.Execution
+	deadline := time.Now().Add(conf.LegRetryTimeout.Duration())
+	retries := 0
+	retry := func() bool {
+		if retries >= conf.LegRetryTimes || time.Now().After(deadline) {
+			return false
+		}
+		if l.slipped(conf.MaxSlippage) {
+			log.Log.Warningf("[execution] %s %s price moved over %s, stop retry", l.ex.Name(), req.Market, conf.MaxSlippage)
+			return false
+		}
+		retries++
+		time.Sleep(time.Millisecond * 100 * time.Duration(retries))
+		return true
+	}
+	for {
+		order, _, err := placeOrder(l.ex, req, func(int, error) bool { return retry() })
+		if err == nil {
+			order, err = settleOrder(l.ex, order)
+		}
+		if err != nil {
+			return nil, retries, err
+		}
+		if order.FilledQuantity.IsPositive() {
+			return order, retries, nil
+		}
+		err = fmt.Errorf("%s %s order %s %s without fill", l.ex.Name(), req.Market, order.ClientId, order.State)
+		log.Log.Warningf("[execution] %+v", err)
+		if !retry() {
+			return nil, retries, err
+		}
+	}
+}
+
+// unwindOrder 反向吃单回滚已成交的数量，订单结束后未成交的部分用新的订单继续回滚，返回未能回滚的数量
+func unwindOrder(ex exchange.Exchange, unwind exchange.OrderRequest) decimal.Decimal {
+	conf := config.Conf.Execution
+	// 失败重发和部分成交后的续单共用次数
+	attempts := 0
+	backoff := func() bool {
+		attempts++
+		if attempts >= conf.UnwindRetryTimes {
+			return false
+		}
+		time.Sleep(time.Millisecond * 200 * time.Duration(attempts))
+		return true
+	}
+	for conf.UnwindRetryTimes > 0 {
+		order, _, err := placeOrder(ex, unwind, func(int, error) bool { return backoff() })
+		if err == nil {
+			// 订单结束前不能续单，否则之后的成交会超出回滚数量
+			order, err = settleOrder(ex, order)
+		}
+		if err != nil {
+			log.Log.Warningf("[execution] unwind %s %s %s %s failed err:%+v", ex.Name(), unwind.Market, unwind.Side, unwind.Quantity, err)
+			break
+		}
+		if order.FilledQuantity.GreaterThanOrEqual(unwind.Quantity) {
+			return decimal.Zero
+		}
+		unwind.Quantity = unwind.Quantity.Sub(order.FilledQuantity)
+		log.Log.Warningf("[execution] unwind %s %s %s %s filled %s, left %s", ex.Name(), unwind.Market, unwind.Side, order.State, order.FilledQuantity, unwind.Quantity)
+		if !backoff() {
+			break
+		}
+	}
+	return unwind.Quantity
+}
+
+// fillPrice 订单成交均价，响应中没有成交均价时(如部分交易所的 ack 响应)按 fallback 估算
+func fillPrice(o *exchange.Order, fallback decimal.Decimal) decimal.Decimal {
+	if o != nil && o.AvgPrice.IsPositive() {
+		return o.AvgPrice
+	}
+	return fallback
+}
@@ -0,0 +1,239 @@
+package strategy
+
+import (
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"move_profit/config"
+	"move_profit/exchange"
+	"move_profit/exchange/exchangetest"
+	"move_profit/order"
+	"move_profit/position"
+)
+
+const testMarket = "BTC_USDT"
+
+// newPair 第一腿在 gate 买入，第二腿在 binance 卖出对冲，各 1 BTC
+func newPair() (*exchangetest.Fake, *exchangetest.Fake, *leg, *leg) {
+	config.Conf = &config.Config{Execution: config.ExecutionConf{
+		LegRetryTimes:    3,
+		LegRetryTimeout:  config.Duration(5 * time.Second),
+		MaxSlippage:      decimal.RequireFromString("0.002"),
+		UnwindRetryTimes: 3,
+	}}
+	contract := exchange.Contract{Market: testMarket, QuantityStep: decimal.RequireFromString("0.001")}
+	gate := exchangetest.NewFake(exchange.Gate, decimal.NewFromInt(100), contract)
+	binance := exchangetest.NewFake(exchange.Binance, decimal.NewFromInt(101), contract)
+	positions = position.NewManager(10, decimal.NewFromInt(1000000), nil)
+	orders = order.NewManager(gate, binance)
+
+	one := decimal.NewFromInt(1)
+	first := &leg{ex: gate, contract: contract, req: exchange.OrderRequest{Market: testMarket, Side: exchange.SideBuy, Quantity: one}}
+	second := &leg{ex: binance, contract: contract, req: exchange.OrderRequest{Market: testMarket, Side: exchange.SideSell, Quantity: one}}
+	return gate, binance, first, second
+}
+
+func rejected(kind exchange.ErrorKind) error {
+	return &exchange.Error{Venue: "fake", Kind: kind, Status: 400, Msg: kind.String()}
+}
+
+func TestExecutePair(t *testing.T) {
+	lost := &net.OpError{Op: "read", Net: "tcp", Err: os.ErrDeadlineExceeded}
+	tests := []struct {
+		name          string
+		first, second []exchangetest.Result
+		result        pairResult
+		firstPos      string // 第一腿交易所最终持仓
+		secondPos     string
+		firstOrders   int // 第一腿交易所收到的下单请求数，含回滚
+		secondOrders  int
+	}{
+		{
+			name:         "done",
+			result:       pairDone,
+			firstPos:     "1",
+			secondPos:    "-1",
+			firstOrders:  1,
+			secondOrders: 1,
+		},
+		{
+			name:         "first leg rejected",
+			first:        []exchangetest.Result{{Err: rejected(exchange.KindInsufficientMargin)}},
+			result:       pairFirstFailed,
+			firstPos:     "0",
+			secondPos:    "0",
+			firstOrders:  1,
+			secondOrders: 0,
+		},
+		{
+			name:         "first leg not filled",
+			first:        []exchangetest.Result{{Partial: true}},
+			result:       pairFirstFailed,
+			firstPos:     "0",
+			secondPos:    "0",
+			firstOrders:  1,
+			secondOrders: 0,
+		},
+		{
+			name:         "first leg partially filled",
+			first:        []exchangetest.Result{{Partial: true, Filled: decimal.RequireFromString("0.4")}},
+			result:       pairDone,
+			firstPos:     "0.4",
+			secondPos:    "-0.4",
+			firstOrders:  1,
+			secondOrders: 1,
+		},
+		{
+			name:         "hedge retried after rate limit",
+			second:       []exchangetest.Result{{Err: rejected(exchange.KindRateLimited)}},
+			result:       pairDone,
+			firstPos:     "1",
+			secondPos:    "-1",
+			firstOrders:  1,
+			secondOrders: 2,
+		},
+		{
+			name:         "hedge expired then retried",
+			second:       []exchangetest.Result{{Partial: true}},
+			result:       pairDone,
+			firstPos:     "1",
+			secondPos:    "-1",
+			firstOrders:  1,
+			secondOrders: 2,
+		},
+		{
+			name:         "hedge expired every time then unwound",
+			second:       []exchangetest.Result{{Partial: true}, {Partial: true}, {Partial: true}, {Partial: true}},
+			result:       pairUnwound,
+			firstPos:     "0",
+			secondPos:    "0",
+			firstOrders:  2,
+			secondOrders: 4,
+		},
+		{
+			name:         "hedge acked then filled",
+			second:       []exchangetest.Result{{Ack: true}},
+			result:       pairDone,
+			firstPos:     "1",
+			secondPos:    "-1",
+			firstOrders:  1,
+			secondOrders: 1,
+		},
+		{
+			name:         "hedge failed then unwound",
+			second:       []exchangetest.Result{{Err: rejected(exchange.KindInsufficientMargin)}},
+			result:       pairUnwound,
+			firstPos:     "0",
+			secondPos:    "0",
+			firstOrders:  2,
+			secondOrders: 1,
+		},
+		{
+			name:         "hedge failed then unwound by two orders",
+			first:        []exchangetest.Result{{}, {Partial: true, Filled: decimal.RequireFromString("0.3")}},
+			second:       []exchangetest.Result{{Err: rejected(exchange.KindInsufficientMargin)}},
+			result:       pairUnwound,
+			firstPos:     "0",
+			secondPos:    "0",
+			firstOrders:  3,
+			secondOrders: 1,
+		},
+		{
+			name:         "unwind acked then filled",
+			first:        []exchangetest.Result{{}, {Ack: true}},
+			second:       []exchangetest.Result{{Err: rejected(exchange.KindInsufficientMargin)}},
+			result:       pairUnwound,
+			firstPos:     "0",
+			secondPos:    "0",
+			firstOrders:  2,
+			secondOrders: 1,
+		},
+		{
+			name:  "hedge and unwind failed",
+			first: []exchangetest.Result{{}, {Err: rejected(exchange.KindReduceOnlyRejected)}},
+			second: []exchangetest.Result{
+				{Err: rejected(exchange.KindInsufficientMargin)},
+			},
+			result:       pairNaked,
+			firstPos:     "1",
+			secondPos:    "0",
+			firstOrders:  2,
+			secondOrders: 1,
+		},
+		{
+			name:         "hedge response lost but filled",
+			second:       []exchangetest.Result{{Err: lost, Accepted: true}},
+			result:       pairDone,
+			firstPos:     "1",
+			secondPos:    "-1",
+			firstOrders:  1,
+			secondOrders: 1,
+		},
+		{
+			name:         "hedge response lost and not received",
+			second:       []exchangetest.Result{{Err: lost}},
+			result:       pairDone,
+			firstPos:     "1",
+			secondPos:    "-1",
+			firstOrders:  1,
+			secondOrders: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gate, binance, first, second := newPair()
+			gate.Script(tt.first...)
+			binance.Script(tt.second...)
+
+			res := executePair(first, second)
+			if res.result != tt.result {
+				t.Fatalf("result = %s, want %s, err:%+v", res.result, tt.result, res.err)
+			}
+			if got := gate.Position(testMarket); !got.Equal(decimal.RequireFromString(tt.firstPos)) {
+				t.Errorf("first leg position = %s, want %s", got, tt.firstPos)
+			}
+			if got := binance.Position(testMarket); !got.Equal(decimal.RequireFromString(tt.secondPos)) {
+				t.Errorf("second leg position = %s, want %s", got, tt.secondPos)
+			}
+			if got := len(gate.Requests()); got != tt.firstOrders {
+				t.Errorf("first leg orders = %d, want %d", got, tt.firstOrders)
+			}
+			if got := len(binance.Requests()); got != tt.secondOrders {
+				t.Errorf("second leg orders = %d, want %d", got, tt.secondOrders)
+			}
+			if res.result == pairNaked && !res.firstQuantity.Equal(gate.Position(testMarket)) {
+				t.Errorf("naked quantity = %s, want %s", res.firstQuantity, gate.Position(testMarket))
+			}
+		})
+	}
+}
+
+// 重发同一个逻辑订单时使用同一个自定义订单 id，回滚订单只减仓
+func TestExecutePairClientIds(t *testing.T) {
+	gate, binance, first, second := newPair()
+	binance.Script(exchangetest.Result{Err: syscall.ECONNRESET}, exchangetest.Result{Err: rejected(exchange.KindInsufficientMargin)})
+
+	res := executePair(first, second)
+	if res.result != pairUnwound {
+		t.Fatalf("result = %s, want %s, err:%+v", res.result, pairUnwound, res.err)
+	}
+	hedges := binance.Requests()
+	if len(hedges) != 2 || hedges[0].ClientId == "" || hedges[0].ClientId != hedges[1].ClientId {
+		t.Errorf("hedge retries must reuse the client id, got %+v", hedges)
+	}
+	reqs := gate.Requests()
+	if len(reqs) != 2 {
+		t.Fatalf("first leg orders = %d, want 2", len(reqs))
+	}
+	unwind := reqs[1]
+	if unwind.ClientId == reqs[0].ClientId {
+		t.Errorf("unwind must be a new order, client id %s reused", unwind.ClientId)
+	}
+	if unwind.Side != exchange.SideSell || !unwind.ReduceOnly || !unwind.Quantity.Equal(decimal.NewFromInt(1)) {
+		t.Errorf("unwind request = %+v", unwind)
+	}
+}
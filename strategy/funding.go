@@ -0,0 +1,114 @@
+package strategy
+
+import (
+	"move_profit/exchange"
+	"move_profit/log"
+	"move_profit/position"
+	"move_profit/quote"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// expectedFunding gate 持有 gateSide、binance 反向的对冲仓位在 horizon 内按当前资金费率预计的资金费收益率(相对名义价值)，
+// 正为收取，负为支付。任意一边没有资金费率时 ok 为 false
+func expectedFunding(market string, gateSide exchange.Side, horizon time.Duration) (decimal.Decimal, bool) {
+	now := time.Now()
+	legs := map[string]exchange.Side{
+		exchange.Gate:    gateSide,
+		exchange.Binance: gateSide.Opposite(),
+	}
+	total := decimal.Zero
+	for venue, side := range legs {
+		f, ok := quote.GetFunding(venue, market)
+		if !ok {
+			return decimal.Zero, false
+		}
+		gain := f.Rate.Mul(decimal.NewFromInt(int64(f.Settlements(now, horizon))))
+		// 资金费率为正时多仓支付、空仓收取
+		if side == exchange.SideBuy {
+			gain = gain.Neg()
+		}
+		total = total.Add(gain)
+	}
+	return total, true
+}
+
+// nextFundingTime 两边中较早的下一次结算时间
+func nextFundingTime(market string) (time.Time, bool) {
+	var next time.Time
+	for _, venue := range []string{exchange.Gate, exchange.Binance} {
+		f, ok := quote.GetFunding(venue, market)
+		if !ok || f.NextTime.IsZero() {
+			continue
+		}
+		if next.IsZero() || f.NextTime.Before(next) {
+			next = f.NextTime
+		}
+	}
+	return next, !next.IsZero()
+}
+
+// 检查资金费结算的间隔
+const fundingCheckInterval = 10 * time.Second
+
+// runFundingAccrual 每次结算时间过后按结算前最后看到的费率估算各腿收付的资金费，累加到持仓上。
+// 重启前后跨过的结算没有费率记录，不计入
+func runFundingAccrual() {
+	// venue:market -> 最近一次看到的尚未结算的资金费率
+	pending := make(map[string]quote.Funding)
+	ticker := time.NewTicker(fundingCheckInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		accrueFunding(pending, time.Now())
+	}
+}
+
+func accrueFunding(pending map[string]quote.Funding, now time.Time) {
+	held := make(map[string]bool, len(pending))
+	for _, p := range positions.List() {
+		if p.State != position.StateOpen && p.State != position.StateClosing {
+			continue
+		}
+		held[exchange.Gate+":"+p.Market], held[exchange.Binance+":"+p.Market] = true, true
+		legs := map[string]decimal.Decimal{
+			exchange.Gate:    p.GateQuantity.Abs().Mul(p.GateEntryPrice),
+			exchange.Binance: p.BinanceQuantity.Mul(p.BinanceEntryPrice),
+		}
+		gateSide := exchange.SideBuy
+		if p.GateQuantity.IsNegative() {
+			gateSide = exchange.SideSell
+		}
+		for venue, notional := range legs {
+			k := venue + ":" + p.Market
+			if last, ok := pending[k]; ok && !last.NextTime.After(now) {
+				delete(pending, k)
+				if !last.NextTime.Before(p.OpenTime) {
+					if !notional.IsPositive() {
+						notional = p.Notional
+					}
+					side := gateSide
+					if venue == exchange.Binance {
+						side = p.BinanceSide
+					}
+					// 资金费率为正时多仓支付、空仓收取
+					amount := last.Rate.Mul(notional)
+					if side == exchange.SideBuy {
+						amount = amount.Neg()
+					}
+					positions.AddFunding(p.Market, amount)
+					log.Log.Infof("[funding] market:%s %s %s rate:%s amount:%s", p.Market, venue, side, last.Rate, amount)
+				}
+			}
+			if f, ok := quote.GetFunding(venue, p.Market); ok && f.NextTime.After(now) {
+				pending[k] = f
+			}
+		}
+	}
+	// 已平仓的市场不再结算，重新开仓时从新的结算周期开始记录
+	for k := range pending {
+		if !held[k] {
+			delete(pending, k)
+		}
+	}
+}
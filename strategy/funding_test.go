@@ -0,0 +1,159 @@
+package strategy
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/op/go-logging"
+	"github.com/shopspring/decimal"
+	"move_profit/exchange"
+	"move_profit/log"
+	"move_profit/position"
+	"move_profit/quote"
+)
+
+func TestMain(m *testing.M) {
+	log.Log = logging.MustGetLogger("test")
+	logging.SetLevel(logging.CRITICAL, "")
+	os.Exit(m.Run())
+}
+
+func TestAccrueFunding(t *testing.T) {
+	const market = "FUNDING_USDT"
+	t0 := time.Date(2024, 6, 1, 7, 0, 0, 0, time.UTC)
+	settle1, settle2 := t0.Add(time.Hour), t0.Add(9*time.Hour)
+	store := func(gateRate, binanceRate string, next time.Time) {
+		quote.StoreFunding(exchange.Gate, market, quote.Funding{Rate: decimal.RequireFromString(gateRate), NextTime: next})
+		quote.StoreFunding(exchange.Binance, market, quote.Funding{Rate: decimal.RequireFromString(binanceRate), NextTime: next})
+	}
+
+	// gate 多 2 @100，binance 空 2 @101
+	positions = position.NewManager(10, decimal.NewFromInt(1000000), nil)
+	positions.Open(&position.Position{
+		Market:            market,
+		BinanceSide:       exchange.SideSell,
+		BinanceQuantity:   decimal.NewFromInt(2),
+		GateQuantity:      decimal.NewFromInt(2),
+		BinanceEntryPrice: decimal.NewFromInt(101),
+		GateEntryPrice:    decimal.NewFromInt(100),
+		Notional:          decimal.NewFromInt(200),
+		OpenTime:          t0,
+	})
+	pending := make(map[string]quote.Funding)
+
+	steps := []struct {
+		name    string
+		quote   func()
+		now     time.Time
+		funding string // 累计资金费
+	}{
+		{
+			name:    "rates recorded before settlement",
+			quote:   func() { store("0.001", "0.0005", settle1) },
+			now:     t0.Add(time.Minute),
+			funding: "0",
+		},
+		{
+			// gate 多仓支付 0.001*200，binance 空仓收取 0.0005*202
+			name:    "settled with the last seen rates",
+			quote:   func() { store("0.003", "0.003", settle1) },
+			now:     settle1.Add(time.Second),
+			funding: "-0.0990",
+		},
+		{
+			name:    "settled only once",
+			now:     settle1.Add(time.Minute),
+			funding: "-0.0990",
+		},
+		{
+			name:    "next period recorded",
+			quote:   func() { store("-0.002", "0.001", settle2) },
+			now:     settle1.Add(2 * time.Minute),
+			funding: "-0.0990",
+		},
+		{
+			// gate 多仓收取 0.002*200，binance 空仓收取 0.001*202
+			name:    "second settlement",
+			now:     settle2,
+			funding: "0.503",
+		},
+	}
+	for _, s := range steps {
+		if s.quote != nil {
+			s.quote()
+		}
+		accrueFunding(pending, s.now)
+		p, _ := positions.Get(market)
+		if !p.Funding.Equal(decimal.RequireFromString(s.funding)) {
+			t.Fatalf("%s: funding = %s, want %s", s.name, p.Funding, s.funding)
+		}
+	}
+}
+
+// 开仓前就已记录的费率在开仓后结算时不计入
+func TestAccrueFundingBeforeOpen(t *testing.T) {
+	const market = "FUNDING2_USDT"
+	settle := time.Date(2024, 6, 1, 8, 0, 0, 0, time.UTC)
+	pending := map[string]quote.Funding{
+		exchange.Gate + ":" + market:    {Rate: decimal.RequireFromString("0.01"), NextTime: settle},
+		exchange.Binance + ":" + market: {Rate: decimal.RequireFromString("0.01"), NextTime: settle},
+	}
+	positions = position.NewManager(10, decimal.NewFromInt(1000000), nil)
+	positions.Open(&position.Position{
+		Market:          market,
+		BinanceSide:     exchange.SideBuy,
+		BinanceQuantity: decimal.NewFromInt(1),
+		GateQuantity:    decimal.NewFromInt(-1),
+		Notional:        decimal.NewFromInt(100),
+		OpenTime:        settle.Add(time.Second),
+	})
+
+	accrueFunding(pending, settle.Add(time.Minute))
+	if p, _ := positions.Get(market); !p.Funding.IsZero() {
+		t.Errorf("funding = %s, want 0", p.Funding)
+	}
+	if len(pending) != 0 {
+		t.Errorf("pending = %+v, want settled entries removed", pending)
+	}
+}
+
+// 平仓后删除该市场记录的费率，重新开仓后重新记录
+func TestAccrueFundingClosed(t *testing.T) {
+	const market = "FUNDING3_USDT"
+	t0 := time.Date(2024, 6, 1, 7, 0, 0, 0, time.UTC)
+	settle := t0.Add(time.Hour)
+	open := func(openTime time.Time) {
+		positions.Open(&position.Position{
+			Market:          market,
+			BinanceSide:     exchange.SideBuy,
+			BinanceQuantity: decimal.NewFromInt(1),
+			GateQuantity:    decimal.NewFromInt(-1),
+			Notional:        decimal.NewFromInt(100),
+			OpenTime:        openTime,
+		})
+	}
+	positions = position.NewManager(10, decimal.NewFromInt(1000000), nil)
+	open(t0)
+	quote.StoreFunding(exchange.Gate, market, quote.Funding{Rate: decimal.RequireFromString("0.01"), NextTime: settle})
+	quote.StoreFunding(exchange.Binance, market, quote.Funding{Rate: decimal.RequireFromString("0.004"), NextTime: settle})
+	pending := make(map[string]quote.Funding)
+	accrueFunding(pending, t0.Add(time.Minute))
+	if len(pending) != 2 {
+		t.Fatalf("pending = %+v, want both legs", pending)
+	}
+
+	positions.Close(market)
+	accrueFunding(pending, t0.Add(2*time.Minute))
+	if len(pending) != 0 {
+		t.Fatalf("pending = %+v, want closed market removed", pending)
+	}
+
+	// gate 空仓收取 0.01*100，binance 多仓支付 0.004*100
+	open(t0.Add(3 * time.Minute))
+	accrueFunding(pending, t0.Add(4*time.Minute))
+	accrueFunding(pending, settle.Add(time.Second))
+	if p, _ := positions.Get(market); !p.Funding.Equal(decimal.RequireFromString("0.6")) {
+		t.Errorf("funding = %s, want 0.6", p.Funding)
+	}
+}
@@ -0,0 +1,77 @@
+package strategy
+
+import (
+	"move_profit/config"
+	"move_profit/exchange"
+	"move_profit/ledger"
+	"move_profit/log"
+	"move_profit/position"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// tradeFee 按成交金额和账户费率估算的手续费，maker 为 true 时按 maker 费率
+func tradeFee(venue, market string, quantity, price decimal.Decimal, maker bool) decimal.Decimal {
+	rate, err := fees.Get(venue, market)
+	if err != nil {
+		log.Log.Warningf("market:%s %s fee rate err:%+v, fee not recorded", market, venue, err)
+		return decimal.Zero
+	}
+	if maker {
+		return quantity.Abs().Mul(price).Mul(rate.Maker)
+	}
+	return quantity.Abs().Mul(price).Mul(rate.Taker)
+}
+
+// recordRoundTrip 平仓成交后按本次平掉的数量记一条开平仓记录，开仓手续费和资金费按 gate 腿平掉的比例分摊，
+// 返回分摊到本次的部分，供剩余仓位扣减
+func recordRoundTrip(pos position.Position, res *pairExecution, exit spread) (entryFee, funding decimal.Decimal) {
+	gateSide := exit.GateSide
+	gateQuantity, binanceQuantity := res.firstQuantity, res.secondQuantity
+	ratio := decimal.NewFromInt(1)
+	if gateQuantity.LessThan(pos.GateQuantity.Abs()) {
+		ratio = gateQuantity.Div(pos.GateQuantity.Abs())
+	}
+	entryFee, funding = pos.EntryFee.Mul(ratio), pos.Funding.Mul(ratio)
+
+	// 多仓盈亏为 (平仓价 - 开仓价) × 数量，空仓相反
+	gateGross := res.firstPrice.Sub(pos.GateEntryPrice).Mul(gateQuantity)
+	binanceGross := res.secondPrice.Sub(pos.BinanceEntryPrice).Mul(binanceQuantity)
+	if gateSide == exchange.SideSell {
+		gateGross = gateGross.Neg()
+	} else {
+		binanceGross = binanceGross.Neg()
+	}
+	gross := gateGross.Add(binanceGross)
+	fee := entryFee.
+		Add(tradeFee(exchange.Gate, pos.Market, gateQuantity, res.firstPrice, false)).
+		Add(tradeFee(exchange.Binance, pos.Market, binanceQuantity, res.secondPrice, false))
+
+	r := ledger.Record{
+		Market:    pos.Market,
+		GateSide:  gateSide,
+		Paper:     config.Conf.Paper.Enabled,
+		OpenTime:  pos.OpenTime,
+		CloseTime: time.Now(),
+		Entry: []ledger.Fill{
+			{Venue: exchange.Gate, Side: gateSide, Quantity: gateQuantity, Price: pos.GateEntryPrice},
+			{Venue: exchange.Binance, Side: pos.BinanceSide, Quantity: binanceQuantity, Price: pos.BinanceEntryPrice},
+		},
+		Exit: []ledger.Fill{
+			{Venue: exchange.Gate, Side: gateSide.Opposite(), Quantity: gateQuantity, Price: res.firstPrice},
+			{Venue: exchange.Binance, Side: pos.BinanceSide.Opposite(), Quantity: binanceQuantity, Price: res.secondPrice},
+		},
+		EntryRate: pos.DiffRate,
+		ExitRate:  exit.Rate,
+		Gross:     gross,
+		Fees:      fee,
+		Funding:   funding,
+		Net:       gross.Sub(fee).Add(funding),
+	}
+	log.Log.Infof("[close] market:%s gross:%s fees:%s funding:%s net:%s", r.Market, r.Gross, r.Fees, r.Funding, r.Net)
+	if err := journal.Append(r); err != nil {
+		log.Log.Errorf("[ledger] market:%s append record err:%+v record:%+v", r.Market, err, r)
+	}
+	return entryFee, funding
+}
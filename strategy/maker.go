@@ -0,0 +1,450 @@
+package strategy
+
+import (
+	"errors"
+	"fmt"
+	"move_profit/alert"
+	"move_profit/config"
+	"move_profit/exchange"
+	"move_profit/log"
+	"move_profit/position"
+	"move_profit/quote"
+	"move_profit/sizing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// 挂单期间检查价差和盘口的间隔
+	makerCheckInterval = 200 * time.Millisecond
+	// 撤单失败时等待私有频道推送订单最终状态的时间
+	makerCancelWait = 2 * time.Second
+)
+
+// makerSpread makerVenue 挂单在己方最优价(买单挂买一、卖单挂卖一)、另一边吃对手价时的开仓价差
+func makerSpread(makerVenue string, gateSide exchange.Side, gateBook, binanceBook quote.BookTicker) spread {
+	gatePrice, binancePrice := gateBook.Price(gateSide), binanceBook.Price(gateSide.Opposite())
+	if makerVenue == exchange.Gate {
+		gatePrice = gateBook.Price(gateSide.Opposite())
+	} else {
+		binancePrice = binanceBook.Price(gateSide)
+	}
+	return newSpread(gateSide, gatePrice, binancePrice)
+}
+
+// queueSize 挂单方向上己方最优价的排队数量
+func queueSize(b quote.BookTicker, side exchange.Side) decimal.Decimal {
+	if side == exchange.SideBuy {
+		return b.BidSize
+	}
+	return b.AskSize
+}
+
+// makerVenue 挂单的交易所：maker 费率低的一边，费率相同时选己方最优价排队数量少的一边
+func makerVenue(market string, gateSide exchange.Side, gateBook, binanceBook quote.BookTicker) (string, error) {
+	gateFee, err := fees.Get(exchange.Gate, market)
+	if err != nil {
+		return "", err
+	}
+	binanceFee, err := fees.Get(exchange.Binance, market)
+	if err != nil {
+		return "", err
+	}
+	switch gateFee.Maker.Cmp(binanceFee.Maker) {
+	case -1:
+		return exchange.Gate, nil
+	case 1:
+		return exchange.Binance, nil
+	}
+	if queueSize(gateBook, gateSide).LessThanOrEqual(queueSize(binanceBook, gateSide.Opposite())) {
+		return exchange.Gate, nil
+	}
+	return exchange.Binance, nil
+}
+
+// checkMakerOpen maker 模式的开仓判断，价差按挂单价计算，手续费按一边 maker 开仓计算
+func checkMakerOpen(market string, gateBook, binanceBook quote.BookTicker) {
+	conf := config.Conf.Strategy
+
+	// 毛价差都不够时不必查询费率
+	gross := decimal.Zero
+	for _, gateSide := range []exchange.Side{exchange.SideBuy, exchange.SideSell} {
+		for _, venue := range []string{exchange.Gate, exchange.Binance} {
+			gross = decimal.Max(gross, makerSpread(venue, gateSide, gateBook, binanceBook).Rate)
+		}
+	}
+	if gross.LessThan(conf.MinEntryEdge) {
+		return
+	}
+
+	var (
+		best      spread
+		bestVenue string
+	)
+	for _, gateSide := range []exchange.Side{exchange.SideBuy, exchange.SideSell} {
+		venue, err := makerVenue(market, gateSide, gateBook, binanceBook)
+		if err != nil {
+			log.Log.Errorf("market:%s err:%+v", market, err)
+			return
+		}
+		s := makerSpread(venue, gateSide, gateBook, binanceBook)
+		if bestVenue == "" || s.Rate.GreaterThan(best.Rate) {
+			best, bestVenue = s, venue
+		}
+	}
+	cost, err := fees.MakerTakerRoundTrip(market, bestVenue)
+	if err != nil {
+		log.Log.Errorf("market:%s err:%+v", market, err)
+		return
+	}
+	funding, ok := expectedFunding(market, best.GateSide, conf.ExpectedHolding.Duration())
+	if !ok {
+		log.Log.Debugf("skip market:%s no funding rate", market)
+		return
+	}
+	cost = cost.Sub(funding)
+	if best.Rate.Sub(cost).LessThan(conf.MinEntryEdge) {
+		return
+	}
+	openMakerPosition(market, bestVenue, best, cost)
+}
+
+// openMakerPosition 占用额度后在后台挂单，挂单期间该市场为 opening 状态，不会重复开仓
+func openMakerPosition(market, venue string, s spread, cost decimal.Decimal) {
+	conf := config.Conf.Strategy
+
+	gateContract, ok := gateEx.GetContract(market)
+	if !ok {
+		return
+	}
+	binanceContract, ok := binanceEx.GetContract(market)
+	if !ok {
+		return
+	}
+	// 两边都能精确下单的数量，对冲腿按 maker 腿实际成交数量下单
+	rule, err := sizing.Match(gateContract, binanceContract)
+	if err != nil {
+		log.Log.Debugf("skip market:%s err:%+v", market, err)
+		return
+	}
+	quantity, err := rule.Quantity(conf.Notional, s.BinancePrice)
+	if err != nil {
+		log.Log.Debugf("skip market:%s err:%+v", market, err)
+		return
+	}
+
+	gate := &leg{ex: gateEx, contract: gateContract, req: exchange.OrderRequest{Market: market, Side: s.GateSide, Quantity: quantity}, quote: quote.QuoteFunc(exchange.Gate), quotePrice: s.GatePrice}
+	binance := &leg{ex: binanceEx, contract: binanceContract, req: exchange.OrderRequest{Market: market, Side: s.GateSide.Opposite(), Quantity: quantity}, quote: quote.QuoteFunc(exchange.Binance), quotePrice: s.BinancePrice}
+	m := &makerExecution{market: market, venue: venue, gateSide: s.GateSide, signal: s, rate: s.Rate, cost: cost, maker: gate, taker: binance}
+	if venue == exchange.Binance {
+		m.maker, m.taker = binance, gate
+	}
+
+	// 对冲腿吃单的滑点按本次数量在深度上的成交均价估算
+	book, ok := quote.GetOrderBook(m.taker.ex.Name(), market)
+	if !ok || time.Since(book.UpdateTime) > conf.MaxQuoteAge.Duration() {
+		log.Log.Debugf("skip market:%s no fresh %s depth", market, m.taker.ex.Name())
+		return
+	}
+	takerPrice, ok := book.ImpactPrice(m.taker.req.Side, quantity)
+	if !ok {
+		log.Log.Debugf("skip market:%s %s depth not enough for %s", market, m.taker.ex.Name(), quantity)
+		return
+	}
+	impact := newSpread(s.GateSide, takerPrice, s.BinancePrice)
+	if venue == exchange.Gate {
+		impact = newSpread(s.GateSide, s.GatePrice, takerPrice)
+	}
+	if impact.Rate.Sub(cost).LessThan(conf.MinEntryEdge) {
+		log.Log.Infof("skip market:%s maker spread:%s impact spread:%s cost:%s below threshold", market, s.Rate, impact.Rate, cost)
+		return
+	}
+	m.rate = impact.Rate
+
+	m.notional = quantity.Mul(s.BinancePrice)
+	if err := positions.Reserve(&position.Position{Market: market, DiffRate: m.rate, Notional: m.notional}); err != nil {
+		log.Log.Debugf("skip market:%s err:%+v", market, err)
+		return
+	}
+	log.Log.Infof("[maker] %s 挂单:%s 成本:%s", spreadMsg(market, impact), venue, cost)
+
+	if err := prepareMarket(market); err != nil {
+		log.Log.Errorf("[maker] market:%s err:%+v", market, err)
+		positions.Release(market)
+		return
+	}
+
+	// 挂单可能持续到 maker_timeout，不能阻塞该市场的平仓检查
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				alert.Send("[maker] market:%s panic:%+v", market, r)
+			}
+		}()
+		m.run()
+		m.finish()
+	}()
+}
+
+// makerExecution maker 腿挂 post only 单，每次收到成交推送后立即在 taker 腿吃单对冲新成交的数量；
+// 价差消失时撤单，己方最优价变化时撤单后按新价格重挂，超过 maker_timeout 后撤单结束
+type makerExecution struct {
+	market   string
+	venue    string // maker 腿所在交易所
+	gateSide exchange.Side
+	signal   spread          // 按挂单价计算的开仓信号价差
+	rate     decimal.Decimal // 按对冲腿深度估算的开仓价差
+	cost     decimal.Decimal
+	notional decimal.Decimal
+	maker    *leg
+	taker    *leg
+
+	filled      decimal.Decimal // maker 腿累计成交数量
+	hedged      decimal.Decimal // taker 腿累计对冲数量
+	filledValue decimal.Decimal // maker 腿累计成交金额
+	hedgedValue decimal.Decimal // taker 腿累计成交金额
+	retries     int             // 对冲单累计重试次数
+	err         error           // 对冲或挂单失败的原因，出现后不再挂单
+}
+
+func (m *makerExecution) run() {
+	deadline := time.Now().Add(config.Conf.Execution.MakerTimeout.Duration())
+	for m.err == nil && time.Now().Before(deadline) {
+		left := m.maker.contract.RoundQuantity(m.maker.req.Quantity.Sub(m.filled))
+		if !left.IsPositive() {
+			return
+		}
+		price, ok := m.price()
+		if !ok {
+			log.Log.Infof("[maker] market:%s spread gone, stop", m.market)
+			return
+		}
+		m.rest(price, left, deadline)
+	}
+}
+
+// price 当前应挂的价格(己方最优价)，按该价格计算的价差扣除成本后低于开仓阈值或盘口过期时 ok 为 false
+func (m *makerExecution) price() (decimal.Decimal, bool) {
+	conf := config.Conf.Strategy
+	gateBook, ok := quote.GetBookTicker(exchange.Gate, m.market)
+	if !ok || time.Since(gateBook.UpdateTime) > conf.MaxQuoteAge.Duration() {
+		return decimal.Zero, false
+	}
+	binanceBook, ok := quote.GetBookTicker(exchange.Binance, m.market)
+	if !ok || time.Since(binanceBook.UpdateTime) > conf.MaxQuoteAge.Duration() {
+		return decimal.Zero, false
+	}
+	s := makerSpread(m.venue, m.gateSide, gateBook, binanceBook)
+	if s.Rate.Sub(m.cost).LessThan(conf.MinEntryEdge) {
+		return decimal.Zero, false
+	}
+	price := s.BinancePrice
+	if m.venue == exchange.Gate {
+		price = s.GatePrice
+	}
+	return m.maker.contract.RoundPrice(price, m.maker.req.Side), price.IsPositive()
+}
+
+// rest 挂一笔 post only 单，直到订单结束、撤单或超时后返回
+func (m *makerExecution) rest(price, quantity decimal.Decimal, deadline time.Time) {
+	venue := m.maker.ex.Name()
+	id := positions.BeginOrder(m.market, venue, m.maker.req.Side, quantity)
+	defer positions.EndOrder(id)
+	// 下单前订阅，避免成交推送早于下单响应
+	updates, stop := orders.Watch(venue, id)
+	defer stop()
+
+	req := exchange.OrderRequest{
+		Market:      m.market,
+		Side:        m.maker.req.Side,
+		Quantity:    quantity,
+		Type:        exchange.OrderLimit,
+		Price:       price,
+		TimeInForce: exchange.GTX,
+		ClientId:    id,
+	}
+	order, _, err := sendOrder(m.maker.ex, req, nil)
+	if err != nil {
+		switch {
+		case errors.Is(err, exchange.ErrPostOnlyRejected):
+			// 盘口已经变化，下一轮按新价格重挂
+			log.Log.Debugf("[maker] market:%s %s price %s rejected", m.market, venue, price)
+		case errors.Is(err, errUnresolved):
+			// 挂单可能仍在交易所，之后的成交不会被对冲
+			m.err = err
+			return
+		case exchange.Retryable(err), exchange.OutcomeUnknown(err):
+			// 确认交易所没有收到，下一轮用新的订单重挂
+			log.Log.Warningf("[maker] market:%s %s place order err:%+v", m.market, venue, err)
+		default:
+			m.err = err
+			return
+		}
+		time.Sleep(makerCheckInterval)
+		return
+	}
+	log.Log.Infof("[maker] market:%s %s order:%s %s %s@%s", m.market, venue, order.Id, req.Side, quantity, price)
+
+	orderFilled := decimal.Zero
+	ticker := time.NewTicker(makerCheckInterval)
+	defer ticker.Stop()
+	for {
+		o, _ := orders.Get(venue, id)
+		m.hedge(o.FilledQuantity, price, &orderFilled)
+		if o.State.Final() {
+			return
+		}
+		if m.err != nil {
+			m.cancel(id, price, &orderFilled)
+			return
+		}
+
+		select {
+		case <-updates:
+		case <-ticker.C:
+			reason := ""
+			if time.Now().After(deadline) {
+				reason = "timeout"
+			} else if p, ok := m.price(); !ok {
+				reason = "spread gone"
+			} else if !p.Equal(price) {
+				reason = fmt.Sprintf("reprice %s", p)
+			}
+			if reason == "" {
+				continue
+			}
+			log.Log.Infof("[maker] market:%s cancel %s order:%s %s", m.market, venue, order.Id, reason)
+			m.cancel(id, price, &orderFilled)
+			return
+		}
+	}
+}
+
+// cancel 撤单并对冲撤单前已成交的部分，撤单失败时等待推送或查询得到最终状态
+func (m *makerExecution) cancel(clientId string, price decimal.Decimal, orderFilled *decimal.Decimal) {
+	venue := m.maker.ex.Name()
+	o, err := orders.Cancel(venue, clientId)
+	if err != nil {
+		log.Log.Warningf("[maker] market:%s cancel %s order:%s err:%+v", m.market, venue, o.Id, err)
+		var final bool
+		if o, final = orders.Wait(venue, clientId, makerCancelWait); !final {
+			// 订单可能仍在挂单，之后的成交不会被对冲
+			if m.err == nil {
+				m.err = fmt.Errorf("%s order %s state unknown after cancel err:%+v", venue, o.Id, err)
+			}
+			alert.Send("[maker] market:%s cancel %s order:%s failed, check it manually err:%+v", m.market, venue, o.Id, err)
+		}
+	}
+	m.hedge(o.FilledQuantity, price, orderFilled)
+}
+
+// hedge total 为当前挂单的累计成交数量，price 为挂单价(post only 单按挂单价成交)，新成交的部分在 taker 腿吃单对冲；
+// 不足 taker 腿一个下单单位的部分留到下次成交一起对冲
+func (m *makerExecution) hedge(total, price decimal.Decimal, orderFilled *decimal.Decimal) {
+	delta := total.Sub(*orderFilled)
+	if !delta.IsPositive() {
+		return
+	}
+	*orderFilled = total
+	m.filled = m.filled.Add(delta)
+	m.filledValue = m.filledValue.Add(delta.Mul(price))
+	// 对冲已经失败，剩余部分由 finish 回滚
+	if m.err != nil {
+		return
+	}
+	quantity := m.taker.contract.RoundQuantity(m.filled.Sub(m.hedged))
+	if !quantity.IsPositive() {
+		return
+	}
+	req := m.taker.req
+	req.Quantity = quantity
+	order, retries, err := retryOrder(m.taker, req)
+	m.retries += retries
+	if err != nil {
+		m.err = err
+		return
+	}
+	quantity = order.FilledQuantity
+	m.hedged = m.hedged.Add(quantity)
+	m.hedgedValue = m.hedgedValue.Add(quantity.Mul(fillPrice(order, m.taker.quotePrice)))
+	log.Log.Infof("[maker] market:%s %s filled:%s %s hedged:%s", m.market, m.maker.ex.Name(), m.filled, m.taker.ex.Name(), m.hedged)
+	// 部分对冲也要落盘，挂单期间崩溃重启后按已对冲数量对账
+	positions.Update(m.position(position.StateOpening, m.hedged))
+}
+
+// position makerQuantity 为 maker 腿持有的数量，taker 腿持有已对冲的数量；
+// 挂单期间仍占用全部额度，结束后名义价值按实际数量计算
+func (m *makerExecution) position(state position.State, makerQuantity decimal.Decimal) position.Position {
+	notional := m.notional
+	if state != position.StateOpening {
+		notional = notional.Mul(makerQuantity).Div(m.maker.req.Quantity)
+	}
+	gateQuantity, binanceQuantity := makerQuantity, m.hedged
+	if m.venue == exchange.Binance {
+		gateQuantity, binanceQuantity = m.hedged, makerQuantity
+	}
+	if m.gateSide == exchange.SideSell {
+		gateQuantity = gateQuantity.Neg()
+	}
+	p := position.Position{
+		Market:          m.market,
+		State:           state,
+		BinanceSide:     m.gateSide.Opposite(),
+		BinanceQuantity: binanceQuantity,
+		GateQuantity:    gateQuantity,
+		DiffRate:        m.rate,
+		SignalRate:      m.signal.Rate,
+		Notional:        notional,
+		MakerVenue:      m.venue,
+	}
+	if executed, ok := m.executed(); ok {
+		p.DiffRate, p.GateEntryPrice, p.BinanceEntryPrice = executed.Rate, executed.GatePrice, executed.BinancePrice
+		p.EntryFee = tradeFee(exchange.Gate, m.market, gateQuantity, executed.GatePrice, m.venue == exchange.Gate).
+			Add(tradeFee(exchange.Binance, m.market, binanceQuantity, executed.BinancePrice, m.venue == exchange.Binance))
+	}
+	return p
+}
+
+// executed 两腿成交均价计算的开仓价差，还没有对冲成交时 ok 为 false
+func (m *makerExecution) executed() (spread, bool) {
+	if !m.filled.IsPositive() || !m.hedged.IsPositive() {
+		return spread{}, false
+	}
+	makerPrice, takerPrice := m.filledValue.Div(m.filled), m.hedgedValue.Div(m.hedged)
+	if m.venue == exchange.Gate {
+		return newSpread(m.gateSide, makerPrice, takerPrice), true
+	}
+	return newSpread(m.gateSide, takerPrice, makerPrice), true
+}
+
+// finish 回滚 maker 腿未能对冲的成交，按最终两腿数量记录仓位
+func (m *makerExecution) finish() {
+	left := decimal.Zero
+	if unhedged := m.filled.Sub(m.hedged); unhedged.IsPositive() {
+		left = unwindOrder(m.maker.ex, exchange.OrderRequest{Market: m.market, Side: m.maker.req.Side.Opposite(), Quantity: unhedged, ReduceOnly: true})
+		if left.IsZero() {
+			log.Log.Warningf("[maker] market:%s unhedged %s %s unwound err:%+v", m.market, m.maker.ex.Name(), unhedged, m.err)
+		}
+	}
+
+	switch {
+	case left.IsPositive():
+		alert.Send("[maker] market:%s %s unhedged %s unwind failed, naked err:%+v", m.market, m.maker.ex.Name(), left, m.err)
+		positions.Update(m.position(position.StateMismatch, m.hedged.Add(left)))
+	case m.hedged.IsPositive():
+		if m.err != nil || m.retries > 0 {
+			alert.Send("[maker] market:%s opened %s with %d hedge retries err:%+v", m.market, m.hedged, m.retries, m.err)
+		}
+		p := m.position(position.StateOpen, m.hedged)
+		if executed, ok := m.executed(); ok {
+			reportSlippage("maker open", m.market, m.signal, executed, m.signal.Rate.Sub(executed.Rate))
+		}
+		positions.Open(&p)
+	default:
+		if m.err != nil {
+			alert.Send("[maker] market:%s open failed err:%+v", m.market, m.err)
+		}
+		positions.Release(m.market)
+	}
+}
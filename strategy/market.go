@@ -0,0 +1,45 @@
+package strategy
+
+import (
+	"move_profit/alert"
+	"move_profit/exchange"
+	"move_profit/log"
+	"sync"
+)
+
+// untradable 不可交易(下架中、非 TRADING 或已从合约列表移除)的 venue:market，任意一边不可交易时禁止开仓
+var untradable sync.Map
+
+// OnMarketChange 合约元数据变化时更新可交易状态；已有仓位的市场变为不可交易时告警，需要人工决定是否平仓
+func OnMarketChange(changes []exchange.MarketChange) {
+	for _, c := range changes {
+		k := c.Venue + ":" + c.Market
+		if c.Tradable {
+			untradable.Delete(k)
+		} else {
+			untradable.Store(k, true)
+		}
+		if c.Kind == exchange.MarketListed {
+			continue
+		}
+		p, ok := positions.Get(c.Market)
+		if !ok {
+			continue
+		}
+		if !c.Tradable {
+			alert.Send("market:%s %s %s not tradable, position state:%s binance:%s gate:%s", c.Market, c.Venue, c.Kind, p.State, p.BinanceQuantity, p.GateQuantity)
+		} else if c.Kind == exchange.MarketRulesChanged {
+			log.Log.Warningf("market:%s %s rules changed with position binance:%s gate:%s", c.Market, c.Venue, p.BinanceQuantity, p.GateQuantity)
+		}
+	}
+}
+
+// tradable 两边都可以开仓
+func tradable(market string) bool {
+	for _, venue := range []string{exchange.Binance, exchange.Gate} {
+		if _, ok := untradable.Load(venue + ":" + market); ok {
+			return false
+		}
+	}
+	return true
+}
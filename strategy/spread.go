@@ -0,0 +1,84 @@
+package strategy
+
+import (
+	"move_profit/alert"
+	"move_profit/config"
+	"move_profit/exchange"
+	"move_profit/log"
+	"move_profit/quote"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// spread 按盘口可成交价计算的一组对冲价差，gateSide 为开仓时 gate 腿的方向
+type spread struct {
+	GateSide     exchange.Side
+	GatePrice    decimal.Decimal
+	BinancePrice decimal.Decimal
+	Rate         decimal.Decimal // (卖出腿价格 - 买入腿价格) / 买入腿价格
+}
+
+func newSpread(gateSide exchange.Side, gatePrice, binancePrice decimal.Decimal) spread {
+	buy, sell := gatePrice, binancePrice
+	if gateSide == exchange.SideSell {
+		buy, sell = binancePrice, gatePrice
+	}
+	return spread{
+		GateSide:     gateSide,
+		GatePrice:    gatePrice,
+		BinancePrice: binancePrice,
+		Rate:         sell.Sub(buy).Div(buy),
+	}
+}
+
+// openSpread gate 按 gateSide 开仓、binance 反向开仓时的可成交价差
+func openSpread(gateSide exchange.Side, gateBook, binanceBook quote.BookTicker) spread {
+	return newSpread(gateSide, gateBook.Price(gateSide), binanceBook.Price(gateSide.Opposite()))
+}
+
+// closeSpread 持有 gateSide 方向仓位时按平仓可成交价计算的同方向价差，开仓价差减去平仓价差即为收益率
+func closeSpread(gateSide exchange.Side, gateBook, binanceBook quote.BookTicker) spread {
+	return newSpread(gateSide, gateBook.Price(gateSide.Opposite()), binanceBook.Price(gateSide))
+}
+
+// bestOpenSpread 两个方向中价差更大的一个
+func bestOpenSpread(gateBook, binanceBook quote.BookTicker) spread {
+	buyGate := openSpread(exchange.SideBuy, gateBook, binanceBook)
+	sellGate := openSpread(exchange.SideSell, gateBook, binanceBook)
+	if sellGate.Rate.GreaterThan(buyGate.Rate) {
+		return sellGate
+	}
+	return buyGate
+}
+
+// impactSpread 按两腿下单数量在深度上的成交均价计算开仓价差，深度缺失、过期或不足时 ok 为 false
+func impactSpread(market string, gateSide exchange.Side, gateQuantity, binanceQuantity decimal.Decimal) (spread, bool) {
+	maxAge := config.Conf.Strategy.MaxQuoteAge.Duration()
+	gateBook, ok := quote.GetOrderBook(exchange.Gate, market)
+	if !ok || time.Since(gateBook.UpdateTime) > maxAge {
+		return spread{}, false
+	}
+	binanceBook, ok := quote.GetOrderBook(exchange.Binance, market)
+	if !ok || time.Since(binanceBook.UpdateTime) > maxAge {
+		return spread{}, false
+	}
+	gatePrice, ok := gateBook.ImpactPrice(gateSide, gateQuantity)
+	if !ok {
+		return spread{}, false
+	}
+	binancePrice, ok := binanceBook.ImpactPrice(gateSide.Opposite(), binanceQuantity)
+	if !ok {
+		return spread{}, false
+	}
+	return newSpread(gateSide, gatePrice, binancePrice), true
+}
+
+// reportSlippage 记录信号价差与两腿实际成交价差的差距，worse 为实际成交比信号不利的比例，超过 max_slippage 时告警
+func reportSlippage(action, market string, signal, executed spread, worse decimal.Decimal) {
+	log.Log.Infof("[%s] market:%s signal spread:%s executed spread:%s gate:%s/%s binance:%s/%s slippage:%s",
+		action, market, signal.Rate, executed.Rate, signal.GatePrice, executed.GatePrice, signal.BinancePrice, executed.BinancePrice, worse)
+	if worse.GreaterThan(config.Conf.Execution.MaxSlippage) {
+		alert.Send("[%s] market:%s executed spread %s is %s worse than signal spread %s", action, market, executed.Rate, worse, signal.Rate)
+	}
+}
@@ -0,0 +1,408 @@
+package strategy
+
+import (
+	"fmt"
+	"github.com/shopspring/decimal"
+	"move_profit/alert"
+	"move_profit/config"
+	"move_profit/exchange"
+	"move_profit/fee"
+	"move_profit/ledger"
+	"move_profit/log"
+	"move_profit/order"
+	"move_profit/position"
+	"move_profit/quote"
+	"move_profit/sizing"
+	"move_profit/utils"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	binanceEx exchange.Exchange
+	gateEx    exchange.Exchange
+	positions *position.Manager
+	fees      *fee.Model
+	orders    *order.Manager
+	journal   *ledger.Ledger
+)
+
+// 未结束的订单超过该时长没有推送时用 REST 查询一次
+const orderRefreshInterval = 5 * time.Second
+
+var count2Taker atomic.Int64
+
+// Init 注入两个交易所的实现，测试时可替换为 fake；store 为空时仓位只保存在内存，journal 为空时不记录开平仓
+func Init(binance, gate exchange.Exchange, store position.Store, records *ledger.Ledger) {
+	conf := config.Conf.Strategy
+	binanceEx = binance
+	gateEx = gate
+	positions = position.NewManager(conf.MaxPositions, conf.MaxTotalNotional, store)
+	fees = fee.NewModel(conf.FeeRefreshInterval.Duration(), binance, gate)
+	go fees.Run()
+	orders = order.NewManager(binance, gate)
+	go orders.Run(orderRefreshInterval)
+	journal = records
+	go runFundingAccrual()
+}
+
+// Recover 加载上次退出时的仓位并与交易所实际持仓对账，必须在行情开始推送前完成
+func Recover() error {
+	if err := positions.Restore(); err != nil {
+		return err
+	}
+	if err := positions.Reconcile(binanceEx, gateEx); err != nil {
+		return err
+	}
+	for _, p := range positions.List() {
+		log.Log.Warningf("[recover] market:%s state:%s binance:%s %s gate:%s diffRate:%s",
+			p.Market, p.State, p.BinanceSide, p.BinanceQuantity, p.GateQuantity, p.DiffRate)
+	}
+	// 上次退出时未结束的挂单(如 maker 单)已经没有人跟踪，全部撤销
+	for _, ex := range []exchange.Exchange{binanceEx, gateEx} {
+		list, err := ex.OpenOrders("")
+		if err != nil {
+			return fmt.Errorf("list %s open orders err:%+v", ex.Name(), err)
+		}
+		for _, o := range list {
+			if !strings.HasPrefix(o.ClientId, position.OrderIdPrefix) {
+				continue
+			}
+			if _, err := ex.CancelOrder(o.Market, o.Id); err != nil {
+				log.Log.Errorf("[recover] cancel %s %s order:%s err:%+v", ex.Name(), o.Market, o.Id, err)
+				continue
+			}
+			log.Log.Warningf("[recover] cancel %s %s order:%s %s %s filled:%s", ex.Name(), o.Market, o.Id, o.Side, o.Quantity, o.FilledQuantity)
+		}
+	}
+	return nil
+}
+
+// placeOrder 下单前后记录在途订单，崩溃重启后据此对账；在途订单 id 同时作为交易所的自定义订单 id，
+// 订单由 orders 跟踪状态。retry 见 sendOrder
+func placeOrder(ex exchange.Exchange, req exchange.OrderRequest, retry func(i int, err error) bool) (*exchange.Order, int, error) {
+	id := positions.BeginOrder(req.Market, ex.Name(), req.Side, req.Quantity)
+	defer positions.EndOrder(id)
+	req.ClientId = id
+	return sendOrder(ex, req, retry)
+}
+
+// ticks 每个市场的行情检查状态，market -> *tickState
+var ticks sync.Map
+
+// tickState running 表示该市场有 goroutine 在检查，pending 表示检查期间又收到了新的盘口
+type tickState struct {
+	running atomic.Bool
+	pending atomic.Bool
+}
+
+// OnTick 每次盘口更新时在行情推送的 goroutine 中调用，只做标记后立即返回；
+// 下单会阻塞在 REST 请求上，检查和下单放到每个市场一个的后台 goroutine 中执行，
+// 检查期间到达的多次盘口合并为一次，按最新盘口再检查
+func OnTick(market string) {
+	v, ok := ticks.Load(market)
+	if !ok {
+		v, _ = ticks.LoadOrStore(market, &tickState{})
+	}
+	t := v.(*tickState)
+	t.pending.Store(true)
+	if t.running.CompareAndSwap(false, true) {
+		go t.run(market)
+	}
+}
+
+func (t *tickState) run(market string) {
+	for {
+		for t.pending.Swap(false) {
+			checkMarket(market)
+		}
+		t.running.Store(false)
+		// 退出前刚到达的盘口可能没有抢到 running，这里再检查一次
+		if !t.pending.Load() || !t.running.CompareAndSwap(false, true) {
+			return
+		}
+	}
+}
+
+// checkMarket 已有仓位的市场检查平仓，否则检查开仓
+func checkMarket(market string) {
+	defer func() {
+		if r := recover(); r != nil {
+			alert.Send("strategy market:%s panic:%+v", market, r)
+		}
+	}()
+
+	conf := config.Conf.Strategy
+	if utils.InArrayString(market, conf.ExcludeMarkets) {
+		return
+	}
+	binanceBook, ok := quote.GetBookTicker(exchange.Binance, market)
+	if !ok {
+		return
+	}
+	gateBook, ok := quote.GetBookTicker(exchange.Gate, market)
+	if !ok {
+		return
+	}
+	// 盘口太久没更新，价格不可信
+	maxAge := conf.MaxQuoteAge.Duration()
+	if time.Since(binanceBook.UpdateTime) > maxAge || time.Since(gateBook.UpdateTime) > maxAge {
+		return
+	}
+
+	if pos, ok := positions.Get(market); ok {
+		if pos.State != position.StateOpen {
+			return
+		}
+		gateSide := exchange.SideBuy
+		if pos.GateQuantity.IsNegative() {
+			gateSide = exchange.SideSell
+		}
+		cost, err := roundTripCost(pos)
+		if err != nil {
+			log.Log.Errorf("market:%s err:%+v", market, err)
+			return
+		}
+		s := closeSpread(gateSide, gateBook, binanceBook)
+		// 开仓价差减平仓价差为本轮毛收益率，再扣除两边开平仓的手续费
+		profit := pos.DiffRate.Sub(s.Rate).Sub(cost)
+		// 即将结算的资金费：继续持有能收取时提高平仓要求，需要支付时降低平仓要求
+		funding, _ := expectedFunding(market, gateSide, conf.FundingExitWindow.Duration())
+		log.Log.Debugf("market:%s closeSpread:%+v cost:%+v profit:%+v funding:%+v", market, s.Rate, cost, profit, funding)
+		if profit.GreaterThanOrEqual(conf.MinExitProfit.Add(funding)) {
+			//出现平仓信号
+			log.Log.Infof("[close position]%s", spreadMsg(market, s))
+			closePosition(market, s)
+		}
+		return
+	}
+
+	// 下架中或暂停交易的市场只平仓不开仓
+	if !tradable(market) {
+		return
+	}
+	if config.Conf.Execution.Mode == config.ExecutionMaker {
+		checkMakerOpen(market, gateBook, binanceBook)
+		return
+	}
+	s := bestOpenSpread(gateBook, binanceBook)
+	// 毛价差都不够时不必查询费率
+	if s.Rate.LessThan(conf.MinEntryEdge) {
+		return
+	}
+	cost, err := fees.TakerRoundTrip(market)
+	if err != nil {
+		log.Log.Errorf("market:%s err:%+v", market, err)
+		return
+	}
+	funding, ok := expectedFunding(market, s.GateSide, conf.ExpectedHolding.Duration())
+	if !ok {
+		log.Log.Debugf("skip market:%s no funding rate", market)
+		return
+	}
+	// 预计持仓期间要支付的资金费计入成本，能收取的资金费抵扣成本
+	cost = cost.Sub(funding)
+	if s.Rate.Sub(cost).LessThan(conf.MinEntryEdge) {
+		return
+	}
+	openPosition(market, s, cost)
+}
+
+// prepareMarket 开仓前把两边设置为全仓和配置的杠杆，任一失败时不开仓，避免按错误的杠杆或保证金模式下单
+func prepareMarket(market string) error {
+	leverage := config.Conf.Strategy.Leverage
+	for _, ex := range []exchange.Exchange{binanceEx, gateEx} {
+		if err := ex.SetMarginMode(market, exchange.MarginCrossed); err != nil {
+			return fmt.Errorf("%s set margin mode err:%w", ex.Name(), err)
+		}
+		if err := ex.SetLeverage(market, leverage); err != nil {
+			return fmt.Errorf("%s set leverage %d err:%w", ex.Name(), leverage, err)
+		}
+	}
+	return nil
+}
+
+// roundTripCost 仓位开平仓的往返手续费率，与开仓判断时的计算方式一致
+func roundTripCost(pos position.Position) (decimal.Decimal, error) {
+	if pos.MakerVenue != "" {
+		return fees.MakerTakerRoundTrip(pos.Market, pos.MakerVenue)
+	}
+	return fees.TakerRoundTrip(pos.Market)
+}
+
+func spreadMsg(market string, s spread) string {
+	return fmt.Sprintf("市场:%s gate方向:%s gate价格:%+v binance价格:%+v 价差比例:%+v%s",
+		market, s.GateSide, s.GatePrice, s.BinancePrice, s.Rate.Mul(decimal.NewFromInt(100)).Truncate(5), "%")
+}
+
+// openPosition cost 为两边开平仓的往返手续费率减去预计持仓期间的资金费收益率
+func openPosition(market string, s spread, cost decimal.Decimal) {
+	conf := config.Conf.Strategy
+
+	gateContract, ok := gateEx.GetContract(market)
+	if !ok {
+		return
+	}
+	binanceContract, ok := binanceEx.GetContract(market)
+	if !ok {
+		return
+	}
+	// 两腿按同一个两边都能精确下单的基础币数量下单
+	rule, err := sizing.Match(gateContract, binanceContract)
+	if err != nil {
+		log.Log.Debugf("skip market:%s err:%+v", market, err)
+		return
+	}
+	quantity, err := rule.Quantity(conf.Notional, s.BinancePrice)
+	if err != nil {
+		log.Log.Debugf("skip market:%s err:%+v", market, err)
+		return
+	}
+	gateSize, binanceSize := quantity, quantity
+	// 按本次数量在两边深度上的成交均价重新计算价差，吃单滑点和手续费后仍需满足开仓阈值
+	impact, ok := impactSpread(market, s.GateSide, gateSize, binanceSize)
+	if !ok {
+		log.Log.Debugf("skip market:%s no fresh depth for size gate:%s binance:%s", market, gateSize, binanceSize)
+		return
+	}
+	if impact.Rate.Sub(cost).LessThan(conf.MinEntryEdge) {
+		log.Log.Infof("skip market:%s spread:%s impact spread:%s cost:%s below threshold", market, s.Rate, impact.Rate, cost)
+		return
+	}
+	signal := s
+	s = impact
+
+	notional := binanceSize.Mul(s.BinancePrice)
+	if err := positions.Reserve(&position.Position{Market: market, DiffRate: s.Rate, Notional: notional}); err != nil {
+		log.Log.Debugf("skip market:%s err:%+v", market, err)
+		return
+	}
+
+	nextFunding, _ := nextFundingTime(market)
+	log.Log.Infof("%s 成本:%s 下次资金费结算:%s ,count:%d", spreadMsg(market, s), cost, nextFunding.Format(time.DateTime), count2Taker.Add(1))
+
+	if err := prepareMarket(market); err != nil {
+		log.Log.Errorf("[open] market:%s err:%+v", market, err)
+		positions.Release(market)
+		return
+	}
+
+	//gate价格低: gate买单，binance卖单; 反之 gate卖单，binance买单
+	gateSide := s.GateSide
+	res := executePair(
+		&leg{ex: gateEx, contract: gateContract, req: exchange.OrderRequest{Market: market, Side: gateSide, Quantity: gateSize}, quote: quote.QuoteFunc(exchange.Gate), quotePrice: s.GatePrice},
+		&leg{ex: binanceEx, contract: binanceContract, req: exchange.OrderRequest{Market: market, Side: gateSide.Opposite(), Quantity: binanceSize}, quote: quote.QuoteFunc(exchange.Binance), quotePrice: s.BinancePrice},
+	)
+	gateQuantity := res.firstQuantity
+	if gateSide == exchange.SideSell {
+		gateQuantity = gateQuantity.Neg()
+	}
+
+	switch res.result {
+	case pairDone:
+		if res.secondRetries > 0 {
+			alert.Send("[open] market:%s binance leg filled after %d retries", market, res.secondRetries)
+		}
+		executed := newSpread(gateSide, res.firstPrice, res.secondPrice)
+		reportSlippage("open", market, signal, executed, signal.Rate.Sub(executed.Rate))
+		positions.Open(&position.Position{
+			Market:            market,
+			BinanceSide:       gateSide.Opposite(),
+			BinanceQuantity:   res.secondQuantity,
+			GateQuantity:      gateQuantity,
+			DiffRate:          executed.Rate,
+			SignalRate:        signal.Rate,
+			BinanceEntryPrice: res.secondPrice,
+			GateEntryPrice:    res.firstPrice,
+			Notional:          notional,
+			EntryFee: tradeFee(exchange.Gate, market, res.firstQuantity, res.firstPrice, false).
+				Add(tradeFee(exchange.Binance, market, res.secondQuantity, res.secondPrice, false)),
+		})
+	case pairFirstFailed:
+		switch exchange.KindOf(res.err) {
+		case exchange.KindInvalidKey, exchange.KindInsufficientMargin:
+			// 不会自行恢复，需要人工处理
+			alert.Send("[open] market:%s gate leg failed err:%+v", market, res.err)
+		default:
+			log.Log.Warningf("[open] market:%s gate leg failed err:%+v", market, res.err)
+		}
+		positions.Release(market)
+	case pairUnwound:
+		alert.Send("[open] market:%s binance leg failed, gate leg unwound err:%+v", market, res.err)
+		positions.Release(market)
+	case pairNaked:
+		alert.Send("[open] market:%s binance leg failed and gate leg unwind failed, naked gate:%s err:%+v", market, gateQuantity, res.err)
+		positions.Update(position.Position{
+			Market:         market,
+			State:          position.StateMismatch,
+			BinanceSide:    gateSide.Opposite(),
+			GateQuantity:   gateQuantity,
+			DiffRate:       s.Rate,
+			SignalRate:     signal.Rate,
+			GateEntryPrice: res.firstPrice,
+			Notional:       notional,
+		})
+	}
+}
+
+// closePosition signal 为触发平仓的平仓价差
+func closePosition(market string, signal spread) {
+	pos, ok := positions.BeginClose(market)
+	if !ok {
+		return
+	}
+	gateContract, _ := gateEx.GetContract(market)
+	binanceContract, _ := binanceEx.GetContract(market)
+
+	gateSide := exchange.SideSell
+	if pos.GateQuantity.IsNegative() {
+		gateSide = exchange.SideBuy
+	}
+	res := executePair(
+		&leg{ex: gateEx, contract: gateContract, req: exchange.OrderRequest{Market: market, Side: gateSide, Quantity: pos.GateQuantity.Abs(), ReduceOnly: true}, quotePrice: signal.GatePrice},
+		&leg{ex: binanceEx, contract: binanceContract, req: exchange.OrderRequest{Market: market, Side: pos.BinanceSide.Opposite(), Quantity: pos.BinanceQuantity, ReduceOnly: true}, quote: quote.QuoteFunc(exchange.Binance), quotePrice: signal.BinancePrice},
+	)
+	// gate 腿本次实际减少的仓位(带方向)
+	gateClosed := res.firstQuantity
+	if gateSide == exchange.SideSell {
+		gateClosed = gateClosed.Neg()
+	}
+
+	switch res.result {
+	case pairDone:
+		if res.secondRetries > 0 {
+			alert.Send("[close] market:%s binance leg filled after %d retries", market, res.secondRetries)
+		}
+		// 平仓价差与开仓价差同方向，越大越不利；开仓实际价差减平仓实际价差为本轮实际毛收益率
+		executed := newSpread(signal.GateSide, res.firstPrice, res.secondPrice)
+		reportSlippage("close", market, signal, executed, executed.Rate.Sub(signal.Rate))
+		log.Log.Infof("[close] market:%s entry spread:%s exit spread:%s gross:%s", market, pos.DiffRate, executed.Rate, pos.DiffRate.Sub(executed.Rate))
+		entryFee, funding := recordRoundTrip(pos, res, executed)
+		pos.EntryFee, pos.Funding = pos.EntryFee.Sub(entryFee), pos.Funding.Sub(funding)
+		pos.GateQuantity = pos.GateQuantity.Add(gateClosed)
+		pos.BinanceQuantity = pos.BinanceQuantity.Sub(res.secondQuantity)
+		if pos.GateQuantity.IsZero() && pos.BinanceQuantity.IsZero() {
+			positions.Close(market)
+			return
+		}
+		log.Log.Warningf("[close] market:%s partially closed, left binance:%s gate:%s", market, pos.BinanceQuantity, pos.GateQuantity)
+		pos.State = position.StateOpen
+		positions.Update(pos)
+	case pairFirstFailed:
+		log.Log.Warningf("[close] market:%s gate leg failed err:%+v", market, res.err)
+		pos.State = position.StateOpen
+		positions.Update(pos)
+	case pairUnwound:
+		alert.Send("[close] market:%s binance leg failed, gate leg reopened err:%+v", market, res.err)
+		pos.State = position.StateOpen
+		positions.Update(pos)
+	case pairNaked:
+		pos.GateQuantity = pos.GateQuantity.Add(gateClosed)
+		alert.Send("[close] market:%s binance leg failed and gate leg reopen failed, binance:%s %s gate:%s err:%+v",
+			market, pos.BinanceSide, pos.BinanceQuantity, pos.GateQuantity, res.err)
+		pos.State = position.StateMismatch
+		positions.Update(pos)
+	}
+}